@@ -0,0 +1,222 @@
+// Package config reads the gogit configuration of a repository.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"aduu.dev/tools/gogit/gitdir"
+)
+
+// Filename is the name of the configuration file in the repository root.
+const Filename = ".gogit.json"
+
+// localFilename is the name of the configuration file of a clone in the gogit directory, see gitdir.Gogit.
+const localFilename = "config.json"
+
+// Modes of removing local replace directives during a commit.
+const (
+	// ModeBackup removes local replace directives into the backup go.mod.b.
+	ModeBackup = "backup"
+	// ModeCommentOut comments out local replace directives with gogit-disabled markers.
+	ModeCommentOut = "comment-out"
+)
+
+var errUnknownMode = fmt.Errorf("unknown mode")
+
+// Config is the gogit configuration of a repository. Empty fields keep gogit's defaults.
+type Config struct {
+	// Shell selects the built-in hook templates: bash, sh or powershell.
+	Shell string `json:"shell,omitempty"`
+	// Binary is the gogit command the hooks run.
+	Binary string `json:"binary,omitempty"`
+	// Modules are the module directories relative to the repository root the hooks work on.
+	Modules []string `json:"modules,omitempty"`
+	// Mode is ModeBackup or ModeCommentOut.
+	Mode string `json:"mode,omitempty"`
+	// LogLevel is the klog verbosity the hooks run gogit with.
+	LogLevel *int `json:"logLevel,omitempty"`
+	// Templates maps hook names to template files relative to the repository root
+	// which replace the built-in templates.
+	Templates map[string]string `json:"templates,omitempty"`
+
+	// The optional features are unset if nil, so the configuration of a clone can turn off
+	// a feature the repository's configuration enables, see IsOn.
+
+	// VerifyStaged blocks commits whose staged module does not build without local replace directives.
+	VerifyStaged *bool `json:"verifyStaged,omitempty"`
+	// Vet additionally runs go vet when VerifyStaged verifies the staged module.
+	Vet *bool `json:"vet,omitempty"`
+	// Dependents blocks commits if a sibling checkout which locally replaces the module does not build.
+	Dependents *bool `json:"dependents,omitempty"`
+	// Profiles keeps a local replace profile per branch on checkout.
+	Profiles *bool `json:"profiles,omitempty"`
+	// Overlay regenerates go.dev.mod from go.local.mod on checkout and merge.
+	Overlay *bool `json:"overlay,omitempty"`
+
+	// Pin embeds the absolute path of Binary, or of the installing gogit, into the hooks
+	// which check that it exists.
+	Pin *bool `json:"pin,omitempty"`
+	// GoRunFallback runs gogit with go run in the version which installed the hooks if Binary does not exist.
+	GoRunFallback *bool `json:"goRunFallback,omitempty"`
+}
+
+// Filepath returns the path of the configuration file of the repository in base.
+func Filepath(base string) string {
+	return filepath.Join(base, Filename)
+}
+
+// IsOn returns true if the optional feature is set and enabled.
+func IsOn(feature *bool) bool {
+	return feature != nil && *feature
+}
+
+// ModuleDirs returns the configured module directories, the repository root if there are none.
+func (cfg Config) ModuleDirs() []string {
+	if len(cfg.Modules) == 0 {
+		return []string{"."}
+	}
+
+	return cfg.Modules
+}
+
+// LocalFilepath returns the path of the configuration file of the clone in base.
+// The configuration of a clone is not committed, install-hooks writes its flags there.
+func LocalFilepath(base string) (path string, err error) {
+	gogitDir, err := gitdir.Gogit(base)
+	if err != nil {
+		return
+	}
+
+	return filepath.Join(gogitDir, localFilename), nil
+}
+
+// Load reads the configuration of the repository in base merged with the configuration of the clone.
+//
+// Non-empty fields of the clone's configuration replace the repository's, including features set to false.
+// Missing configuration files are empty configurations, unknown fields are an error to catch typos.
+func Load(base string) (cfg Config, err error) {
+	cfg, err = load(Filepath(base))
+	if err != nil {
+		return
+	}
+
+	// Outside of a git repository there is no clone configuration.
+	if _, err = gitdir.Find(base); err != nil {
+		return cfg, nil
+	}
+
+	path, err := LocalFilepath(base)
+	if err != nil {
+		return
+	}
+
+	local, err := load(path)
+	if err != nil {
+		return
+	}
+
+	return merge(cfg, local), nil
+}
+
+// LoadLocal reads only the configuration of the clone in base, an empty configuration if there is none.
+func LoadLocal(base string) (cfg Config, err error) {
+	path, err := LocalFilepath(base)
+	if err != nil {
+		return
+	}
+
+	return load(path)
+}
+
+// SaveLocal writes the configuration of the clone in base.
+func SaveLocal(base string, cfg Config) (err error) {
+	path, err := LocalFilepath(base)
+	if err != nil {
+		return
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "\t")
+	if err != nil {
+		return
+	}
+
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func merge(cfg Config, local Config) Config {
+	if len(local.Shell) != 0 {
+		cfg.Shell = local.Shell
+	}
+
+	if len(local.Binary) != 0 {
+		cfg.Binary = local.Binary
+	}
+
+	if len(local.Modules) != 0 {
+		cfg.Modules = local.Modules
+	}
+
+	if len(local.Mode) != 0 {
+		cfg.Mode = local.Mode
+	}
+
+	if local.LogLevel != nil {
+		cfg.LogLevel = local.LogLevel
+	}
+
+	if len(local.Templates) != 0 {
+		cfg.Templates = local.Templates
+	}
+
+	features := []struct {
+		field **bool
+		local *bool
+	}{
+		{field: &cfg.VerifyStaged, local: local.VerifyStaged},
+		{field: &cfg.Vet, local: local.Vet},
+		{field: &cfg.Dependents, local: local.Dependents},
+		{field: &cfg.Profiles, local: local.Profiles},
+		{field: &cfg.Overlay, local: local.Overlay},
+		{field: &cfg.Pin, local: local.Pin},
+		{field: &cfg.GoRunFallback, local: local.GoRunFallback},
+	}
+
+	for _, f := range features {
+		if f.local != nil {
+			*f.field = f.local
+		}
+	}
+
+	return cfg
+}
+
+func load(path string) (cfg Config, err error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Config{}, nil
+	}
+
+	if err != nil {
+		return
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err = decoder.Decode(&cfg); err != nil {
+		return Config{}, fmt.Errorf("failed to parse %#v: %w", path, err)
+	}
+
+	switch cfg.Mode {
+	case "", ModeBackup, ModeCommentOut:
+	default:
+		return Config{}, fmt.Errorf("%w %#v in %#v: use %#v or %#v", errUnknownMode, cfg.Mode, path, ModeBackup, ModeCommentOut)
+	}
+
+	return cfg, nil
+}
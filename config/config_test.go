@@ -0,0 +1,135 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	zero := 0
+
+	tests := []struct {
+		name    string
+		content *string
+		want    Config
+		wantErr error
+	}{
+		{
+			name: "no config file",
+			want: Config{},
+		},
+		{
+			name:    "all fields",
+			content: pstring(`{"shell": "sh", "binary": "gogit", "modules": ["a", "b"], "mode": "comment-out", "logLevel": 0, "templates": {"pre-commit": "t"}}`),
+			want: Config{
+				Shell:     "sh",
+				Binary:    "gogit",
+				Modules:   []string{"a", "b"},
+				Mode:      ModeCommentOut,
+				LogLevel:  &zero,
+				Templates: map[string]string{"pre-commit": "t"},
+			},
+		},
+		{
+			name:    "unknown mode",
+			content: pstring(`{"mode": "delete"}`),
+			wantErr: errUnknownMode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, err := ioutil.TempDir("", "gogit-config")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			t.Cleanup(func() {
+				if err = os.RemoveAll(base); err != nil {
+					t.Fatal(err)
+				}
+			})
+
+			if tt.content != nil {
+				if err = ioutil.WriteFile(Filepath(base), []byte(*tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := Load(base)
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), "got %v", err)
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoad_clone_overrides(t *testing.T) {
+	base, err := ioutil.TempDir("", "gogit-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(base); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if err = os.MkdirAll(filepath.Join(base, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ioutil.WriteFile(Filepath(base), []byte(`{"profiles": true, "overlay": true, "dependents": true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	on, off := true, false
+	if err = SaveLocal(base, Config{Profiles: &off, VerifyStaged: &on}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, Config{Profiles: &off, Overlay: &on, Dependents: &on, VerifyStaged: &on}, cfg,
+		"an explicit false of the clone should turn off the repository's feature, unset ones keep it")
+}
+
+func TestLoad_unknown_field(t *testing.T) {
+	base, err := ioutil.TempDir("", "gogit-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(base); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if err = ioutil.WriteFile(Filepath(base), []byte(`{"shel": "sh"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Load(base)
+	assert.Error(t, err, "a typo should not be ignored")
+}
+
+func pstring(s string) *string {
+	return &s
+}
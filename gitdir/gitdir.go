@@ -0,0 +1,113 @@
+// Package gitdir finds the git directory of a repository so gogit can keep its state there.
+package gitdir
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	errNoGitRepository = fmt.Errorf("not inside a git repository")
+	errInvalidGitFile  = fmt.Errorf("invalid .git file")
+)
+
+// Find returns the git directory of the repository containing path.
+//
+// It walks up from path until it finds a .git directory or a .git file
+// as used by worktrees and submodules which points to the actual git directory.
+func Find(path string) (gitDir string, err error) {
+	_, gitDir, err = find(path)
+	return
+}
+
+// ModuleDir returns the directory at path relative to the top-level directory of its working tree
+// with slashes, "." for the top-level directory itself.
+//
+// It names a module of a repository independently of where the repository is checked out.
+func ModuleDir(path string) (dir string, err error) {
+	root, _, err := find(path)
+	if err != nil {
+		return
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return
+	}
+
+	return filepath.ToSlash(rel), nil
+}
+
+// find returns the top-level directory of the working tree containing path and its git directory.
+func find(path string) (root string, gitDir string, err error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+
+	for {
+		dotGit := filepath.Join(dir, ".git")
+
+		stat, err := os.Stat(dotGit)
+		switch {
+		case err == nil && stat.IsDir():
+			return dir, dotGit, nil
+		case err == nil:
+			gitDir, err = readGitFile(dotGit)
+			return dir, gitDir, err
+		case !os.IsNotExist(err):
+			return "", "", err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", fmt.Errorf("%w: %#v", errNoGitRepository, path)
+		}
+
+		dir = parent
+	}
+}
+
+// Gogit returns the directory below the git directory of the repository containing path
+// in which gogit keeps its state. The directory is created if it does not exist.
+func Gogit(path string) (dir string, err error) {
+	gitDir, err := Find(path)
+	if err != nil {
+		return
+	}
+
+	dir = filepath.Join(gitDir, "gogit")
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	return dir, nil
+}
+
+// readGitFile reads a .git file of the form "gitdir: <path>".
+func readGitFile(dotGit string) (gitDir string, err error) {
+	data, err := ioutil.ReadFile(dotGit)
+	if err != nil {
+		return
+	}
+
+	content := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(content, "gitdir:") {
+		return "", fmt.Errorf("%w: %#v", errInvalidGitFile, dotGit)
+	}
+
+	gitDir = strings.TrimSpace(strings.TrimPrefix(content, "gitdir:"))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(dotGit), gitDir)
+	}
+
+	return filepath.Clean(gitDir), nil
+}
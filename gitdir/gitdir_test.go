@@ -0,0 +1,122 @@
+package gitdir
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFind(t *testing.T) {
+	tempDir, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(tempDir); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// Resolve symlinks like /tmp on macOS so paths compare equal.
+	tempDir, err = filepath.EvalSymlinks(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := filepath.Join(tempDir, "repo")
+	sub := filepath.Join(repo, "sub", "module")
+	worktree := filepath.Join(tempDir, "worktree")
+
+	for _, dir := range []string{filepath.Join(repo, ".git", "worktrees", "wt"), sub, worktree} {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(worktree, ".git"), []byte("gitdir: ../repo/.git/worktrees/wt\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "repository root",
+			path: repo,
+			want: filepath.Join(repo, ".git"),
+		},
+		{
+			name: "sub-directory",
+			path: sub,
+			want: filepath.Join(repo, ".git"),
+		},
+		{
+			name: "worktree with .git file",
+			path: worktree,
+			want: filepath.Join(repo, ".git", "worktrees", "wt"),
+		},
+		{
+			name:    "no repository",
+			path:    tempDir,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Find(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Find(%#v) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestModuleDir(t *testing.T) {
+	tempDir, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(tempDir); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	repo := filepath.Join(tempDir, "repo")
+	sub := filepath.Join(repo, "sub", "module")
+
+	for _, dir := range []string{filepath.Join(repo, ".git"), sub} {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir, err := ModuleDir(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, ".", dir)
+
+	dir, err = ModuleDir(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "sub/module", dir)
+
+	_, err = ModuleDir(tempDir)
+	assert.True(t, errors.Is(err, errNoGitRepository), "got %v", err)
+}
@@ -0,0 +1,75 @@
+package gogitcmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"aduu.dev/tools/gogit/replace"
+	"aduu.dev/tools/gogit/workspace"
+)
+
+// GogitAddReplaceCMD adds local replace directives to sibling checkouts found in the workspace.
+func GogitAddReplaceCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "add-replace [module...]",
+		Short: "adds local replace directives to the checkouts of the modules found in the workspace",
+		Long: `Searches the workspace roots for a go.mod declaring each given module path and adds a replace
+directive with the relative path to it to the go.mod in --path (default: the current directory).
+
+With --all a replace directive is added for every required module which is checked out exactly once
+in the workspace and not replaced locally yet.
+
+The workspace roots are taken from --root, else from $` + workspace.RootsEnv + ` (separated like PATH),
+else the parent directory of the module is scanned.`,
+	}
+
+	path := cmd.Flags().String("path", ".", "directory of the module whose go.mod is changed")
+	roots := cmd.Flags().StringSlice("root", nil, "workspace roots to scan, can be repeated")
+	all := cmd.Flags().Bool("all", false, "adds local replaces for every required module found in the workspace")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		if *all == (len(args) != 0) {
+			return fmt.Errorf("either give modules or --all")
+		}
+
+		workspaceRoots, err := workspace.Roots(*path, *roots)
+		if err != nil {
+			return
+		}
+
+		var replaces []replace.LocalReplace
+
+		if *all {
+			replaces, err = workspace.FindRequired(*path, workspaceRoots)
+			if err != nil {
+				return
+			}
+		}
+
+		for _, modulePath := range args {
+			rep, err := workspace.Find(*path, workspaceRoots, modulePath)
+			if err != nil {
+				return err
+			}
+
+			replaces = append(replaces, rep)
+		}
+
+		for _, rep := range replaces {
+			if err = replace.AddLocalReplace(*path, rep); err != nil {
+				return
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), rep.String())
+		}
+
+		return nil
+	}
+	cmd.SetOut(os.Stdout)
+	cmd.SetErr(os.Stderr)
+	cmd.AddCommand()
+
+	return cmd
+}
@@ -17,8 +17,14 @@ func CMD() *cobra.Command {
 	}
 	cmd.SetOut(os.Stdout)
 	cmd.SetErr(os.Stderr)
-	cmd.AddCommand(GogitInstallHooksCMD(), GogitRemoveHooksCMD())
-	cmd.AddCommand(GogitReplaceCMD())
+	cmd.AddCommand(GogitInstallHooksCMD(), GogitRemoveHooksCMD(), GogitUpgradeHooksCMD(), GogitHookCMD())
+	cmd.AddCommand(GogitVersionCMD())
+	cmd.AddCommand(GogitReplaceCMD(), GogitCommitMsgCMD(), GogitRestoreLocalCMD())
+	cmd.AddCommand(GogitProfileCMD(), GogitOverlayCMD())
+	cmd.AddCommand(GogitExecCMD(), GogitVerifyStagedCMD())
+	cmd.AddCommand(GogitGraphCMD(), GogitReleasePlanCMD(), GogitDependentsCMD())
+	cmd.AddCommand(GogitAddReplaceCMD(), GogitDropReplaceCMD())
+	cmd.AddCommand(GogitEnableLocalCMD(), GogitDisableLocalCMD())
 
 	return cmd
 }
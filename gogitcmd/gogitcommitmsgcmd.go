@@ -0,0 +1,32 @@
+package gogitcmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"aduu.dev/tools/gogit/replace"
+)
+
+// GogitCommitMsgCMD adds the local replace directives removed during the commit as trailers to the commit message.
+func GogitCommitMsgCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "commit-msg <path> <message-file>",
+		Short: "adds the local replace directives removed during the commit as trailers to the commit message",
+		Long: `Meant to be run from the commit-msg hook after the pre-commit hook ran "replace".
+Each removed local replace directive is added as a trailer like
+
+Local-Replace: aduu.dev/utils => ../aduu-dev-utils @ <HEAD of ../aduu-dev-utils>
+
+Existing Local-Replace trailers are replaced, so amending a commit does not duplicate them.`,
+		Args: cobra.ExactArgs(2),
+	}
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		return replace.AddLocalReplaceTrailers(args[0], args[1])
+	}
+	cmd.SetOut(os.Stdout)
+	cmd.SetErr(os.Stderr)
+	cmd.AddCommand()
+
+	return cmd
+}
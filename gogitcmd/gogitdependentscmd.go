@@ -0,0 +1,90 @@
+package gogitcmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"aduu.dev/tools/gogit/workspace"
+)
+
+// GogitDependentsCMD lists the sibling checkouts which locally replace a module.
+func GogitDependentsCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dependents [path]",
+		Short: "lists the modules in the workspace which locally replace the module in path",
+		Long: `Scans the workspace roots for go.mod files whose local replace directives resolve to the
+module in path (default: the current directory).
+
+The workspace roots are taken from --root, else from $` + workspace.RootsEnv + ` (separated like PATH),
+else the parent directory of the module is scanned.
+
+With --build each dependent is built offline and a pass/fail matrix is printed.
+The command fails if any dependent does not build, so it can run from the pre-commit hook.`,
+		Args: cobra.MaximumNArgs(1),
+	}
+
+	roots := cmd.Flags().StringSlice("root", nil, "workspace roots to scan, can be repeated")
+	build := cmd.Flags().Bool("build", false, "runs go build ./... offline in each dependent")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		path := "."
+		if len(args) == 1 {
+			path = args[0]
+		}
+
+		workspaceRoots, err := workspace.Roots(path, *roots)
+		if err != nil {
+			return
+		}
+
+		dependents, err := workspace.Dependents(path, workspaceRoots)
+		if err != nil {
+			return
+		}
+
+		ok := true
+		if *build {
+			ok = workspace.Check(dependents)
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+
+		for _, dependent := range dependents {
+			result := ""
+
+			switch {
+			case !*build:
+			case dependent.BuildErr == nil:
+				result = "\tpass"
+			default:
+				result = "\tFAIL"
+			}
+
+			fmt.Fprintf(w, "%s\t%s%s\n", dependent.Path, dependent.Dir, result)
+		}
+
+		if err = w.Flush(); err != nil {
+			return
+		}
+
+		if !ok {
+			for _, dependent := range dependents {
+				if dependent.BuildErr != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "%s: %v\n", dependent.Path, dependent.BuildErr)
+				}
+			}
+
+			return fmt.Errorf("some dependents do not build")
+		}
+
+		return nil
+	}
+	cmd.SetOut(os.Stdout)
+	cmd.SetErr(os.Stderr)
+	cmd.AddCommand()
+
+	return cmd
+}
@@ -0,0 +1,53 @@
+package gogitcmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"aduu.dev/tools/gogit/replace"
+)
+
+// GogitDropReplaceCMD removes local replace directives while recording them for --undo.
+func GogitDropReplaceCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "drop-replace [module...]",
+		Short: "removes the local replace directives of the modules, all if none is given",
+		Long: `Removes local replace directives from the go.mod in --path (default: the current directory).
+
+The removed directives are recorded below the git directory, --undo adds them again.`,
+	}
+
+	path := cmd.Flags().String("path", ".", "directory of the module whose go.mod is changed")
+	undo := cmd.Flags().Bool("undo", false, "adds the dropped local replace directives again")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		var replaces []replace.LocalReplace
+
+		if *undo {
+			if len(args) != 0 {
+				return fmt.Errorf("--undo restores all dropped modules and takes no modules")
+			}
+
+			replaces, err = replace.RestoreDroppedLocalReplaces(*path)
+		} else {
+			replaces, err = replace.DropLocalReplaces(*path, args)
+		}
+
+		if err != nil {
+			return
+		}
+
+		for _, rep := range replaces {
+			fmt.Fprintln(cmd.OutOrStdout(), rep.String())
+		}
+
+		return nil
+	}
+	cmd.SetOut(os.Stdout)
+	cmd.SetErr(os.Stderr)
+	cmd.AddCommand()
+
+	return cmd
+}
@@ -0,0 +1,65 @@
+package gogitcmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"aduu.dev/tools/gogit/replace"
+)
+
+// GogitExecCMD runs a command against go.mod without local replace directives.
+func GogitExecCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "exec [path] -- <command> [args...]",
+		Short: "runs a command against go.mod without local replace directives, like CI sees it",
+		Long: `Strips the local replace directives from go.mod in path (default: the current directory),
+runs the command with stdio passed through and always restores go.mod afterwards,
+also if the command fails or gogit is interrupted. The command's exit code is forwarded.
+
+With --modfile go.mod is never touched: the command runs against a stripped temporary copy via GOFLAGS=-modfile.
+
+Example: gogit exec -- go test ./...`,
+		Args: cobra.MinimumNArgs(1),
+	}
+
+	useModfile := cmd.Flags().Bool("modfile", false, "runs against a stripped temporary copy of go.mod via -modfile instead of stripping go.mod")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		path := "."
+		command := args
+
+		if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+			if dash > 1 {
+				return fmt.Errorf("expected at most one path before --, got %v", args[:dash])
+			}
+
+			if dash == 1 {
+				path = args[0]
+			}
+
+			command = args[dash:]
+		}
+
+		if len(command) == 0 {
+			return fmt.Errorf("no command given after --")
+		}
+
+		exitCode, err := replace.Exec(path, replace.ExecOptions{Modfile: *useModfile}, command[0], command[1:]...)
+		if err != nil {
+			return err
+		}
+
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+
+		return nil
+	}
+	cmd.SetOut(os.Stdout)
+	cmd.SetErr(os.Stderr)
+	cmd.AddCommand()
+
+	return cmd
+}
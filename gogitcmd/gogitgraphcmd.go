@@ -0,0 +1,40 @@
+package gogitcmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"aduu.dev/tools/gogit/graph"
+)
+
+// GogitGraphCMD renders the graph of modules connected by local replace directives.
+func GogitGraphCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "graph [paths...]",
+		Short: "renders the graph of modules connected by local replace directives",
+		Long: `Follows the local replace directives of the modules in paths (default: the current directory)
+recursively and renders the module graph. Nodes are annotated with branch, HEAD and dirty state,
+edges forming a cycle are highlighted.`,
+	}
+
+	format := cmd.Flags().String("format", string(graph.FormatDOT), "output format: dot, mermaid or json")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		if len(args) == 0 {
+			args = []string{"."}
+		}
+
+		g, err := graph.Build(args)
+		if err != nil {
+			return
+		}
+
+		return g.Render(cmd.OutOrStdout(), graph.Format(*format))
+	}
+	cmd.SetOut(os.Stdout)
+	cmd.SetErr(os.Stderr)
+	cmd.AddCommand()
+
+	return cmd
+}
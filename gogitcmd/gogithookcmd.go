@@ -0,0 +1,64 @@
+package gogitcmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"aduu.dev/tools/gogit/config"
+	"aduu.dev/tools/gogit/hook"
+)
+
+// GogitHookCMD runs gogit's logic for the git hooks.
+func GogitHookCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "hook",
+		Short: "runs gogit's logic for the git hooks, installed hooks only call \"gogit hook run <name>\"",
+	}
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		return cmd.Help()
+	}
+	cmd.SetOut(os.Stdout)
+	cmd.SetErr(os.Stderr)
+	cmd.AddCommand(hookRunCMD())
+
+	return cmd
+}
+
+func hookRunCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "run <name> [hook arguments...]",
+		Short: "runs the hook with the arguments git passed to it",
+		Long: fmt.Sprintf(`Supported hooks: %s.
+What they do is configured in %s and by the install-hooks flags.
+
+%s=1 skips all hooks, %s=pre-commit,post-commit only the listed ones.
+%s=1 prints the steps of the hook without running them.
+%s=1 prints each step before running it, a number also sets the log verbosity.`,
+			strings.Join(hook.Names(), ", "), config.Filename, hook.SkipEnv, hook.SkipEnv, hook.DryRunEnv, hook.VerboseEnv),
+		Args: cobra.MinimumNArgs(1),
+	}
+
+	// The hook arguments are passed on as they are.
+	cmd.Flags().SetInterspersed(false)
+
+	path := cmd.Flags().String("path", ".", "path of the repository, git runs hooks in its root")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		if level, err := strconv.Atoi(os.Getenv(hook.VerboseEnv)); err == nil {
+			if v := flag.CommandLine.Lookup("v"); v != nil {
+				if err = v.Value.Set(strconv.Itoa(level)); err != nil {
+					return err
+				}
+			}
+		}
+
+		return hook.Run(*path, args[0], hook.ArgsFromEnv(args[0], args[1:]), hook.OptionsFromEnv())
+	}
+
+	return cmd
+}
@@ -1,10 +1,13 @@
 package gogitcmd
 
 import (
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 
+	"aduu.dev/tools/gogit/config"
 	"aduu.dev/tools/gogit/install"
 )
 
@@ -13,21 +16,76 @@ func GogitInstallHooksCMD() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "install-hooks <repo>",
 		Short: "installs pre-commit and post-commit hooks which remove local go.mod directives",
-		Args:  cobra.ExactArgs(1),
 	}
 
-	baseCommand := cmd.Flags().String("base-command", "", "sets the base command to use for fixing go.mod: default=gogit. Can also be set via $GOGIT_REPLACE_CMD")
+	baseCommand := cmd.Flags().String("base-command", "", "sets the base command to use for fixing go.mod: default=gogit. Can also be set via $GOGIT_REPLACE_CMD or binary in "+config.Filename)
+	shell := cmd.Flags().String("shell", "", "generates the hooks from the templates for bash, sh or powershell: default=bash or shell in "+config.Filename)
+	modules := cmd.Flags().StringSlice("module", nil, "module directory relative to the repository the hooks work on, can be repeated: default=. or modules in "+config.Filename)
+	logLevel := cmd.Flags().String("log-level", "", "klog verbosity the hooks run gogit with: default=gogit's default or logLevel in "+config.Filename)
+	profiles := cmd.Flags().Bool("profiles", false, "installs a post-checkout hook which keeps the local replace directives per branch")
+	overlay := cmd.Flags().Bool("overlay", false, "installs post-checkout and post-merge hooks which regenerate go.dev.mod from go.local.mod")
+	verifyStaged := cmd.Flags().Bool("verify-staged", false, "blocks commits whose staged module does not build without local replace directives")
+	vet := cmd.Flags().Bool("vet", false, "additionally runs go vet ./... when --verify-staged verifies the staged module")
+	dependents := cmd.Flags().Bool("dependents", false, "blocks commits if a sibling checkout which locally replaces the module does not build")
+	commentOut := cmd.Flags().Bool("comment-out", false, "comments out local replace directives with gogit-disabled markers instead of using a backup")
+	dispatcher := cmd.Flags().Bool("dispatcher", false, "moves existing hooks to <hook>.d/00-original and installs dispatchers running every step in <hook>.d")
+	repair := cmd.Flags().Bool("repair", false, "overwrites gogit blocks in hooks which were edited by hand")
+	pin := cmd.Flags().Bool("pin", false, "embeds the absolute path of this gogit or of --base-command into the hooks which check that it exists")
+	goRunFallback := cmd.Flags().Bool("go-run-fallback", false, "runs gogit with go run in this version if the binary of the hooks does not exist")
+	template := cmd.Flags().Bool("template", false, "installs the hooks into init.templateDir of the global git config for all new clones instead of into a repo")
+	recursive := addRecursiveFlags(cmd, "installs the hooks into every git repository containing a go.mod below the root instead of into a repo")
+	manager := cmd.Flags().String("manager", "", "adds gogit to the config of the hook manager of the repo instead of writing .git/hooks: "+strings.Join(install.Managers(), ", "))
+
+	cmd.Args = hooksArgs(template, recursive, manager)
 
 	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
 		baseCMD := *baseCommand
 		if len(baseCMD) == 0 {
 			baseCMD = os.Getenv("GOGIT_REPLACE_CMD")
 		}
-		if len(baseCMD) == 0 {
-			baseCMD = "gogit"
+
+		opts := install.Options{
+			BaseCommand:   baseCMD,
+			Shell:         *shell,
+			Modules:       *modules,
+			LogLevel:      *logLevel,
+			Profiles:      *profiles,
+			Overlay:       *overlay,
+			VerifyStaged:  *verifyStaged,
+			Vet:           *vet,
+			Dependents:    *dependents,
+			CommentOut:    *commentOut,
+			Dispatcher:    *dispatcher,
+			Repair:        *repair,
+			Pin:           *pin,
+			GoRunFallback: *goRunFallback,
+			Off:           offFeatures(cmd),
+		}
+
+		if recursive.enabled() {
+			results, err := install.InstallRecursive(*recursive.root, opts, recursive.options())
+			if err != nil {
+				return err
+			}
+
+			return recursive.write(cmd, results)
+		}
+
+		var reports []install.HookReport
+		switch {
+		case *template:
+			reports, err = install.InstallTemplate(opts)
+		case len(*manager) != 0:
+			reports, err = install.InstallManager(args[0], *manager, opts)
+		default:
+			reports, err = install.Install(args[0], opts)
+		}
+
+		for _, report := range reports {
+			fmt.Fprintln(cmd.OutOrStdout(), report.String())
 		}
 
-		return install.Hooks(args[0], baseCMD)
+		return err
 	}
 
 	cmd.SetOut(os.Stdout)
@@ -42,10 +100,32 @@ func GogitRemoveHooksCMD() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "remove-hooks <repo>",
 		Short: "removes the git commit hooks installed by install-hooks",
-		Args:  cobra.ExactArgs(1),
 	}
+
+	template := cmd.Flags().Bool("template", false, "removes the hooks from init.templateDir of the global git config instead of from a repo")
+	recursive := addRecursiveFlags(cmd, "removes the hooks from every git repository containing a go.mod below the root instead of from a repo")
+	manager := cmd.Flags().String("manager", "", "removes gogit from the config of the hook manager of the repo instead of from .git/hooks: "+strings.Join(install.Managers(), ", "))
+
+	cmd.Args = hooksArgs(template, recursive, manager)
+
 	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
-		return install.Remove(args[0])
+		if recursive.enabled() {
+			results, err := install.RemoveRecursive(*recursive.root, recursive.options())
+			if err != nil {
+				return err
+			}
+
+			return recursive.write(cmd, results)
+		}
+
+		switch {
+		case *template:
+			return install.RemoveTemplate()
+		case len(*manager) != 0:
+			return install.RemoveManager(args[0], *manager)
+		default:
+			return install.Remove(args[0])
+		}
 	}
 	cmd.SetOut(os.Stdout)
 	cmd.SetErr(os.Stderr)
@@ -53,3 +133,134 @@ func GogitRemoveHooksCMD() *cobra.Command {
 
 	return cmd
 }
+
+// GogitUpgradeHooksCMD rewrites the hooks installed by earlier versions of gogit to the current format.
+func GogitUpgradeHooksCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "upgrade-hooks [repos...]",
+		Short: "rewrites the hooks installed by earlier versions of gogit to the current format, default repo=.",
+	}
+
+	dryRun := cmd.Flags().Bool("dry-run", false, "prints what would be upgraded without changing the hooks")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		repos := args
+		if len(repos) == 0 {
+			repos = []string{"."}
+		}
+
+		failed := 0
+
+		for _, repo := range repos {
+			report, err := install.Upgrade(repo, *dryRun)
+			if err != nil {
+				failed++
+				fmt.Fprintf(cmd.OutOrStdout(), "%s: failed: %v\n", repo, err)
+
+				continue
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), report.String())
+		}
+
+		if failed != 0 {
+			return fmt.Errorf("failed to upgrade the hooks of %d of %d repositories", failed, len(repos))
+		}
+
+		return nil
+	}
+
+	cmd.SetOut(os.Stdout)
+	cmd.SetErr(os.Stderr)
+	cmd.AddCommand()
+
+	return cmd
+}
+
+// offFeatures returns the features turned off explicitly, e.g. with --profiles=false.
+// Flags which are not given keep what the configuration of the clone has.
+func offFeatures(cmd *cobra.Command) (off []string) {
+	for _, feature := range install.Features() {
+		if enabled, err := cmd.Flags().GetBool(feature); err == nil && !enabled && cmd.Flags().Changed(feature) {
+			off = append(off, feature)
+		}
+	}
+
+	return off
+}
+
+// recursiveFlags are the flags of install-hooks and remove-hooks for all repositories below a root.
+type recursiveFlags struct {
+	root        *string
+	excludeFile *string
+	jobs        *int
+	dryRun      *bool
+	format      *string
+}
+
+func addRecursiveFlags(cmd *cobra.Command, usage string) recursiveFlags {
+	return recursiveFlags{
+		root:        cmd.Flags().String("recursive", "", usage),
+		excludeFile: cmd.Flags().String("exclude-file", "", "with --recursive: file of glob patterns of repos relative to the root to skip: default=<root>/"+install.ExcludeFilename),
+		jobs:        cmd.Flags().Int("jobs", install.DefaultJobs, "with --recursive: number of repos processed concurrently"),
+		dryRun:      cmd.Flags().Bool("dry-run", false, "with --recursive: prints what would be done without changing the hooks"),
+		format:      cmd.Flags().String("format", string(install.FormatTable), "with --recursive: output format: table or json"),
+	}
+}
+
+func (f recursiveFlags) enabled() bool {
+	return len(*f.root) != 0
+}
+
+func (f recursiveFlags) options() install.RecursiveOptions {
+	return install.RecursiveOptions{
+		ExcludeFile: *f.excludeFile,
+		Jobs:        *f.jobs,
+		DryRun:      *f.dryRun,
+	}
+}
+
+// write prints the results and fails if any repository failed.
+func (f recursiveFlags) write(cmd *cobra.Command, results []install.RepoResult) (err error) {
+	if err = install.WriteResults(cmd.OutOrStdout(), results, install.Format(*f.format)); err != nil {
+		return
+	}
+
+	failed := 0
+
+	for _, result := range results {
+		if result.Failed() {
+			failed++
+		}
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("failed for %d of %d repositories", failed, len(results))
+	}
+
+	return nil
+}
+
+// hooksArgs expects the repo unless the hooks are installed into the git template or recursively.
+func hooksArgs(template *bool, recursive recursiveFlags, manager *string) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if !recursive.enabled() {
+			for _, flag := range []string{"exclude-file", "jobs", "dry-run", "format"} {
+				if cmd.Flags().Changed(flag) {
+					return fmt.Errorf("--%s needs --recursive", flag)
+				}
+			}
+		}
+
+		switch {
+		case *template && recursive.enabled():
+			return fmt.Errorf("--template and --recursive can not be combined")
+		case len(*manager) != 0 && (*template || recursive.enabled()):
+			return fmt.Errorf("--manager can not be combined with --template or --recursive")
+		case *template || recursive.enabled():
+			return cobra.NoArgs(cmd, args)
+		default:
+			return cobra.ExactArgs(1)(cmd, args)
+		}
+	}
+}
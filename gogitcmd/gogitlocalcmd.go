@@ -0,0 +1,74 @@
+package gogitcmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"aduu.dev/tools/gogit/replace"
+)
+
+// GogitDisableLocalCMD comments out the local replace directives with markers.
+func GogitDisableLocalCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "disable-local [path]",
+		Short: "comments out the local replace directives of the go.mod in path with gogit-disabled markers",
+		Long: `Rewrites each local replace directive of the go.mod in path (default: the current directory) to
+	// gogit-disabled: replace aduu.dev/utils => ../utils
+The go command ignores the marked lines and enable-local restores them, also on a fresh clone.`,
+		Args: cobra.MaximumNArgs(1),
+	}
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		replaces, err := replace.DisableLocalReplaces(pathArg(args), false)
+		if err != nil {
+			return
+		}
+
+		for _, rep := range replaces {
+			fmt.Fprintln(cmd.OutOrStdout(), rep.String())
+		}
+
+		return nil
+	}
+	cmd.SetOut(os.Stdout)
+	cmd.SetErr(os.Stderr)
+	cmd.AddCommand()
+
+	return cmd
+}
+
+// GogitEnableLocalCMD re-enables the local replace directives disabled with markers.
+func GogitEnableLocalCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "enable-local [path]",
+		Short: "re-enables the local replace directives disabled with gogit-disabled markers in the go.mod in path",
+		Args:  cobra.MaximumNArgs(1),
+	}
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		replaces, err := replace.EnableLocalReplaces(pathArg(args))
+		if err != nil {
+			return
+		}
+
+		for _, rep := range replaces {
+			fmt.Fprintln(cmd.OutOrStdout(), rep.String())
+		}
+
+		return nil
+	}
+	cmd.SetOut(os.Stdout)
+	cmd.SetErr(os.Stderr)
+	cmd.AddCommand()
+
+	return cmd
+}
+
+// pathArg returns the optional path argument or the current directory.
+func pathArg(args []string) string {
+	if len(args) == 0 {
+		return "."
+	}
+
+	return args[0]
+}
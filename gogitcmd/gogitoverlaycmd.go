@@ -0,0 +1,87 @@
+package gogitcmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"aduu.dev/tools/gogit/overlay"
+)
+
+// GogitOverlayCMD manages the go.dev.mod generated from go.mod and the personal go.local.mod overlay.
+func GogitOverlayCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "overlay",
+		Short: "generates go.dev.mod from go.mod and the replace directives in go.local.mod",
+		Long: `Keep local replace directives out of go.mod by writing them into a gitignored go.local.mod.
+"overlay sync" merges them into go.dev.mod (and go.dev.sum), which the go command uses
+with GOFLAGS=-modfile=<absolute path>/go.dev.mod as printed by "overlay env".`,
+	}
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		return cmd.Help()
+	}
+	cmd.SetOut(os.Stdout)
+	cmd.SetErr(os.Stderr)
+	cmd.AddCommand(overlaySyncCMD(), overlayEnvCMD())
+
+	return cmd
+}
+
+func overlaySyncCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync <path>",
+		Short: "regenerates go.dev.mod and go.dev.sum from go.mod, go.sum and go.local.mod",
+		Args:  cobra.ExactArgs(1),
+	}
+
+	ifExists := cmd.Flags().Bool("if-exists", false, "does nothing if there is no go.local.mod, used by the hooks")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		if *ifExists {
+			exists, err := overlay.Exists(args[0])
+			if err != nil || !exists {
+				return err
+			}
+		}
+
+		return overlay.Sync(args[0])
+	}
+
+	return cmd
+}
+
+func overlayEnvCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "env [path]",
+		Short: "prints the environment variable which makes the go command use the go.dev.mod of the module in path",
+		Long: `The go.dev.mod is referenced by its absolute path, so the setting works in every directory,
+e.g. in package directories or in an editor. path defaults to the current directory.`,
+		Args: cobra.MaximumNArgs(1),
+	}
+
+	export := cmd.Flags().Bool("export", false, "prefixes the output with export for eval in shells")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		path := "."
+		if len(args) == 1 {
+			path = args[0]
+		}
+
+		env, err := overlay.Env(path)
+		if err != nil {
+			return
+		}
+
+		if *export {
+			fmt.Fprintln(cmd.OutOrStdout(), "export "+env)
+			return nil
+		}
+
+		fmt.Fprintln(cmd.OutOrStdout(), env)
+
+		return nil
+	}
+
+	return cmd
+}
@@ -0,0 +1,99 @@
+package gogitcmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"aduu.dev/tools/gogit/profile"
+)
+
+// GogitProfileCMD manages the local replace profiles kept per branch.
+func GogitProfileCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profile",
+		Short: "manages the local replace directives kept per branch",
+		Long: `With "install-hooks --profiles" a post-checkout hook saves the local replace directives
+of go.mod for the branch you leave and restores the ones of the branch you check out.
+The profiles are stored in .git/gogit/profiles.`,
+	}
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		return cmd.Help()
+	}
+	cmd.SetOut(os.Stdout)
+	cmd.SetErr(os.Stderr)
+	cmd.AddCommand(profileListCMD(), profileShowCMD(), profileDropCMD(), profilePostCheckoutCMD())
+
+	return cmd
+}
+
+func profileListCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list <path>",
+		Short: "lists the branches with a saved profile",
+		Args:  cobra.ExactArgs(1),
+	}
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		branches, err := profile.List(args[0])
+		if err != nil {
+			return
+		}
+
+		for _, branch := range branches {
+			fmt.Fprintln(cmd.OutOrStdout(), branch)
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+func profileShowCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show <path> <branch>",
+		Short: "shows the local replace directives saved for the branch",
+		Args:  cobra.ExactArgs(2),
+	}
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		replaces, err := profile.Show(args[0], args[1])
+		if err != nil {
+			return
+		}
+
+		for _, rep := range replaces {
+			fmt.Fprintln(cmd.OutOrStdout(), rep.String())
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+func profileDropCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "drop <path> <branch>",
+		Short: "drops the profile saved for the branch",
+		Args:  cobra.ExactArgs(2),
+	}
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		return profile.Drop(args[0], args[1])
+	}
+
+	return cmd
+}
+
+func profilePostCheckoutCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "post-checkout <path> <previous-head> <new-head> <branch-flag>",
+		Short: "switches the profile on branch checkouts, run by the post-checkout hook",
+		Args:  cobra.ExactArgs(4),
+	}
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		return profile.PostCheckout(args[0], args[1], args[2], args[3])
+	}
+
+	return cmd
+}
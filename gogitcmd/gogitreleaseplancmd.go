@@ -0,0 +1,59 @@
+package gogitcmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"aduu.dev/tools/gogit/graph"
+)
+
+// GogitReleasePlanCMD plans the releases needed to replace the local replace directives of a module by versions.
+func GogitReleasePlanCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "release-plan [path]",
+		Short: "plans the tags and require bumps needed to release a stack of locally replaced modules",
+		Long: `Walks the local replace directives from the module in path (default: the current directory),
+orders the targets so dependencies come first and reports for each whether HEAD is tagged,
+which tag would come next and which require lines have to be bumped.
+
+Without --apply nothing is changed. With --apply the tags are created locally and the require
+lines are rewritten. A module whose require lines were bumped needs a commit before it can be
+tagged, so --apply stops there; commit and run it again to continue.`,
+		Args: cobra.MaximumNArgs(1),
+	}
+
+	apply := cmd.Flags().Bool("apply", false, "creates the tags and rewrites the require lines")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		path := "."
+		if len(args) == 1 {
+			path = args[0]
+		}
+
+		g, err := graph.Build([]string{path})
+		if err != nil {
+			return
+		}
+
+		plan, err := graph.Plan(g, path)
+		if err != nil {
+			return
+		}
+
+		if err = plan.Write(cmd.OutOrStdout()); err != nil {
+			return
+		}
+
+		if !*apply {
+			return nil
+		}
+
+		return plan.Apply()
+	}
+	cmd.SetOut(os.Stdout)
+	cmd.SetErr(os.Stderr)
+	cmd.AddCommand()
+
+	return cmd
+}
@@ -16,22 +16,52 @@ func gomodFilepath(base string) string {
 // GogitReplaceCMD replaces the local go.mod with one containing no go.mod files.
 func GogitReplaceCMD() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "replace <path>",
+		Use:   "replace <path>...",
 		Short: "replaces the local go.mod with one containing no go.mod files",
 		Long: `The command only works on the status of the staged file and not
-on the file's status in the working directory itself to avoid doing work on a non-staged go.mod'`,
-		Args: cobra.ExactArgs(1),
+on the file's status in the working directory itself to avoid doing work on a non-staged go.mod'
+
+Several module paths are worked on in order, with --note their removed local replaces are written
+into a single note.
+
+With --files the arguments are filenames, e.g. passed by the pre-commit framework,
+and every module whose go.mod is among them is worked on.`,
 	}
 
 	undo := cmd.Flags().Bool("undo", false, "undoes a prior replace on the path")
 	workOnStaged := cmd.Flags().Bool("replace-only-if-staged", false, "modifies only the staged go.mod if this is set to true")
+	note := cmd.Flags().Bool("note", false, "together with --undo writes the removed local replaces as git note on HEAD before undoing")
+	commentOut := cmd.Flags().Bool("comment-out", false, "comments out local replace directives with gogit-disabled markers instead of using a backup, --undo re-enables them")
+	files := cmd.Flags().Bool("files", false, "takes filenames instead of the path and works on the module of every go.mod among them, other files are ignored")
+
+	cmd.Args = func(cmd *cobra.Command, args []string) error {
+		if *files {
+			return cobra.ArbitraryArgs(cmd, args)
+		}
+
+		return cobra.MinimumNArgs(1)(cmd, args)
+	}
 
 	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
-		if *undo {
-			return replace.UndoRemovingLocalReplacesFromGomod(args[0], *workOnStaged)
+		bases := args
+		if *files {
+			bases = gomodDirs(args)
 		}
 
-		return replace.RemoveLocalReplacesFromGomod(args[0], *workOnStaged)
+		// A commit has a single note, so it is written for all modules before any of them is undone.
+		if *undo && *note {
+			if err = replace.WriteModulesLocalReplacesNote(bases); err != nil {
+				return err
+			}
+		}
+
+		for _, base := range bases {
+			if err = replaceModule(base, *undo, *workOnStaged, *commentOut); err != nil {
+				return err
+			}
+		}
+
+		return nil
 	}
 	cmd.SetOut(os.Stdout)
 	cmd.SetErr(os.Stderr)
@@ -39,3 +69,38 @@ on the file's status in the working directory itself to avoid doing work on a no
 
 	return cmd
 }
+
+// gomodDirs returns the directories of the go.mod files among filenames in order, each once.
+func gomodDirs(filenames []string) (dirs []string) {
+	seen := make(map[string]bool)
+
+	for _, filename := range filenames {
+		dir := filepath.Dir(filename)
+		if filepath.Clean(filename) != gomodFilepath(dir) || seen[dir] {
+			continue
+		}
+
+		seen[dir] = true
+		dirs = append(dirs, dir)
+	}
+
+	return dirs
+}
+
+func replaceModule(base string, undo bool, workOnStaged bool, commentOut bool) (err error) {
+	if commentOut {
+		if undo {
+			_, err = replace.EnableLocalReplaces(base)
+		} else {
+			_, err = replace.DisableLocalReplaces(base, workOnStaged)
+		}
+
+		return err
+	}
+
+	if undo {
+		return replace.UndoRemovingLocalReplacesFromGomod(base, workOnStaged)
+	}
+
+	return replace.RemoveLocalReplacesFromGomod(base, workOnStaged)
+}
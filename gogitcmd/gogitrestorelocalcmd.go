@@ -0,0 +1,36 @@
+package gogitcmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"aduu.dev/tools/gogit/replace"
+)
+
+// GogitRestoreLocalCMD re-applies the local replace directives noted for a commit.
+func GogitRestoreLocalCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore-local <commit> [path]",
+		Short: "re-applies the local replace directives noted for the commit to the go.mod in path",
+		Long: `The post-commit hook stores the removed local replace directives as git note under refs/notes/gogit.
+This command reads that note and adds the replace directives again if their targets exist
+relative to the module in path (default: the current directory).
+
+Fetch notes from a remote with: git fetch origin refs/notes/gogit:refs/notes/gogit`,
+		Args: cobra.RangeArgs(1, 2),
+	}
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		path := "."
+		if len(args) == 2 {
+			path = args[1]
+		}
+
+		return replace.RestoreLocalReplaces(path, args[0])
+	}
+	cmd.SetOut(os.Stdout)
+	cmd.SetErr(os.Stderr)
+	cmd.AddCommand()
+
+	return cmd
+}
@@ -0,0 +1,34 @@
+package gogitcmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"aduu.dev/tools/gogit/verify"
+)
+
+// GogitVerifyStagedCMD verifies that the staged module builds once local replace directives are stripped.
+func GogitVerifyStagedCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify-staged <path>",
+		Short: "verifies that the staged module builds offline without local replace directives",
+		Long: `Writes the files staged in the repository into a temporary directory, strips the local
+replace directives of the module's go.mod in there and runs "go build ./..." with
+GOFLAGS=-mod=mod GOPROXY=off against the local module cache.
+
+Install the hooks with --verify-staged to block commits which fail the verification.`,
+		Args: cobra.ExactArgs(1),
+	}
+
+	vet := cmd.Flags().Bool("vet", false, "additionally runs go vet ./...")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		return verify.Staged(args[0], verify.Options{Vet: *vet})
+	}
+	cmd.SetOut(os.Stdout)
+	cmd.SetErr(os.Stderr)
+	cmd.AddCommand()
+
+	return cmd
+}
@@ -0,0 +1,28 @@
+package gogitcmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"aduu.dev/tools/gogit/version"
+)
+
+// GogitVersionCMD prints the version of gogit.
+func GogitVersionCMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "prints the version of gogit which is also recorded in the hooks it installs",
+		Args:  cobra.NoArgs,
+	}
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		fmt.Fprintln(cmd.OutOrStdout(), version.Get())
+		return nil
+	}
+	cmd.SetOut(os.Stdout)
+	cmd.SetErr(os.Stderr)
+	cmd.AddCommand()
+
+	return cmd
+}
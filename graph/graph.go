@@ -0,0 +1,255 @@
+// Package graph follows local replace directives across sibling checkouts and builds the module graph.
+package graph
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5"
+	"golang.org/x/mod/modfile"
+	"k8s.io/klog/v2"
+
+	"aduu.dev/tools/gogit/replace"
+)
+
+// Node is a module checkout in the graph.
+type Node struct {
+	// Module is the module path declared in go.mod.
+	Module string `json:"module"`
+	// Dir is the absolute directory of the module.
+	Dir string `json:"dir"`
+	// Repository is true if Dir is inside a git repository.
+	Repository bool `json:"repository"`
+	// Branch is the checked out branch, empty on a detached HEAD.
+	Branch string `json:"branch,omitempty"`
+	// Head is the HEAD commit hash.
+	Head string `json:"head,omitempty"`
+	// Dirty is true if the worktree has uncommitted changes.
+	Dirty bool `json:"dirty"`
+	// Error describes why the module could not be read, e.g. because the replace target is missing.
+	Error string `json:"error,omitempty"`
+}
+
+// Edge is a local replace directive from the module in From to the module in To.
+type Edge struct {
+	// From is the directory of the module containing the replace directive.
+	From string `json:"from"`
+	// To is the directory of the replace target.
+	To string `json:"to"`
+	// Replace is the replace directive, e.g. "aduu.dev/utils => ../aduu-dev-utils".
+	Replace string `json:"replace"`
+}
+
+// Graph is the graph of modules connected by local replace directives.
+type Graph struct {
+	Nodes []*Node `json:"nodes"`
+	Edges []Edge  `json:"edges"`
+	// Cycles lists the directories of the modules forming each cycle.
+	Cycles [][]string `json:"cycles,omitempty"`
+
+	nodes map[string]*Node
+}
+
+// Build follows the local replace directives of the modules in paths recursively and returns the resulting graph.
+func Build(paths []string) (g *Graph, err error) {
+	g = &Graph{nodes: map[string]*Node{}}
+
+	for _, path := range paths {
+		dir, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = g.visit(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	g.Cycles = g.findCycles()
+
+	return g, nil
+}
+
+// Node returns the node of the module in dir or nil.
+func (g *Graph) Node(dir string) *Node {
+	return g.nodes[dir]
+}
+
+// Targets returns the directories the module in dir locally replaces, in go.mod order.
+func (g *Graph) Targets(dir string) (targets []string) {
+	for _, edge := range g.Edges {
+		if edge.From == dir {
+			targets = append(targets, edge.To)
+		}
+	}
+
+	return targets
+}
+
+func (g *Graph) visit(dir string) (err error) {
+	if _, ok := g.nodes[dir]; ok {
+		return nil
+	}
+
+	node := &Node{Dir: dir}
+	g.nodes[dir] = node
+	g.Nodes = append(g.Nodes, node)
+
+	gomodFilepath := filepath.Join(dir, "go.mod")
+
+	data, err := ioutil.ReadFile(gomodFilepath)
+	if err != nil {
+		// A missing replace target is part of the picture, not a reason to stop.
+		node.Error = err.Error()
+		return nil
+	}
+
+	file, err := modfile.Parse(gomodFilepath, data, nil)
+	if err != nil {
+		node.Error = err.Error()
+		return nil
+	}
+
+	if file.Module != nil {
+		node.Module = file.Module.Mod.Path
+	}
+
+	addGitInfo(node)
+
+	replaces, err := replace.LocalReplaces(dir)
+	if err != nil {
+		return
+	}
+
+	for _, rep := range replaces {
+		target := rep.New
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dir, target)
+		}
+
+		g.Edges = append(g.Edges, Edge{From: dir, To: target, Replace: rep.String()})
+
+		if err = g.visit(target); err != nil {
+			return
+		}
+
+		// The module path of a missing target is only known from the replace directive.
+		if targetNode := g.nodes[target]; len(targetNode.Module) == 0 {
+			targetNode.Module = rep.Old.Path
+		}
+	}
+
+	return nil
+}
+
+// addGitInfo annotates the node with the branch, HEAD and dirty state of its repository.
+func addGitInfo(node *Node) {
+	r, err := git.PlainOpenWithOptions(node.Dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return
+	}
+
+	node.Repository = true
+
+	head, err := r.Head()
+	if err != nil {
+		klog.InfoS("Failed to resolve HEAD", "dir", node.Dir, "err", err)
+		return
+	}
+
+	node.Head = head.Hash().String()
+	if head.Name().IsBranch() {
+		node.Branch = head.Name().Short()
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		return
+	}
+
+	status, err := w.Status()
+	if err != nil {
+		klog.InfoS("Failed to get worktree status", "dir", node.Dir, "err", err)
+		return
+	}
+
+	node.Dirty = !status.IsClean()
+}
+
+// findCycles returns each cycle once, starting at the module first reached by Build.
+func (g *Graph) findCycles() (cycles [][]string) {
+	const (
+		unvisited = iota
+		inProgress
+		done
+	)
+
+	state := make(map[string]int, len(g.Nodes))
+
+	var stack []string
+
+	var dfs func(dir string)
+	dfs = func(dir string) {
+		state[dir] = inProgress
+		stack = append(stack, dir)
+
+		for _, target := range g.Targets(dir) {
+			switch state[target] {
+			case unvisited:
+				dfs(target)
+			case inProgress:
+				for i := len(stack) - 1; i >= 0; i-- {
+					if stack[i] == target {
+						cycle := make([]string, len(stack)-i)
+						copy(cycle, stack[i:])
+						cycles = append(cycles, cycle)
+
+						break
+					}
+				}
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		state[dir] = done
+	}
+
+	for _, node := range g.Nodes {
+		if state[node.Dir] == unvisited {
+			dfs(node.Dir)
+		}
+	}
+
+	return cycles
+}
+
+// label returns a human readable description of the node.
+func (n *Node) label() string {
+	label := n.Module
+	if len(label) == 0 {
+		label = n.Dir
+	}
+
+	switch {
+	case len(n.Error) != 0:
+		return label + " (missing)"
+	case !n.Repository:
+		return label
+	}
+
+	ref := n.Branch
+	if len(ref) == 0 {
+		ref = "detached"
+	}
+
+	if len(n.Head) >= 7 {
+		ref += "@" + n.Head[:7]
+	}
+
+	if n.Dirty {
+		ref += " (dirty)"
+	}
+
+	return fmt.Sprintf("%s\n%s", label, ref)
+}
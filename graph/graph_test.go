@@ -0,0 +1,130 @@
+package graph
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeModules writes a go.mod per directory below base with the given local replace directives.
+func writeModules(t *testing.T, base string, modules map[string][]string) {
+	for dir, replaces := range modules {
+		gomod := "module aduu.dev/" + dir + "\n\ngo 1.14\n"
+		for _, rep := range replaces {
+			gomod += "\nreplace aduu.dev/" + rep + " => ../" + rep + "\n"
+		}
+
+		if err := os.MkdirAll(filepath.Join(base, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(base, dir, "go.mod"), []byte(gomod), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// Resolve symlinks like /tmp on macOS so paths compare equal.
+	dir, err = filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func TestBuild(t *testing.T) {
+	base := tempDir(t)
+
+	writeModules(t, base, map[string][]string{
+		"a": {"b", "missing"},
+		"b": {"c"},
+		"c": {"b"},
+	})
+
+	// b is a clean git repository on master.
+	r, err := git.PlainInit(filepath.Join(base, "b"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = w.Add("go.mod"); err != nil {
+		t.Fatal(err)
+	}
+
+	head, err := w.Commit("init", &git.CommitOptions{
+		Author: &object.Signature{Name: "gogit", Email: "gogit@aduu.dev"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := Build([]string{filepath.Join(base, "a")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, b, c, missing := filepath.Join(base, "a"), filepath.Join(base, "b"), filepath.Join(base, "c"), filepath.Join(base, "missing")
+
+	var dirs []string
+	for _, node := range g.Nodes {
+		dirs = append(dirs, node.Dir)
+	}
+
+	assert.Equal(t, []string{a, b, c, missing}, dirs)
+	assert.Equal(t, []string{b, missing}, g.Targets(a))
+	assert.Equal(t, [][]string{{b, c}}, g.Cycles)
+
+	assert.Equal(t, &Node{
+		Module:     "aduu.dev/b",
+		Dir:        b,
+		Repository: true,
+		Branch:     "master",
+		Head:       head.String(),
+	}, g.Node(b))
+
+	assert.Equal(t, "aduu.dev/missing", g.Node(missing).Module, "missing targets are named after the replace directive")
+	assert.NotEmpty(t, g.Node(missing).Error)
+
+	var dot bytes.Buffer
+	if err = g.Render(&dot, FormatDOT); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Contains(t, dot.String(), "\tn1 -> n2 [color=red];\n", "cycle edges should be highlighted")
+	assert.Contains(t, dot.String(), `n1 [label="aduu.dev/b\nmaster@`+head.String()[:7]+`"];`)
+
+	var mermaid bytes.Buffer
+	if err = g.Render(&mermaid, FormatMermaid); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Contains(t, mermaid.String(), "\tn0 --> n1\n")
+
+	if err = g.Render(&bytes.Buffer{}, "svg"); err == nil {
+		t.Fatal("Render should fail for unknown formats")
+	}
+}
@@ -0,0 +1,426 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"k8s.io/klog/v2"
+
+	"aduu.dev/tools/gogit/replace"
+)
+
+var (
+	errCycle       = fmt.Errorf("local replace directives form a cycle")
+	errNotInGraph  = fmt.Errorf("module is not in the graph")
+	errNeedsCommit = fmt.Errorf("module needs a commit before it can be tagged")
+)
+
+// Bump is a require line which needs a new version.
+type Bump struct {
+	Module string `json:"module"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// Release is one step of a ReleasePlan.
+type Release struct {
+	*Node
+	// Root is true for the module the plan was made for. It is not tagged.
+	Root bool `json:"root"`
+	// TagPrefix is the prefix of tags of modules in a sub-directory of their repository, e.g. "utils/".
+	TagPrefix string `json:"tagPrefix,omitempty"`
+	// Tag is an existing semver tag pointing to HEAD.
+	Tag string `json:"tag,omitempty"`
+	// NextTag is the tag to create if HEAD is not tagged yet.
+	NextTag string `json:"nextTag,omitempty"`
+	// Bumps are the require lines of this module which have to be bumped before it is tagged.
+	Bumps []Bump `json:"bumps,omitempty"`
+	// Blocked describes why the module can not be tagged as it is.
+	Blocked string `json:"blocked,omitempty"`
+}
+
+// Version returns the version dependents have to require after the release.
+func (r *Release) Version() string {
+	tag := r.Tag
+	if len(tag) == 0 {
+		tag = r.NextTag
+	}
+
+	return strings.TrimPrefix(tag, r.TagPrefix)
+}
+
+// ReleasePlan lists the releases needed to get rid of the local replace directives of a module,
+// dependencies first.
+type ReleasePlan struct {
+	Releases []*Release `json:"releases"`
+}
+
+// Plan returns the release plan for the module in dir, which must be part of g.
+func Plan(g *Graph, dir string) (plan *ReleasePlan, err error) {
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return
+	}
+
+	if g.Node(dir) == nil {
+		return nil, fmt.Errorf("%w: %#v", errNotInGraph, dir)
+	}
+
+	order, err := topologicalOrder(g, dir)
+	if err != nil {
+		return
+	}
+
+	plan = &ReleasePlan{}
+	releases := make(map[string]*Release, len(order))
+
+	for _, nodeDir := range order {
+		release := &Release{Node: g.Node(nodeDir), Root: nodeDir == dir}
+
+		if len(release.Error) != 0 {
+			return nil, fmt.Errorf("can not plan release of %v: %v", release.Module, release.Error)
+		}
+
+		if err = addBumps(g, release, releases); err != nil {
+			return
+		}
+
+		if !release.Root {
+			if err = addTags(release); err != nil {
+				return nil, fmt.Errorf("failed to read tags of %v: %w", release.Module, err)
+			}
+		}
+
+		releases[nodeDir] = release
+		plan.Releases = append(plan.Releases, release)
+	}
+
+	return plan, nil
+}
+
+// topologicalOrder returns the modules reachable from dir, each after all modules it locally replaces.
+func topologicalOrder(g *Graph, dir string) (order []string, err error) {
+	state := map[string]int{}
+
+	const (
+		inProgress = iota + 1
+		done
+	)
+
+	var visit func(dir string) error
+	visit = func(dir string) error {
+		switch state[dir] {
+		case inProgress:
+			return fmt.Errorf("%w at %v", errCycle, g.Node(dir).Module)
+		case done:
+			return nil
+		}
+
+		state[dir] = inProgress
+
+		for _, target := range g.Targets(dir) {
+			if err := visit(target); err != nil {
+				return err
+			}
+		}
+
+		state[dir] = done
+		order = append(order, dir)
+
+		return nil
+	}
+
+	return order, visit(dir)
+}
+
+// addBumps adds the require lines of the release which need the versions of the already planned releases.
+func addBumps(g *Graph, release *Release, releases map[string]*Release) (err error) {
+	requires, err := replace.Requires(release.Dir)
+	if err != nil {
+		return
+	}
+
+	for _, target := range g.Targets(release.Dir) {
+		dependency := releases[target]
+
+		from, ok := requires[dependency.Module]
+		if !ok || from == dependency.Version() {
+			continue
+		}
+
+		release.Bumps = append(release.Bumps, Bump{Module: dependency.Module, From: from, To: dependency.Version()})
+	}
+
+	return nil
+}
+
+// addTags finds the semver tag of HEAD or computes the next one and checks whether HEAD can be tagged.
+func addTags(release *Release) (err error) {
+	r, err := git.PlainOpenWithOptions(release.Dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		return
+	}
+
+	rel, err := filepath.Rel(w.Filesystem.Root(), release.Dir)
+	if err != nil {
+		return
+	}
+
+	if rel != "." {
+		release.TagPrefix = filepath.ToSlash(rel) + "/"
+	}
+
+	tags, err := semverTags(r, release.TagPrefix, release.Module)
+	if err != nil {
+		return
+	}
+
+	highest := ""
+
+	for tag, hash := range tags {
+		version := strings.TrimPrefix(tag, release.TagPrefix)
+
+		if hash.String() == release.Head && semver.Compare(version, strings.TrimPrefix(release.Tag, release.TagPrefix)) > 0 {
+			release.Tag = tag
+		}
+
+		if semver.Compare(version, highest) > 0 {
+			highest = version
+		}
+	}
+
+	if len(release.Tag) == 0 {
+		release.NextTag = release.TagPrefix + nextVersion(release.Module, highest)
+	}
+
+	release.Blocked, err = blocked(r, w, rel)
+
+	return err
+}
+
+// semverTags returns the semver tags with the prefix and the commits they point to.
+// Tags of another major version than the one of modulePath are skipped.
+func semverTags(r *git.Repository, prefix string, modulePath string) (tags map[string]plumbing.Hash, err error) {
+	iter, err := r.Tags()
+	if err != nil {
+		return
+	}
+
+	tags = map[string]plumbing.Hash{}
+
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().Short()
+		version := strings.TrimPrefix(name, prefix)
+		if !strings.HasPrefix(name, prefix) || !semver.IsValid(version) || !isModuleVersion(modulePath, version) {
+			return nil
+		}
+
+		hash := ref.Hash()
+
+		// Annotated tags point to a tag object instead of the commit.
+		if tag, err := r.TagObject(hash); err == nil {
+			hash = tag.Target
+		}
+
+		tags[name] = hash
+
+		return nil
+	})
+
+	return tags, err
+}
+
+// isModuleVersion reports whether version is of the major version of modulePath:
+// v0 or v1 without a major version suffix and vN with the suffix /vN.
+func isModuleVersion(modulePath string, version string) bool {
+	_, pathMajor, ok := module.SplitPathVersion(modulePath)
+	if !ok {
+		return false
+	}
+
+	major := semver.Major(version)
+
+	if len(pathMajor) == 0 {
+		return (major == "v0" || major == "v1") && semver.Build(version) != "+incompatible"
+	}
+
+	return major == strings.TrimLeft(pathMajor, "/.")
+}
+
+// nextVersion returns the version after highest, the next patch release.
+// A pre-release is followed by its release.
+// Without any release of the module's major version it starts at v0.1.0 or vN.0.0
+// for modules with a major version suffix.
+func nextVersion(modulePath string, highest string) string {
+	if len(highest) == 0 || !isModuleVersion(modulePath, highest) {
+		if _, major, ok := module.SplitPathVersion(modulePath); ok && len(major) != 0 {
+			return strings.TrimPrefix(major, "/") + ".0.0"
+		}
+
+		return "v0.1.0"
+	}
+
+	var major, minor, patch int
+
+	// Ignore pre-release and build suffixes, the next release is a full one.
+	if _, err := fmt.Sscanf(semver.Canonical(highest), "v%d.%d.%d", &major, &minor, &patch); err != nil {
+		return "v0.1.0"
+	}
+
+	if len(semver.Prerelease(highest)) != 0 {
+		return fmt.Sprintf("v%d.%d.%d", major, minor, patch)
+	}
+
+	return fmt.Sprintf("v%d.%d.%d", major, minor, patch+1)
+}
+
+// blocked returns why HEAD does not represent the module as it is in the worktree.
+//
+// Changes to go.mod and go.sum are expected because of the local replace directives,
+// but the required versions must match the ones committed.
+func blocked(r *git.Repository, w *git.Worktree, rel string) (reason string, err error) {
+	status, err := w.Status()
+	if err != nil {
+		return
+	}
+
+	gomod := filepath.ToSlash(filepath.Join(rel, "go.mod"))
+	gosum := filepath.ToSlash(filepath.Join(rel, "go.sum"))
+
+	for file, fileStatus := range status {
+		if file == gomod || file == gosum || fileStatus.Worktree == git.Untracked {
+			continue
+		}
+
+		return "uncommitted changes in " + file, nil
+	}
+
+	head, err := r.Head()
+	if err != nil {
+		return
+	}
+
+	commit, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return
+	}
+
+	committedFile, err := commit.File(gomod)
+	if err != nil {
+		return
+	}
+
+	committed, err := committedFile.Contents()
+	if err != nil {
+		return
+	}
+
+	committedRequires, err := replace.ParseRequires([]byte(committed))
+	if err != nil {
+		return
+	}
+
+	requires, err := replace.Requires(filepath.Join(w.Filesystem.Root(), rel))
+	if err != nil {
+		return
+	}
+
+	if !reflect.DeepEqual(committedRequires, requires) {
+		return "required versions in go.mod are not committed", nil
+	}
+
+	return "", nil
+}
+
+// Write prints the plan in a human readable form.
+func (p *ReleasePlan) Write(w io.Writer) (err error) {
+	var b strings.Builder
+
+	for i, release := range p.Releases {
+		fmt.Fprintf(&b, "%d. %s (%s)\n", i+1, release.Module, release.Dir)
+
+		for _, bump := range release.Bumps {
+			fmt.Fprintf(&b, "   bump require %s %s => %s\n", bump.Module, bump.From, bump.To)
+		}
+
+		switch {
+		case release.Root:
+			continue
+		case len(release.Tag) != 0:
+			fmt.Fprintf(&b, "   HEAD %s is tagged %s\n", shortHash(release.Head), release.Tag)
+		default:
+			fmt.Fprintf(&b, "   tag HEAD %s as %s\n", shortHash(release.Head), release.NextTag)
+		}
+
+		if len(release.Blocked) != 0 {
+			fmt.Fprintf(&b, "   blocked: %s\n", release.Blocked)
+		}
+	}
+
+	_, err = io.WriteString(w, b.String())
+
+	return err
+}
+
+// Apply carries out the plan in order: it bumps the require lines of each module and tags its HEAD.
+//
+// A module whose require lines had to be bumped or which is blocked needs a commit first,
+// so Apply stops there with an error. Run the plan again after committing to continue.
+func (p *ReleasePlan) Apply() (err error) {
+	for _, release := range p.Releases {
+		for _, bump := range release.Bumps {
+			if err = replace.SetRequire(release.Dir, bump.Module, bump.To); err != nil {
+				return
+			}
+		}
+
+		if release.Root {
+			continue
+		}
+
+		if len(release.Bumps) != 0 {
+			return fmt.Errorf("%w: bumped the requires of %v in %v, commit go.mod and rerun", errNeedsCommit, release.Module, release.Dir)
+		}
+
+		if len(release.Blocked) != 0 {
+			return fmt.Errorf("%w: %v in %v is blocked: %v", errNeedsCommit, release.Module, release.Dir, release.Blocked)
+		}
+
+		if len(release.Tag) != 0 {
+			continue
+		}
+
+		r, err := git.PlainOpenWithOptions(release.Dir, &git.PlainOpenOptions{DetectDotGit: true})
+		if err != nil {
+			return err
+		}
+
+		if _, err = r.CreateTag(release.NextTag, plumbing.NewHash(release.Head), nil); err != nil {
+			return fmt.Errorf("failed to tag %v: %w", release.Module, err)
+		}
+
+		klog.InfoS("Tagged release", "module", release.Module, "tag", release.NextTag, "head", release.Head)
+	}
+
+	return nil
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+
+	return hash
+}
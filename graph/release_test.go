@@ -0,0 +1,196 @@
+package graph
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+
+	"aduu.dev/tools/gogit/replace"
+)
+
+// commitAll stages go.mod in dir and commits it, initializing the repository if needed.
+func commitAll(t *testing.T, dir string) plumbing.Hash {
+	r, err := git.PlainOpen(dir)
+	if err == git.ErrRepositoryNotExists {
+		r, err = git.PlainInit(dir, false)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = w.Add("go.mod"); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := w.Commit("commit", &git.CommitOptions{
+		Author: &object.Signature{Name: "gogit", Email: "gogit@aduu.dev"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hash
+}
+
+func writeGomod(t *testing.T, dir string, content string) {
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	base := tempDir(t)
+	writeModules(t, base, map[string][]string{"a": nil, "b": nil, "c": nil})
+
+	a, b, c := filepath.Join(base, "a"), filepath.Join(base, "b"), filepath.Join(base, "c")
+
+	writeGomod(t, a, "module aduu.dev/a\n\ngo 1.14\n\nrequire aduu.dev/b v0.9.0\n\nreplace aduu.dev/b => ../b\n")
+	writeGomod(t, b, "module aduu.dev/b\n\ngo 1.14\n\nrequire aduu.dev/c v0.0.1\n\nreplace aduu.dev/c => ../c\n")
+
+	commitAll(t, c)
+	bHead := commitAll(t, b)
+
+	rb, err := git.PlainOpen(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = rb.CreateTag("v1.0.0", bHead, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := Build([]string{a})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := Plan(g, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !assert.Len(t, plan.Releases, 3) {
+		return
+	}
+
+	assert.Equal(t, "aduu.dev/c", plan.Releases[0].Module)
+	assert.Equal(t, "v0.1.0", plan.Releases[0].NextTag)
+
+	assert.Equal(t, "aduu.dev/b", plan.Releases[1].Module)
+	assert.Equal(t, "v1.0.0", plan.Releases[1].Tag)
+	assert.Equal(t, []Bump{{Module: "aduu.dev/c", From: "v0.0.1", To: "v0.1.0"}}, plan.Releases[1].Bumps)
+
+	assert.True(t, plan.Releases[2].Root)
+	assert.Equal(t, []Bump{{Module: "aduu.dev/b", From: "v0.9.0", To: "v1.0.0"}}, plan.Releases[2].Bumps)
+
+	var out bytes.Buffer
+	if err = plan.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Contains(t, out.String(), "bump require aduu.dev/c v0.0.1 => v0.1.0")
+
+	// b needs a commit after its requires are bumped.
+	if err = plan.Apply(); err == nil {
+		t.Fatal("Apply should stop at b")
+	}
+
+	requires, err := replace.Requires(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "v0.1.0", requires["aduu.dev/c"])
+
+	rc, err := git.PlainOpen(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = rc.Tag("v0.1.0"); err != nil {
+		t.Fatalf("c should be tagged: %v", err)
+	}
+
+	// Continue after committing b.
+	commitAll(t, b)
+
+	g, err = Build([]string{a})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err = Plan(g, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "v1.0.1", plan.Releases[1].NextTag)
+
+	if err = plan.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	requires, err = replace.Requires(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "v1.0.1", requires["aduu.dev/b"])
+}
+
+func Test_nextVersion(t *testing.T) {
+	tests := []struct {
+		modulePath string
+		highest    string
+		want       string
+	}{
+		{modulePath: "aduu.dev/utils", highest: "", want: "v0.1.0"},
+		{modulePath: "aduu.dev/utils/v2", highest: "", want: "v2.0.0"},
+		{modulePath: "aduu.dev/utils", highest: "v1.2.3", want: "v1.2.4"},
+		{modulePath: "aduu.dev/utils", highest: "v1.3.0-rc.1", want: "v1.3.0"},
+		{modulePath: "aduu.dev/utils", highest: "v1.2.0-rc.1", want: "v1.2.0"},
+		{modulePath: "aduu.dev/utils/v2", highest: "v2.1.0-beta", want: "v2.1.0"},
+		{modulePath: "aduu.dev/utils/v2", highest: "v2.1.0", want: "v2.1.1"},
+		{modulePath: "aduu.dev/utils/v2", highest: "v1.4.2", want: "v2.0.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.modulePath+"@"+tt.highest, func(t *testing.T) {
+			assert.Equal(t, tt.want, nextVersion(tt.modulePath, tt.highest))
+		})
+	}
+}
+
+func Test_isModuleVersion(t *testing.T) {
+	tests := []struct {
+		modulePath string
+		version    string
+		want       bool
+	}{
+		{modulePath: "aduu.dev/utils", version: "v0.3.1", want: true},
+		{modulePath: "aduu.dev/utils", version: "v1.2.0-rc.1", want: true},
+		{modulePath: "aduu.dev/utils", version: "v2.0.0", want: false},
+		{modulePath: "aduu.dev/utils", version: "v2.0.0+incompatible", want: false},
+		{modulePath: "aduu.dev/utils/v2", version: "v1.4.2", want: false},
+		{modulePath: "aduu.dev/utils/v2", version: "v2.1.0", want: true},
+		{modulePath: "aduu.dev/utils/v2", version: "v3.0.0", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.modulePath+"@"+tt.version, func(t *testing.T) {
+			assert.Equal(t, tt.want, isModuleVersion(tt.modulePath, tt.version))
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format is an output format of Render.
+type Format string
+
+// Supported output formats.
+const (
+	FormatDOT     Format = "dot"
+	FormatMermaid Format = "mermaid"
+	FormatJSON    Format = "json"
+)
+
+var (
+	errUnknownFormat = fmt.Errorf("unknown format")
+)
+
+// Render writes the graph in the given format.
+func (g *Graph) Render(w io.Writer, format Format) (err error) {
+	switch format {
+	case FormatDOT:
+		return g.dot(w)
+	case FormatMermaid:
+		return g.mermaid(w)
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(g)
+	default:
+		return fmt.Errorf("%w %#v, expected one of %v, %v or %v", errUnknownFormat, format, FormatDOT, FormatMermaid, FormatJSON)
+	}
+}
+
+// ids returns a short identifier per node directory.
+func (g *Graph) ids() map[string]string {
+	ids := make(map[string]string, len(g.Nodes))
+	for i, node := range g.Nodes {
+		ids[node.Dir] = fmt.Sprintf("n%d", i)
+	}
+
+	return ids
+}
+
+// cycleEdges returns the edges which are part of a cycle keyed by from and to directory.
+func (g *Graph) cycleEdges() map[[2]string]bool {
+	edges := map[[2]string]bool{}
+
+	for _, cycle := range g.Cycles {
+		for i, dir := range cycle {
+			edges[[2]string{dir, cycle[(i+1)%len(cycle)]}] = true
+		}
+	}
+
+	return edges
+}
+
+func (g *Graph) dot(w io.Writer) (err error) {
+	ids := g.ids()
+	cycleEdges := g.cycleEdges()
+
+	var b strings.Builder
+
+	b.WriteString("digraph gogit {\n")
+	b.WriteString("\tnode [shape=box];\n")
+
+	for _, node := range g.Nodes {
+		attributes := fmt.Sprintf("label=%q", node.label())
+
+		switch {
+		case len(node.Error) != 0:
+			attributes += ", style=dashed"
+		case node.Dirty:
+			attributes += ", color=orange"
+		}
+
+		fmt.Fprintf(&b, "\t%s [%s];\n", ids[node.Dir], attributes)
+	}
+
+	for _, edge := range g.Edges {
+		attributes := ""
+		if cycleEdges[[2]string{edge.From, edge.To}] {
+			attributes = " [color=red]"
+		}
+
+		fmt.Fprintf(&b, "\t%s -> %s%s;\n", ids[edge.From], ids[edge.To], attributes)
+	}
+
+	b.WriteString("}\n")
+
+	_, err = io.WriteString(w, b.String())
+
+	return err
+}
+
+func (g *Graph) mermaid(w io.Writer) (err error) {
+	ids := g.ids()
+	cycleEdges := g.cycleEdges()
+
+	var b strings.Builder
+
+	b.WriteString("graph TD\n")
+
+	for _, node := range g.Nodes {
+		label := strings.ReplaceAll(node.label(), `"`, "#quot;")
+		label = strings.ReplaceAll(label, "\n", "<br/>")
+
+		fmt.Fprintf(&b, "\t%s[\"%s\"]\n", ids[node.Dir], label)
+	}
+
+	for i, edge := range g.Edges {
+		fmt.Fprintf(&b, "\t%s --> %s\n", ids[edge.From], ids[edge.To])
+
+		if cycleEdges[[2]string{edge.From, edge.To}] {
+			fmt.Fprintf(&b, "\tlinkStyle %d stroke:red\n", i)
+		}
+	}
+
+	_, err = io.WriteString(w, b.String())
+
+	return err
+}
@@ -0,0 +1,396 @@
+// Package hook runs gogit's logic for the git hooks.
+//
+// Installed hooks only call "gogit hook run <name>", so upgrading gogit changes
+// what the hooks do in every clone without reinstalling them.
+package hook
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog/v2"
+
+	"aduu.dev/tools/gogit/config"
+	"aduu.dev/tools/gogit/overlay"
+	"aduu.dev/tools/gogit/profile"
+	"aduu.dev/tools/gogit/replace"
+	"aduu.dev/tools/gogit/verify"
+	"aduu.dev/tools/gogit/workspace"
+)
+
+// Environment variables controlling Run, see OptionsFromEnv.
+const (
+	// SkipEnv skips every hook if set to 1 or true, else the hooks in its comma separated list.
+	SkipEnv = "GOGIT_SKIP"
+	// DryRunEnv prints the steps of a hook instead of running them if set to 1 or true.
+	DryRunEnv = "GOGIT_DRYRUN"
+	// VerboseEnv prints each step before running it if set to anything but 0.
+	// A number also sets the log verbosity.
+	VerboseEnv = "GOGIT_VERBOSE"
+)
+
+// Environment variables the pre-commit framework passes the arguments of post-checkout in, see ArgsFromEnv.
+const (
+	preCommitFromRefEnv      = "PRE_COMMIT_FROM_REF"
+	preCommitToRefEnv        = "PRE_COMMIT_TO_REF"
+	preCommitCheckoutTypeEnv = "PRE_COMMIT_CHECKOUT_TYPE"
+)
+
+// Hook names Run supports.
+const (
+	PreCommit    = "pre-commit"
+	CommitMsg    = "commit-msg"
+	PostCommit   = "post-commit"
+	PostCheckout = "post-checkout"
+	PostMerge    = "post-merge"
+)
+
+// skipAll in Options.Skip skips every hook.
+const skipAll = "all"
+
+var (
+	errUnknownHook          = fmt.Errorf("unknown hook")
+	errMissingHookArgs      = fmt.Errorf("missing hook arguments")
+	errDependentsDoNotBuild = fmt.Errorf("dependents do not build")
+)
+
+// Names returns the hooks Run supports in the order git runs them.
+func Names() []string {
+	return []string{PreCommit, CommitMsg, PostCommit, PostCheckout, PostMerge}
+}
+
+// Options configures Run.
+type Options struct {
+	// Skip are the names of the hooks which do nothing, "all" skips every hook.
+	Skip []string
+	// DryRun prints the steps of the hook instead of running them.
+	DryRun bool
+	// Verbose prints each step before running it.
+	Verbose bool
+	// Out receives the printed steps, os.Stderr if nil.
+	Out io.Writer
+}
+
+// OptionsFromEnv returns the options set by SkipEnv, DryRunEnv and VerboseEnv.
+func OptionsFromEnv() Options {
+	opts := Options{
+		DryRun: isTrue(os.Getenv(DryRunEnv)),
+		Out:    os.Stderr,
+	}
+
+	switch skip := os.Getenv(SkipEnv); {
+	case isTrue(skip):
+		opts.Skip = []string{skipAll}
+	case len(skip) != 0:
+		for _, name := range strings.Split(skip, ",") {
+			opts.Skip = append(opts.Skip, strings.TrimSpace(name))
+		}
+	}
+
+	verbose := os.Getenv(VerboseEnv)
+	opts.Verbose = len(verbose) != 0 && verbose != "0"
+
+	return opts
+}
+
+// ArgsFromEnv returns args, or the arguments git passed to the hook name if the pre-commit framework
+// passes them in environment variables instead, which it does for post-checkout.
+func ArgsFromEnv(name string, args []string) []string {
+	if len(args) != 0 || name != PostCheckout {
+		return args
+	}
+
+	from, ok := os.LookupEnv(preCommitFromRefEnv)
+	if !ok {
+		return args
+	}
+
+	return []string{from, os.Getenv(preCommitToRefEnv), os.Getenv(preCommitCheckoutTypeEnv)}
+}
+
+func isTrue(s string) bool {
+	b, err := strconv.ParseBool(s)
+	return err == nil && b
+}
+
+func (opts Options) skips(name string) bool {
+	for _, skip := range opts.Skip {
+		if skip == skipAll || skip == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// step is one action of a hook, described by the equivalent gogit command.
+type step struct {
+	command string
+	run     func() error
+}
+
+// Run runs the hook name for the repository in base with the arguments git passed to the hook.
+//
+// What the hook does is decided by the repository's configuration, see config.Load.
+func Run(base string, name string, args []string, opts Options) (err error) {
+	if opts.skips(name) {
+		klog.InfoS("Skipping hook", "hook", name, "because", SkipEnv)
+		return nil
+	}
+
+	if opts.Out == nil {
+		opts.Out = os.Stderr
+	}
+
+	cfg, err := config.Load(base)
+	if err != nil {
+		return
+	}
+
+	steps, err := plan(base, name, args, cfg)
+	if err != nil {
+		return
+	}
+
+	for _, s := range steps {
+		if opts.Verbose || opts.DryRun {
+			fmt.Fprintf(opts.Out, "gogit %s: %s\n", name, s.command)
+		}
+
+		if opts.DryRun {
+			continue
+		}
+
+		if err = s.run(); err != nil {
+			return fmt.Errorf("%s hook failed at %#v: %w", name, s.command, err)
+		}
+	}
+
+	return nil
+}
+
+// plan returns the steps of the hook for each configured module.
+func plan(base string, name string, args []string, cfg config.Config) (steps []step, err error) {
+	// The trailers and the note of all modules replace the existing ones at once.
+	switch name {
+	case CommitMsg:
+		return commitMsg(base, cfg.ModuleDirs(), args)
+	case PostCommit:
+		return postCommit(base, cfg.ModuleDirs(), cfg), nil
+	}
+
+	for _, module := range cfg.ModuleDirs() {
+		dir := filepath.Join(base, module)
+
+		var moduleSteps []step
+
+		switch name {
+		case PreCommit:
+			moduleSteps, err = preCommit(dir, module, cfg)
+		case PostCheckout:
+			moduleSteps, err = postCheckout(dir, module, args, cfg)
+		case PostMerge:
+			moduleSteps = postMerge(dir, module, cfg)
+		default:
+			return nil, fmt.Errorf("%w %#v: supported are %s", errUnknownHook, name, strings.Join(Names(), ", "))
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		steps = append(steps, moduleSteps...)
+	}
+
+	return steps, nil
+}
+
+func modeFlag(cfg config.Config) string {
+	if cfg.Mode == config.ModeCommentOut {
+		return " --comment-out"
+	}
+
+	return ""
+}
+
+// preCommit recovers from an aborted commit and runs the checks before stripping go.mod,
+// so a failed check does not leave a stripped go.mod behind.
+func preCommit(dir string, module string, cfg config.Config) (steps []step, err error) {
+	aborted, err := replace.HasBackup(dir)
+	if err != nil {
+		return
+	}
+
+	if aborted {
+		steps = append(steps, step{
+			command: "replace --undo " + module + " (restoring go.mod of an aborted commit)",
+			run: func() error {
+				return replace.UndoRemovingLocalReplacesFromGomod(dir, false)
+			},
+		})
+	}
+
+	if config.IsOn(cfg.VerifyStaged) {
+		opts := verify.Options{Vet: config.IsOn(cfg.Vet)}
+		command := "verify-staged "
+		if opts.Vet {
+			command += "--vet "
+		}
+
+		steps = append(steps, step{
+			command: command + module,
+			run: func() error {
+				return verify.Staged(dir, opts)
+			},
+		})
+	}
+
+	if config.IsOn(cfg.Dependents) {
+		steps = append(steps, step{
+			command: "dependents --build " + module,
+			run: func() error {
+				return buildDependents(dir)
+			},
+		})
+	}
+
+	return append(steps, step{
+		command: "replace --replace-only-if-staged" + modeFlag(cfg) + " " + module,
+		run: func() (err error) {
+			if cfg.Mode == config.ModeCommentOut {
+				_, err = replace.DisableLocalReplaces(dir, true)
+				return err
+			}
+
+			return replace.RemoveLocalReplacesFromGomod(dir, true)
+		},
+	}), nil
+}
+
+func buildDependents(dir string) (err error) {
+	roots, err := workspace.Roots(dir, nil)
+	if err != nil {
+		return
+	}
+
+	dependents, err := workspace.Dependents(dir, roots)
+	if err != nil {
+		return
+	}
+
+	if workspace.Check(dependents) {
+		return nil
+	}
+
+	var failed []string
+
+	for _, dependent := range dependents {
+		if dependent.BuildErr != nil {
+			klog.ErrorS(dependent.BuildErr, "Dependent does not build", "module", dependent.Path, "dir", dependent.Dir)
+			failed = append(failed, dependent.Path)
+		}
+	}
+
+	return fmt.Errorf("%w: %s", errDependentsDoNotBuild, strings.Join(failed, ", "))
+}
+
+// moduleDirs returns the directories of the modules below base.
+func moduleDirs(base string, modules []string) (dirs []string) {
+	dirs = make([]string, 0, len(modules))
+	for _, module := range modules {
+		dirs = append(dirs, filepath.Join(base, module))
+	}
+
+	return dirs
+}
+
+func commitMsg(base string, modules []string, args []string) (steps []step, err error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("%w: %s needs the commit message file", errMissingHookArgs, CommitMsg)
+	}
+
+	messageFile := args[0]
+	dirs := moduleDirs(base, modules)
+
+	return []step{{
+		command: "commit-msg " + strings.Join(modules, " ") + " " + messageFile,
+		run: func() error {
+			return replace.AddModulesLocalReplaceTrailers(dirs, messageFile)
+		},
+	}}, nil
+}
+
+func postCommit(base string, modules []string, cfg config.Config) (steps []step) {
+	return []step{{
+		command: "replace --replace-only-if-staged --undo --note" + modeFlag(cfg) + " " + strings.Join(modules, " "),
+		run: func() (err error) {
+			dirs := moduleDirs(base, modules)
+
+			if err = replace.WriteModulesLocalReplacesNote(dirs); err != nil {
+				return
+			}
+
+			for _, dir := range dirs {
+				if cfg.Mode == config.ModeCommentOut {
+					_, err = replace.EnableLocalReplaces(dir)
+				} else {
+					err = replace.UndoRemovingLocalReplacesFromGomod(dir, true)
+				}
+
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}}
+}
+
+// postCheckout switches the profile before syncing the overlay because it may change go.mod.
+func postCheckout(dir string, module string, args []string, cfg config.Config) (steps []step, err error) {
+	if len(args) < 3 {
+		return nil, fmt.Errorf("%w: %s needs the previous head, the new head and the branch flag", errMissingHookArgs, PostCheckout)
+	}
+
+	if config.IsOn(cfg.Profiles) {
+		steps = append(steps, step{
+			command: "profile post-checkout " + module + " " + strings.Join(args[:3], " "),
+			run: func() error {
+				return profile.PostCheckout(dir, args[0], args[1], args[2])
+			},
+		})
+	}
+
+	if config.IsOn(cfg.Overlay) {
+		steps = append(steps, overlaySync(dir, module))
+	}
+
+	return steps, nil
+}
+
+func postMerge(dir string, module string, cfg config.Config) (steps []step) {
+	if config.IsOn(cfg.Overlay) {
+		steps = append(steps, overlaySync(dir, module))
+	}
+
+	return steps
+}
+
+func overlaySync(dir string, module string) step {
+	return step{
+		command: "overlay sync --if-exists " + module,
+		run: func() error {
+			exists, err := overlay.Exists(dir)
+			if err != nil || !exists {
+				return err
+			}
+
+			return overlay.Sync(dir)
+		},
+	}
+}
@@ -0,0 +1,301 @@
+package hook
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/mod/module"
+
+	"aduu.dev/tools/gogit/config"
+	"aduu.dev/tools/gogit/replace"
+)
+
+func repoTempDir(t *testing.T) (base string) {
+	base, err := ioutil.TempDir("", "gogit-hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(base); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if err = os.MkdirAll(filepath.Join(base, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	return base
+}
+
+func TestRun_dry_run(t *testing.T) {
+	tests := []struct {
+		name   string
+		hook   string
+		args   []string
+		config string
+		backup bool
+		want   string
+	}{
+		{
+			name: "pre-commit: default",
+			hook: PreCommit,
+			want: "gogit pre-commit: replace --replace-only-if-staged .\n",
+		},
+		{
+			name:   "pre-commit: checks before stripping each module, recovering an aborted commit",
+			hook:   PreCommit,
+			config: `{"modules": [".", "api"], "verifyStaged": true, "dependents": true, "mode": "comment-out"}`,
+			backup: true,
+			want: `gogit pre-commit: replace --undo . (restoring go.mod of an aborted commit)
+gogit pre-commit: verify-staged .
+gogit pre-commit: dependents --build .
+gogit pre-commit: replace --replace-only-if-staged --comment-out .
+gogit pre-commit: verify-staged api
+gogit pre-commit: dependents --build api
+gogit pre-commit: replace --replace-only-if-staged --comment-out api
+`,
+		},
+		{
+			name:   "pre-commit: verifying with go vet",
+			hook:   PreCommit,
+			config: `{"verifyStaged": true, "vet": true}`,
+			want: `gogit pre-commit: verify-staged --vet .
+gogit pre-commit: replace --replace-only-if-staged .
+`,
+		},
+		{
+			name: "commit-msg",
+			hook: CommitMsg,
+			args: []string{".git/COMMIT_EDITMSG"},
+			want: "gogit commit-msg: commit-msg . .git/COMMIT_EDITMSG\n",
+		},
+		{
+			name:   "commit-msg: trailers of all modules at once",
+			hook:   CommitMsg,
+			args:   []string{".git/COMMIT_EDITMSG"},
+			config: `{"modules": [".", "api"]}`,
+			want:   "gogit commit-msg: commit-msg . api .git/COMMIT_EDITMSG\n",
+		},
+		{
+			name: "post-commit",
+			hook: PostCommit,
+			want: "gogit post-commit: replace --replace-only-if-staged --undo --note .\n",
+		},
+		{
+			name: "post-checkout: nothing enabled",
+			hook: PostCheckout,
+			args: []string{"a", "b", "1"},
+		},
+		{
+			name:   "post-checkout: profiles before overlay",
+			hook:   PostCheckout,
+			args:   []string{"a", "b", "1"},
+			config: `{"profiles": true, "overlay": true}`,
+			want: `gogit post-checkout: profile post-checkout . a b 1
+gogit post-checkout: overlay sync --if-exists .
+`,
+		},
+		{
+			name:   "post-merge",
+			hook:   PostMerge,
+			config: `{"overlay": true}`,
+			want:   "gogit post-merge: overlay sync --if-exists .\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := repoTempDir(t)
+
+			if len(tt.config) != 0 {
+				if err := ioutil.WriteFile(config.Filepath(base), []byte(tt.config), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if tt.backup {
+				if err := ioutil.WriteFile(filepath.Join(base, "go.mod.b"), nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var out bytes.Buffer
+			if err := Run(base, tt.hook, tt.args, Options{DryRun: true, Out: &out}); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.want, out.String())
+		})
+	}
+}
+
+func TestRun_post_commit_modules(t *testing.T) {
+	base := repoTempDir(t)
+
+	r, err := git.PlainInit(base, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modules := map[string]string{".": "aduu.dev/k", "api": "aduu.dev/k/api"}
+	targets := map[string]string{".": "../utils", "api": "../../utils"}
+
+	for dir, modulePath := range modules {
+		stripped := "module " + modulePath + "\n\ngo 1.14\n\nrequire aduu.dev/utils v1.0.0\n"
+		gomod := filepath.Join(base, dir, "go.mod")
+
+		if err = os.MkdirAll(filepath.Dir(gomod), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = ioutil.WriteFile(gomod, []byte(stripped), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = w.Add(filepath.ToSlash(filepath.Join(dir, "go.mod"))); err != nil {
+			t.Fatal(err)
+		}
+
+		// pre-commit stripped the local replace into the backup.
+		backup := stripped + "\nreplace aduu.dev/utils => " + targets[dir] + "\n"
+		if err = ioutil.WriteFile(gomod+".b", []byte(backup), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err = w.Commit("add modules", &git.CommitOptions{
+		Author: &object.Signature{Name: "gogit", Email: "gogit@aduu.dev"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ioutil.WriteFile(config.Filepath(base), []byte(`{"modules": [".", "api"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = Run(base, PostCommit, nil, Options{Out: ioutil.Discard}); err != nil {
+		t.Fatal(err)
+	}
+
+	for dir := range modules {
+		got, err := replace.ReadLocalReplacesNote(filepath.Join(base, dir), "HEAD")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := []replace.LocalReplace{{Old: module.Version{Path: "aduu.dev/utils"}, New: targets[dir]}}
+		assert.Equal(t, want, got, "the note should list the local replaces of module %#v", dir)
+		assert.NoFileExists(t, filepath.Join(base, dir, "go.mod.b"), "go.mod should be restored")
+	}
+}
+
+func TestRun_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		hook    string
+		args    []string
+		wantErr error
+	}{
+		{name: "unknown hook", hook: "pre-push", wantErr: errUnknownHook},
+		{name: "commit-msg without message file", hook: CommitMsg, wantErr: errMissingHookArgs},
+		{name: "post-checkout without heads", hook: PostCheckout, args: []string{"a"}, wantErr: errMissingHookArgs},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Run(repoTempDir(t), tt.hook, tt.args, Options{DryRun: true, Out: ioutil.Discard})
+			assert.True(t, errors.Is(err, tt.wantErr), "got %v", err)
+		})
+	}
+}
+
+func TestRun_skip(t *testing.T) {
+	var out bytes.Buffer
+
+	// An unknown hook would fail if it was not skipped.
+	err := Run(repoTempDir(t), "pre-push", nil, Options{Skip: []string{"pre-push"}, DryRun: true, Out: &out})
+	assert.NoError(t, err)
+	assert.Empty(t, out.String())
+}
+
+func TestOptionsFromEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want Options
+	}{
+		{
+			name: "nothing set",
+			want: Options{Out: os.Stderr},
+		},
+		{
+			name: "skip all, dry run and verbose",
+			env:  map[string]string{SkipEnv: "1", DryRunEnv: "true", VerboseEnv: "2"},
+			want: Options{Skip: []string{skipAll}, DryRun: true, Verbose: true, Out: os.Stderr},
+		},
+		{
+			name: "skip some, not verbose",
+			env:  map[string]string{SkipEnv: "pre-commit, post-commit", DryRunEnv: "0", VerboseEnv: "0"},
+			want: Options{Skip: []string{PreCommit, PostCommit}, Out: os.Stderr},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{SkipEnv, DryRunEnv, VerboseEnv} {
+				previous, set := os.LookupEnv(name)
+
+				if err := os.Setenv(name, tt.env[name]); err != nil {
+					t.Fatal(err)
+				}
+
+				t.Cleanup(func() {
+					if set {
+						_ = os.Setenv(name, previous)
+					} else {
+						_ = os.Unsetenv(name)
+					}
+				})
+			}
+
+			assert.Equal(t, tt.want, OptionsFromEnv())
+		})
+	}
+}
+
+func TestArgsFromEnv(t *testing.T) {
+	for name, value := range map[string]string{preCommitFromRefEnv: "a1", preCommitToRefEnv: "b2", preCommitCheckoutTypeEnv: "1"} {
+		name := name
+		previous, set := os.LookupEnv(name)
+
+		if err := os.Setenv(name, value); err != nil {
+			t.Fatal(err)
+		}
+
+		t.Cleanup(func() {
+			if set {
+				_ = os.Setenv(name, previous)
+			} else {
+				_ = os.Unsetenv(name)
+			}
+		})
+	}
+
+	assert.Equal(t, []string{"a1", "b2", "1"}, ArgsFromEnv(PostCheckout, nil), "the pre-commit framework passes them in the environment")
+	assert.Equal(t, []string{"x", "y", "0"}, ArgsFromEnv(PostCheckout, []string{"x", "y", "0"}), "arguments passed by git win")
+	assert.Empty(t, ArgsFromEnv(PostMerge, nil), "only post-checkout takes them from the environment")
+}
@@ -94,7 +94,7 @@ done:
 // addToShellFile adds the given line with the given comment to the file.
 //
 // If there is a line with the given comment already it replaces the line's content
-// with the given line + comment.
+// with the given line + comment. Otherwise the line is inserted before the first top-level exit or exec.
 // It ignores further lines with the same comment.
 func addToShellFile(content string, line string, comment string) (changedContent string, err error) {
 	lines := strings.Split(content, "\n")
@@ -120,6 +120,13 @@ func addToShellFile(content string, line string, comment string) (changedContent
 		return
 	}
 
+	// Add the line before a top-level exit or exec, else it would never run.
+	if terminator := analyzeHook(content).Terminator; len(matchedLines) == 0 && terminator >= 0 {
+		lines = append(lines[:terminator], append([]string{combinedLine(line, comment)}, lines[terminator:]...)...)
+
+		return strings.Join(lines, "\n"), nil
+	}
+
 	// Add the line at the back.
 	if len(matchedLines) == 0 {
 		lines = append(lines, combinedLine(line, comment))
@@ -0,0 +1,209 @@
+package install
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// posixShells are the interpreters which can run gogit's hook line.
+var posixShells = map[string]bool{
+	"sh":   true,
+	"bash": true,
+	"dash": true,
+	"ash":  true,
+	"ksh":  true,
+	"mksh": true,
+	"zsh":  true,
+}
+
+var (
+	heredocStart = regexp.MustCompile(`<<-?\s*['"]?([A-Za-z_][A-Za-z0-9_]*)['"]?`)
+	redirection  = regexp.MustCompile(`^[0-9]*(>>?|<)(&[0-9-]|.*)$`)
+)
+
+// hookAnalysis describes an existing hook file.
+type hookAnalysis struct {
+	// Interpreter is the program named by the shebang, e.g. bash or python3.
+	// Without shebang the hook is run by sh.
+	Interpreter string
+	// Shell is true if the interpreter is a POSIX shell.
+	Shell bool
+	// Terminator is the index of the first line which ends the script at the top level,
+	// an exit or an exec replacing the shell, -1 if there is none.
+	Terminator int
+}
+
+// analyzeHook analyzes the content of an existing hook file.
+func analyzeHook(content string) hookAnalysis {
+	lines := strings.Split(content, "\n")
+
+	analysis := hookAnalysis{
+		Interpreter: shebangInterpreter(lines[0]),
+		Terminator:  -1,
+	}
+
+	if len(analysis.Interpreter) == 0 {
+		analysis.Interpreter = "sh"
+	}
+
+	analysis.Shell = posixShells[analysis.Interpreter]
+
+	if analysis.Shell {
+		analysis.Terminator = topLevelTerminator(lines)
+	}
+
+	return analysis
+}
+
+// shebangInterpreter returns the base name of the interpreter of the shebang line, also behind env.
+func shebangInterpreter(line string) string {
+	if !strings.HasPrefix(line, "#!") {
+		return ""
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) == 0 {
+		return ""
+	}
+
+	if filepath.Base(fields[0]) != "env" {
+		return filepath.Base(fields[0])
+	}
+
+	// Skip options like -S and variable assignments of env.
+	for _, field := range fields[1:] {
+		if strings.HasPrefix(field, "-") || strings.Contains(field, "=") {
+			continue
+		}
+
+		return filepath.Base(field)
+	}
+
+	return ""
+}
+
+// topLevelTerminator returns the index of the first top-level exit or exec line, -1 if there is none.
+// A top-level if with an else whose branches all end with exit or exec counts as terminator as well,
+// like the hooks written by the pre-commit framework.
+//
+// Nesting is tracked by the shell keywords and braces, here-documents and continued lines are skipped.
+// It is a heuristic for the usual hook scripts, not a shell parser.
+func topLevelTerminator(lines []string) int {
+	depth := 0
+	heredoc := ""
+	continued := false
+
+	// The top-level if block being read.
+	ifStart := -1
+	hasElse := false
+	branchesTerminate := false
+	lastTerminates := false
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		if len(heredoc) != 0 {
+			if trimmed == heredoc {
+				heredoc = ""
+			}
+
+			continue
+		}
+
+		wasContinued := continued
+		continued = strings.HasSuffix(trimmed, "\\")
+
+		code := stripShellComment(trimmed)
+
+		if match := heredocStart.FindStringSubmatch(code); match != nil {
+			heredoc = match[1]
+		}
+
+		words := strings.FieldsFunc(code, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == ';' || r == '&' || r == '|' || r == '(' || r == ')'
+		})
+
+		if len(words) == 0 || wasContinued {
+			continue
+		}
+
+		// Redirections like 1>&2 must stay whole to tell them from commands.
+		terminates := isTerminator(strings.Fields(code))
+
+		switch {
+		case depth == 0 && terminates:
+			return i
+		case depth == 0 && words[0] == "if":
+			ifStart, hasElse, branchesTerminate, lastTerminates = i, false, true, false
+		case depth == 1 && ifStart >= 0:
+			switch words[0] {
+			case "elif", "else":
+				branchesTerminate = branchesTerminate && lastTerminates
+				hasElse = hasElse || words[0] == "else"
+				lastTerminates = false
+			case "fi":
+				if hasElse && branchesTerminate && lastTerminates {
+					return ifStart
+				}
+
+				ifStart = -1
+			case "then":
+			default:
+				lastTerminates = terminates
+			}
+		case depth > 1:
+			// A nested block as last command of a branch is not followed.
+			lastTerminates = false
+		}
+
+		for _, word := range words {
+			switch word {
+			case "if", "case", "for", "while", "until", "select", "{":
+				depth++
+			case "fi", "esac", "done", "}":
+				depth--
+			}
+		}
+
+		if depth < 0 {
+			depth = 0
+		}
+
+		// A one-line if is complete already.
+		if depth == 0 && ifStart == i {
+			ifStart = -1
+		}
+	}
+
+	return -1
+}
+
+// isTerminator returns true for exit and for exec running a command, exec only redirecting continues the script.
+func isTerminator(fields []string) bool {
+	switch strings.TrimRight(fields[0], ";") {
+	case "exit":
+		return true
+	case "exec":
+		for _, field := range fields[1:] {
+			if !redirection.MatchString(strings.TrimRight(field, ";")) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// stripShellComment removes a trailing comment, which starts with a # at the beginning of a word.
+func stripShellComment(line string) string {
+	if strings.HasPrefix(line, "#") {
+		return ""
+	}
+
+	if i := strings.Index(line, " #"); i >= 0 {
+		return line[:i]
+	}
+
+	return line
+}
@@ -0,0 +1,210 @@
+package install
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Hook samples as written by git and common hook managers, shortened to their structure.
+const (
+	gitSamplePreCommit = `#!/bin/sh
+#
+# An example hook script to verify what is about to be committed.
+
+if git rev-parse --verify HEAD >/dev/null 2>&1
+then
+	against=HEAD
+else
+	# Initial commit: diff against an empty tree object
+	against=$(git hash-object -t tree /dev/null)
+fi
+
+# Redirect output to stderr.
+exec 1>&2
+
+if [ "$allownonascii" != "true" ]
+then
+	cat <<\EOF
+Error: Attempt to add a non-ASCII file name.
+exit 1
+EOF
+	exit 1
+fi
+
+# If there are whitespace errors, print the offending file names and fail.
+exec git diff-index --check --cached $against --`
+
+	preCommitFramework = `#!/usr/bin/env bash
+# File generated by pre-commit: https://pre-commit.com
+INSTALL_PYTHON=/usr/bin/python3
+ARGS=(hook-impl --config=.pre-commit-config.yaml --hook-type=pre-commit)
+# end templated
+
+HERE="$(cd "$(dirname "$0")" && pwd)"
+ARGS+=(--hook-dir "$HERE" -- "$@")
+
+if [ -x "$INSTALL_PYTHON" ]; then
+    exec "$INSTALL_PYTHON" -mpre_commit "${ARGS[@]}"
+elif command -v pre-commit > /dev/null; then
+    exec pre-commit "${ARGS[@]}"
+else
+    echo 'pre-commit not found.  Did you forget to activate your virtualenv?' 1>&2
+    exit 1
+fi`
+
+	lefthook = `#!/bin/sh
+
+if [ "$LEFTHOOK" = "0" ]; then
+  exit 0
+fi
+
+call_lefthook()
+{
+  if test -n "$LEFTHOOK_BIN"
+  then
+    "$LEFTHOOK_BIN" "$@"
+  else
+    echo "Can't find lefthook in PATH"
+    exit 1
+  fi
+}
+
+call_lefthook run "pre-commit" "$@"`
+
+	husky = `#!/usr/bin/env sh
+. "$(dirname -- "$0")/_/husky.sh"
+
+npx lint-staged`
+
+	nodeHook = `#!/usr/bin/env node
+const { execSync } = require("child_process");
+process.exit(0);`
+
+	pythonHook = `#!/usr/bin/python3
+import sys
+sys.exit(0)`
+
+	exitAfterContinuation = `#!/bin/bash
+go vet ./... \
+  exit
+make lint || exit 1
+exit 0`
+)
+
+func Test_analyzeHook(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    hookAnalysis
+	}{
+		{
+			name:    "git sample: insert before exec, not before exec redirecting or exit in if or heredoc",
+			content: gitSamplePreCommit,
+			want:    hookAnalysis{Interpreter: "sh", Shell: true, Terminator: 25},
+		},
+		{
+			name:    "pre-commit framework: every branch of the final if ends the script",
+			content: preCommitFramework,
+			want:    hookAnalysis{Interpreter: "bash", Shell: true, Terminator: 9},
+		},
+		{
+			name:    "lefthook: exits only nested",
+			content: lefthook,
+			want:    hookAnalysis{Interpreter: "sh", Shell: true, Terminator: -1},
+		},
+		{
+			name:    "husky: env shebang",
+			content: husky,
+			want:    hookAnalysis{Interpreter: "sh", Shell: true, Terminator: -1},
+		},
+		{
+			name:    "node",
+			content: nodeHook,
+			want:    hookAnalysis{Interpreter: "node", Shell: false, Terminator: -1},
+		},
+		{
+			name:    "python",
+			content: pythonHook,
+			want:    hookAnalysis{Interpreter: "python3", Shell: false, Terminator: -1},
+		},
+		{
+			name:    "no shebang runs with sh",
+			content: "echo hi\nexit 0\n",
+			want:    hookAnalysis{Interpreter: "sh", Shell: true, Terminator: 1},
+		},
+		{
+			name:    "env with options",
+			content: "#!/usr/bin/env -S bash -e\ntrue",
+			want:    hookAnalysis{Interpreter: "bash", Shell: true, Terminator: -1},
+		},
+		{
+			name:    "continued lines and conditional exits are no terminator",
+			content: exitAfterContinuation,
+			want:    hookAnalysis{Interpreter: "bash", Shell: true, Terminator: 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, analyzeHook(tt.content))
+		})
+	}
+}
+
+func Test_installLine_existing_hooks(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		wantAction string
+		wantErr    error
+		want       string
+	}{
+		{
+			name:       "insert before exec",
+			content:    "#!/bin/sh\nexec 1>&2\nexec git diff-index --check --cached HEAD --\n",
+			wantAction: `inserted before line 3 "exec git diff-index --check --cached HEAD --"`,
+			want:       "#!/bin/sh\nexec 1>&2\n" + block("gogit x") + "\nexec git diff-index --check --cached HEAD --\n",
+		},
+		{
+			name:       "append",
+			content:    "#!/bin/sh\nnpx lint-staged",
+			wantAction: "appended",
+			want:       "#!/bin/sh\nnpx lint-staged\n\n" + block("gogit x"),
+		},
+		{
+			name:       "replace legacy line",
+			content:    "#!/bin/sh\ngogit old # " + defaultBashComment + "\nexit 0",
+			wantAction: "replaced legacy line",
+			want:       "#!/bin/sh\n" + block("gogit x") + "\nexit 0",
+		},
+		{
+			name:    "refuse python",
+			content: pythonHook,
+			wantErr: errNotShellHook,
+			want:    pythonHook,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hookFile := filepath.Join(hooksTempDir(t), hooksPath(), "pre-commit")
+			if err := ioutil.WriteFile(hookFile, []byte(tt.content), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			report, err := installLine(hookFile, []string{"gogit x"}, Options{Shell: ShellBash})
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), "got %v", err)
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.wantAction, report.Action)
+			fileHasContent(t, hookFile, tt.want, "")
+		})
+	}
+}
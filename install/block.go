@@ -0,0 +1,348 @@
+package install
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"aduu.dev/tools/gogit/version"
+)
+
+// A managed block holds the lines gogit owns in a hook file:
+//
+//	# >>> gogit >>> format=2 version=v0.4.0 sha256:<checksum of the body>
+//	gogit hook run pre-commit "$@"
+//	# <<< gogit <<<
+//
+// The format and the version of the gogit binary which wrote the block tell upgrade-hooks what it has to rewrite.
+// The checksum detects hand edits, which are only overwritten when repairing.
+const (
+	blockBeginMarker = ">>> gogit >>>"
+	blockEndMarker   = "<<< gogit <<<"
+	checksumPrefix   = "sha256:"
+	formatPrefix     = "format="
+	versionPrefix    = "version="
+)
+
+// Formats of gogit's lines in hook files.
+const (
+	// legacyFormat are single lines marked with defaultBashComment.
+	legacyFormat = 0
+	// checksumFormat are blocks recording only the checksum.
+	checksumFormat = 1
+	// versionedFormat are blocks also recording the format and the version of gogit.
+	versionedFormat = 2
+
+	// currentFormat is the format gogit writes.
+	currentFormat = versionedFormat
+)
+
+var (
+	errBlockDrifted      = fmt.Errorf("gogit block was edited by hand")
+	errBlockUnterminated = fmt.Errorf("gogit block has no end marker")
+	errBlockNested       = fmt.Errorf("gogit block starts inside another gogit block")
+
+	blockBeginRegexp = regexp.MustCompile(`^\s*#\s*` + regexp.QuoteMeta(blockBeginMarker) + `(.*)$`)
+	blockEndRegexp   = regexp.MustCompile(`^\s*#\s*` + regexp.QuoteMeta(blockEndMarker) + `\s*$`)
+)
+
+// managedBlock is a block found in a hook file.
+type managedBlock struct {
+	// begin and end are the indexes of the marker lines.
+	begin int
+	end   int
+	// checksum, format and version are recorded in the begin marker.
+	checksum string
+	format   int
+	version  string
+	body     []string
+}
+
+// parseBlockHeader returns the fields recorded in the begin marker after blockBeginMarker.
+// Blocks without format field were written in checksumFormat.
+func parseBlockHeader(header string) (checksum string, format int, gogitVersion string) {
+	format = checksumFormat
+
+	for _, field := range strings.Fields(header) {
+		switch {
+		case strings.HasPrefix(field, checksumPrefix):
+			checksum = field
+		case strings.HasPrefix(field, formatPrefix):
+			if f, err := strconv.Atoi(strings.TrimPrefix(field, formatPrefix)); err == nil {
+				format = f
+			}
+		case strings.HasPrefix(field, versionPrefix):
+			gogitVersion = strings.TrimPrefix(field, versionPrefix)
+		}
+	}
+
+	return checksum, format, gogitVersion
+}
+
+// current returns true if the block was written in the current format by this gogit binary.
+func (b managedBlock) current() bool {
+	return b.format == currentFormat && b.version == version.Get()
+}
+
+// drifted returns true if the body does not match the recorded checksum anymore.
+func (b managedBlock) drifted() bool {
+	return b.checksum != blockChecksum(b.body)
+}
+
+func blockChecksum(body []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(body, "\n")))
+	return checksumPrefix + hex.EncodeToString(sum[:8])
+}
+
+// blockLines returns the body wrapped in begin and end markers of the current format.
+func blockLines(body []string) []string {
+	lines := make([]string, 0, len(body)+2)
+	lines = append(lines, fmt.Sprintf("# %s %s%d %s%s %s",
+		blockBeginMarker, formatPrefix, currentFormat, versionPrefix, version.Get(), blockChecksum(body)))
+	lines = append(lines, body...)
+
+	return append(lines, "# "+blockEndMarker)
+}
+
+// findBlocks returns the managed blocks in lines in order.
+func findBlocks(lines []string) (blocks []managedBlock, err error) {
+	current := -1
+
+	for i, l := range lines {
+		switch {
+		case blockBeginRegexp.MatchString(l):
+			if current >= 0 {
+				return nil, fmt.Errorf("%w: line %d", errBlockNested, i+1)
+			}
+
+			current = i
+		case blockEndRegexp.MatchString(l) && current >= 0:
+			b := managedBlock{
+				begin: current,
+				end:   i,
+				body:  append([]string(nil), lines[current+1:i]...),
+			}
+			b.checksum, b.format, b.version = parseBlockHeader(blockBeginRegexp.FindStringSubmatch(lines[current])[1])
+
+			blocks = append(blocks, b)
+			current = -1
+		}
+	}
+
+	if current >= 0 {
+		return nil, fmt.Errorf("%w: line %d", errBlockUnterminated, current+1)
+	}
+
+	return blocks, nil
+}
+
+// legacyLines returns the indexes of single lines marked with defaultBashComment outside of blocks,
+// as written by earlier versions of gogit.
+func legacyLines(lines []string, blocks []managedBlock) (indexes []int, err error) {
+	matched, err := commentLineIndexes(lines, defaultBashComment)
+	if err != nil {
+		return
+	}
+
+	for _, i := range matched {
+		inBlock := false
+		for _, b := range blocks {
+			if i > b.begin && i < b.end {
+				inBlock = true
+			}
+		}
+
+		if !inBlock {
+			indexes = append(indexes, i)
+		}
+	}
+
+	return indexes, nil
+}
+
+// removeManaged removes the blocks and legacy lines from lines.
+// at is translated to the index it has after the removal.
+func removeManaged(lines []string, blocks []managedBlock, legacy []int, at int) (out []string, atOut int) {
+	remove := make(map[int]bool)
+	for _, b := range blocks {
+		for i := b.begin; i <= b.end; i++ {
+			remove[i] = true
+		}
+	}
+
+	for _, i := range legacy {
+		remove[i] = true
+	}
+
+	atOut = -1
+	for i, l := range lines {
+		if i == at {
+			atOut = len(out)
+		}
+
+		if !remove[i] {
+			out = append(out, l)
+		}
+	}
+
+	return out, atOut
+}
+
+func trimTrailingEmptyLines(lines []string) []string {
+	for len(lines) != 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// addBlock installs body as the managed block of content.
+//
+// An existing block or legacy line is replaced in place, further blocks and legacy lines are removed.
+// Otherwise the block is inserted before the first top-level exit or exec, or appended.
+// A block edited by hand is only overwritten with repair, else errBlockDrifted is returned.
+func addBlock(content string, body []string, repair bool) (changedContent string, action string, err error) {
+	lines := strings.Split(content, "\n")
+
+	blocks, err := findBlocks(lines)
+	if err != nil {
+		return
+	}
+
+	legacy, err := legacyLines(lines, blocks)
+	if err != nil {
+		return
+	}
+
+	drifted := false
+	for _, b := range blocks {
+		if b.drifted() {
+			drifted = true
+		}
+	}
+
+	if drifted && !repair {
+		return "", "", fmt.Errorf("%w, rerun with --repair to overwrite the edits", errBlockDrifted)
+	}
+
+	at := -1
+
+	switch {
+	case len(blocks) != 0:
+		at = blocks[0].begin
+
+		switch {
+		case drifted:
+			action = "repaired"
+		case strings.Join(blocks[0].body, "\n") == strings.Join(body, "\n") && blocks[0].current():
+			action = "unchanged"
+		default:
+			action = "updated"
+		}
+	case len(legacy) != 0:
+		at = legacy[0]
+		action = "replaced legacy line"
+	}
+
+	lines, at = removeManaged(lines, blocks, legacy, at)
+
+	if at < 0 {
+		if terminator := analyzeHook(strings.Join(lines, "\n")).Terminator; terminator >= 0 {
+			at = terminator
+			action = fmt.Sprintf("inserted before line %d %#v", terminator+1, strings.TrimSpace(lines[terminator]))
+		}
+	}
+
+	if at >= 0 {
+		lines = append(lines[:at], append(blockLines(body), lines[at:]...)...)
+	} else {
+		action = "appended"
+
+		// Keep one empty line between the existing content and the block.
+		lines = trimTrailingEmptyLines(lines)
+		if len(lines) != 0 {
+			lines = append(lines, "")
+		}
+
+		lines = append(lines, blockLines(body)...)
+	}
+
+	if duplicates := len(blocks) + len(legacy) - 1; duplicates > 0 {
+		action += fmt.Sprintf(", removed %d duplicate(s)", duplicates)
+	}
+
+	return strings.Join(lines, "\n"), action, nil
+}
+
+// removeBlocks removes all managed blocks and legacy lines from content.
+//
+// drifted is true if a removed block was edited by hand.
+func removeBlocks(content string) (changedContent string, drifted bool, err error) {
+	lines := strings.Split(content, "\n")
+
+	blocks, err := findBlocks(lines)
+	if err != nil {
+		return
+	}
+
+	legacy, err := legacyLines(lines, blocks)
+	if err != nil {
+		return
+	}
+
+	if len(blocks) == 0 && len(legacy) == 0 {
+		return content, false, nil
+	}
+
+	for _, b := range blocks {
+		if b.drifted() {
+			drifted = true
+		}
+	}
+
+	lines, _ = removeManaged(lines, blocks, legacy, -1)
+
+	return strings.Join(trimTrailingEmptyLines(lines), "\n"), drifted, nil
+}
+
+// ensureBlock installs body as the managed block of the hook file, see addBlock.
+func ensureBlock(file string, body []string, repair bool) (action string, err error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return
+	}
+
+	newContent, action, err := addBlock(string(content), body, repair)
+	if err != nil {
+		return "", fmt.Errorf("%#v: %w", file, err)
+	}
+
+	if newContent == string(content) {
+		return action, nil
+	}
+
+	return action, ioutil.WriteFile(file, []byte(newContent), 0755)
+}
+
+// ensureNoBlock removes the managed blocks and legacy lines from the hook file.
+func ensureNoBlock(file string) (drifted bool, err error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return
+	}
+
+	newContent, drifted, err := removeBlocks(string(content))
+	if err != nil {
+		return false, fmt.Errorf("%#v: %w", file, err)
+	}
+
+	if newContent == string(content) {
+		return drifted, nil
+	}
+
+	return drifted, ioutil.WriteFile(file, []byte(newContent), 0755)
+}
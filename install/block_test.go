@@ -0,0 +1,196 @@
+package install
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"aduu.dev/tools/gogit/version"
+)
+
+// block returns body as managed block.
+func block(body ...string) string {
+	return strings.Join(blockLines(body), "\n")
+}
+
+func Test_blockLines(t *testing.T) {
+	assert.Equal(t, `# >>> gogit >>> format=2 version=`+version.Get()+` sha256:88477fbf4e480176
+gogit x
+gogit y
+# <<< gogit <<<`, block("gogit x", "gogit y"))
+}
+
+func Test_parseBlockHeader(t *testing.T) {
+	tests := []struct {
+		name         string
+		header       string
+		wantChecksum string
+		wantFormat   int
+		wantVersion  string
+	}{
+		{
+			name:         "checksum only",
+			header:       " sha256:88477fbf4e480176",
+			wantChecksum: "sha256:88477fbf4e480176",
+			wantFormat:   checksumFormat,
+		},
+		{
+			name:         "versioned",
+			header:       " format=2 version=v0.4.0 sha256:88477fbf4e480176",
+			wantChecksum: "sha256:88477fbf4e480176",
+			wantFormat:   versionedFormat,
+			wantVersion:  "v0.4.0",
+		},
+		{
+			name:         "unknown fields of newer formats are ignored",
+			header:       " format=3 version=v1.0.0 shell=sh sha256:88477fbf4e480176",
+			wantChecksum: "sha256:88477fbf4e480176",
+			wantFormat:   3,
+			wantVersion:  "v1.0.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checksum, format, gogitVersion := parseBlockHeader(tt.header)
+			assert.Equal(t, tt.wantChecksum, checksum)
+			assert.Equal(t, tt.wantFormat, format)
+			assert.Equal(t, tt.wantVersion, gogitVersion)
+		})
+	}
+}
+
+func Test_addBlock(t *testing.T) {
+	edited := strings.Replace(block("gogit x"), "gogit x", "gogit x --verbose", 1)
+
+	tests := []struct {
+		name       string
+		content    string
+		repair     bool
+		wantAction string
+		want       string
+		wantErr    error
+	}{
+		{
+			name:       "empty file",
+			content:    "",
+			wantAction: "appended",
+			want:       block("gogit x", "gogit y"),
+		},
+		{
+			name:       "append after one empty line",
+			content:    "#!/bin/sh\necho hi\n\n\n",
+			wantAction: "appended",
+			want:       "#!/bin/sh\necho hi\n\n" + block("gogit x", "gogit y"),
+		},
+		{
+			name:       "update in place",
+			content:    "#!/bin/sh\n" + block("gogit old") + "\necho hi",
+			wantAction: "updated",
+			want:       "#!/bin/sh\n" + block("gogit x", "gogit y") + "\necho hi",
+		},
+		{
+			name:       "older format is updated",
+			content:    "#!/bin/sh\n# >>> gogit >>> sha256:88477fbf4e480176\ngogit x\ngogit y\n# <<< gogit <<<",
+			wantAction: "updated",
+			want:       "#!/bin/sh\n" + block("gogit x", "gogit y"),
+		},
+		{
+			name:       "unchanged",
+			content:    "#!/bin/sh\n" + block("gogit x", "gogit y"),
+			wantAction: "unchanged",
+			want:       "#!/bin/sh\n" + block("gogit x", "gogit y"),
+		},
+		{
+			name: "duplicates and legacy lines are removed",
+			content: "#!/bin/sh\ngogit legacy # " + defaultBashComment + "\n" + block("gogit old") + "\necho hi\n" +
+				block("gogit old") + "\nexit 0",
+			wantAction: "updated, removed 2 duplicate(s)",
+			want:       "#!/bin/sh\n" + block("gogit x", "gogit y") + "\necho hi\nexit 0",
+		},
+		{
+			name:    "edited by hand",
+			content: "#!/bin/sh\n" + edited,
+			wantErr: errBlockDrifted,
+		},
+		{
+			name:       "edited by hand: repair",
+			content:    "#!/bin/sh\n" + edited,
+			repair:     true,
+			wantAction: "repaired",
+			want:       "#!/bin/sh\n" + block("gogit x", "gogit y"),
+		},
+		{
+			name:    "unterminated block",
+			content: "#!/bin/sh\n# >>> gogit >>> sha256:00\ngogit x",
+			wantErr: errBlockUnterminated,
+		},
+		{
+			name:    "nested block",
+			content: "#!/bin/sh\n# >>> gogit >>> sha256:00\n" + block("gogit x"),
+			wantErr: errBlockNested,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, action, err := addBlock(tt.content, []string{"gogit x", "gogit y"}, tt.repair)
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), "got %v", err)
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.wantAction, action)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_removeBlocks(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		want        string
+		wantDrifted bool
+	}{
+		{
+			name:    "nothing to remove",
+			content: "#!/bin/sh\necho hi\n",
+			want:    "#!/bin/sh\necho hi\n",
+		},
+		{
+			name:    "block before exit",
+			content: "#!/bin/sh\n" + block("gogit x") + "\nexit 0",
+			want:    "#!/bin/sh\nexit 0",
+		},
+		{
+			name:    "blocks and legacy lines at the end",
+			content: "#!/bin/sh\n\n" + block("gogit x") + "\n" + block("gogit x") + "\ngogit x # " + defaultBashComment + "\n",
+			want:    "#!/bin/sh",
+		},
+		{
+			name:        "edited by hand",
+			content:     "#!/bin/sh\n\n" + strings.Replace(block("gogit x"), "gogit x", "gogit y", 1),
+			want:        "#!/bin/sh",
+			wantDrifted: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, drifted, err := removeBlocks(tt.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantDrifted, drifted)
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package install
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// dispatcherComment marks hook files which are gogit dispatchers.
+	dispatcherComment = "gogit hook dispatcher."
+
+	// originalStepName is the step the hook which existed before the dispatcher is moved to.
+	originalStepName = "00-original"
+	// gogitStepName is the step running gogit.
+	gogitStepName = "50-gogit"
+)
+
+var (
+	errOriginalStepExists = fmt.Errorf("original hook step exists already")
+)
+
+// dispatcherScript runs every executable in <hook>.d in order with the hook's arguments and stdin.
+// It stops at the first failing step and exits with its exit code.
+// stdin is buffered as every step has to see all of it.
+const dispatcherScript = `#!/bin/sh
+# ` + dispatcherComment + ` Runs the executables in the .d directory next to this hook in order.
+
+stdin="$(mktemp)" || exit 1
+trap 'rm -f "$stdin"' EXIT
+
+if [ ! -t 0 ]; then
+	cat > "$stdin"
+fi
+
+for step in "$0.d"/*; do
+	if [ -f "$step" ] && [ -x "$step" ]; then
+		"$step" "$@" < "$stdin" || exit $?
+	fi
+done
+`
+
+func stepsDir(hookFile string) string {
+	return hookFile + ".d"
+}
+
+// isDispatcher returns true if the hook file is a dispatcher written by dispatchLine.
+func isDispatcher(hookFile string) (dispatcher bool, err error) {
+	content, err := ioutil.ReadFile(hookFile)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return
+	}
+
+	return strings.Contains(string(content), "# "+dispatcherComment), nil
+}
+
+// dispatchLine installs body as gogit's own step of a dispatcher hook.
+//
+// An existing hook which is not a dispatcher yet is moved to <hook>.d/00-original,
+// so it keeps running first no matter how it is written.
+func dispatchLine(hookFile string, body []string, opts Options) (report HookReport, err error) {
+	report = HookReport{Hook: hookFile, Action: "added step to dispatcher"}
+
+	dispatcher, err := isDispatcher(hookFile)
+	if err != nil {
+		return
+	}
+
+	steps := stepsDir(hookFile)
+
+	if err = os.MkdirAll(steps, 0755); err != nil {
+		return
+	}
+
+	if !dispatcher {
+		report.Action = "created dispatcher"
+
+		content, err := ioutil.ReadFile(hookFile)
+		if err != nil && !os.IsNotExist(err) {
+			return report, err
+		}
+
+		if err == nil {
+			report.Action = "moved to " + filepath.Join(filepath.Base(steps), originalStepName) + " behind dispatcher"
+			report.Interpreter = analyzeHook(string(content)).Interpreter
+
+			// A block installed without dispatcher would run gogit twice.
+			if _, err = ensureNoBlock(hookFile); err != nil {
+				return report, err
+			}
+
+			if err = moveOriginal(hookFile, filepath.Join(steps, originalStepName)); err != nil {
+				return report, err
+			}
+		}
+
+		if err = ioutil.WriteFile(hookFile, []byte(dispatcherScript), 0755); err != nil {
+			return report, err
+		}
+	}
+
+	step := filepath.Join(steps, gogitStepName)
+	if err = ioutil.WriteFile(step, newHookFile(opts.Shell, body), 0755); err != nil {
+		return
+	}
+
+	return report, os.Chmod(step, 0755)
+}
+
+// undispatch removes gogit's step from the dispatcher hook.
+//
+// If only the original hook remains it is moved back in place of the dispatcher,
+// if no step remains the dispatcher is removed, so installing and removing leaves the hook as it was.
+func undispatch(hookFile string) (err error) {
+	steps := stepsDir(hookFile)
+
+	if err = os.Remove(filepath.Join(steps, gogitStepName)); err != nil && !os.IsNotExist(err) {
+		return
+	}
+
+	remaining, err := ioutil.ReadDir(steps)
+	if err != nil && !os.IsNotExist(err) {
+		return
+	}
+
+	switch {
+	case len(remaining) == 0:
+		if err = os.Remove(hookFile); err != nil {
+			return
+		}
+	case len(remaining) == 1 && remaining[0].Name() == originalStepName:
+		if err = os.Remove(hookFile); err != nil {
+			return
+		}
+
+		if err = moveOriginal(filepath.Join(steps, originalStepName), hookFile); err != nil {
+			return
+		}
+	default:
+		klog.InfoS("Keeping hook dispatcher as it has other steps", "hook", hookFile, "steps", len(remaining))
+		return nil
+	}
+
+	if err = os.Remove(steps); err != nil && !os.IsNotExist(err) {
+		return
+	}
+
+	return nil
+}
+
+// moveOriginal moves the hook file. A relative symlink is re-pointed so it still resolves
+// from its new directory, as hooks managed by other tools are often symlinks.
+func moveOriginal(from string, to string) (err error) {
+	if _, err = os.Lstat(to); err == nil {
+		return fmt.Errorf("%w: %#v", errOriginalStepExists, to)
+	}
+
+	stat, err := os.Lstat(from)
+	if err != nil {
+		return
+	}
+
+	if stat.Mode()&os.ModeSymlink == 0 {
+		return os.Rename(from, to)
+	}
+
+	target, err := os.Readlink(from)
+	if err != nil {
+		return
+	}
+
+	if !filepath.IsAbs(target) {
+		target, err = filepath.Rel(filepath.Dir(to), filepath.Join(filepath.Dir(from), target))
+		if err != nil {
+			return
+		}
+	}
+
+	if err = os.Symlink(target, to); err != nil {
+		return
+	}
+
+	return os.Remove(from)
+}
@@ -0,0 +1,172 @@
+package install
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hooksTempDir(t *testing.T) (base string) {
+	base, err := ioutil.TempDir(os.TempDir(), strings.ReplaceAll(t.Name(), "/", "_"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(base); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if err = os.MkdirAll(filepath.Join(base, hooksPath()), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	return base
+}
+
+func TestHooksWithOptions_dispatcher(t *testing.T) {
+	base := hooksTempDir(t)
+
+	original := "#!/usr/bin/env python3\nimport sys\nsys.exit(0)\n"
+	if err := ioutil.WriteFile(preCommitFilepath(base), []byte(original), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := Options{BaseCommand: "gogit", Dispatcher: true}
+
+	// Installing twice must not move the dispatcher itself.
+	for i := 0; i < 2; i++ {
+		if err := HooksWithOptions(base, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fileHasContent(t, preCommitFilepath(base), dispatcherScript, "pre-commit should be the dispatcher")
+	fileHasContent(t, filepath.Join(stepsDir(preCommitFilepath(base)), originalStepName), original, "original hook should be moved")
+	fileHasContent(t, filepath.Join(stepsDir(preCommitFilepath(base)), gogitStepName), "#!/bin/bash\n\n"+block(`gogit hook run pre-commit "$@"`), "gogit should be its own step")
+	fileHasContent(t, filepath.Join(stepsDir(commitMsgFilepath(base)), gogitStepName), "#!/bin/bash\n\n"+block(`gogit hook run commit-msg "$@"`), "commit-msg should get a step")
+
+	if err := Remove(base); err != nil {
+		t.Fatal(err)
+	}
+
+	fileHasContent(t, preCommitFilepath(base), original, "original hook should be restored")
+
+	stat, err := os.Stat(preCommitFilepath(base))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, os.FileMode(0700), stat.Mode().Perm(), "original mode should be kept")
+	assert.NoFileExists(t, commitMsgFilepath(base), "hooks without original should be removed")
+
+	for _, hook := range []string{preCommitFilepath(base), commitMsgFilepath(base), postCommitFilepath(base)} {
+		_, err = os.Stat(stepsDir(hook))
+		assert.True(t, os.IsNotExist(err), "%s should be removed", stepsDir(hook))
+	}
+}
+
+func TestHooksWithOptions_dispatcher_moves_legacy_line_out(t *testing.T) {
+	base := hooksTempDir(t)
+
+	if err := Hooks(base, "gogit"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := HooksWithOptions(base, Options{BaseCommand: "gogit", Dispatcher: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	fileHasContent(t, filepath.Join(stepsDir(preCommitFilepath(base)), originalStepName), "#!/bin/bash",
+		"gogit's line should not stay in the original hook")
+}
+
+func TestHooksWithOptions_dispatcher_relative_symlink(t *testing.T) {
+	base := hooksTempDir(t)
+
+	managed := filepath.Join(base, "tools", "pre-commit")
+	if err := os.MkdirAll(filepath.Dir(managed), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(managed, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := filepath.Rel(filepath.Dir(preCommitFilepath(base)), managed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.Symlink(target, preCommitFilepath(base)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = HooksWithOptions(base, Options{BaseCommand: "gogit", Dispatcher: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	fileHasContent(t, filepath.Join(stepsDir(preCommitFilepath(base)), originalStepName), "#!/bin/sh\n",
+		"moved symlink should still resolve")
+
+	if err = Remove(base); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := os.Readlink(preCommitFilepath(base))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, target, restored, "symlink should be restored exactly")
+}
+
+func TestDispatcherScript(t *testing.T) {
+	base := hooksTempDir(t)
+	hook := filepath.Join(base, hooksPath(), "pre-push")
+	out := filepath.Join(base, "out")
+
+	if err := ioutil.WriteFile(hook, []byte(dispatcherScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := map[string]string{
+		"10-first":      "#!/bin/sh\necho \"first $* $(cat)\" >> " + out + "\n",
+		"20-second":     "#!/bin/sh\necho \"second $* $(cat)\" >> " + out + "\nexit 3\n",
+		"30-not-run":    "#!/bin/sh\necho not-run >> " + out + "\n",
+		"05-executable": "not executable",
+	}
+
+	if err := os.MkdirAll(stepsDir(hook), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range steps {
+		mode := os.FileMode(0755)
+		if name == "05-executable" {
+			mode = 0644
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(stepsDir(hook), name), []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command(hook, "origin", "url")
+	cmd.Stdin = strings.NewReader("refs")
+
+	err := cmd.Run()
+
+	exitErr, ok := err.(*exec.ExitError)
+	if assert.True(t, ok, "dispatcher should fail with the failing step, got %v", err) {
+		assert.Equal(t, 3, exitErr.ExitCode())
+	}
+
+	fileHasContent(t, out, "first origin url refs\nsecond origin url refs\n", "steps should run in order with args and stdin")
+}
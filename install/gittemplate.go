@@ -0,0 +1,188 @@
+package install
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"aduu.dev/utils/helper"
+	"k8s.io/klog/v2"
+
+	"aduu.dev/tools/gogit/config"
+	"aduu.dev/tools/gogit/hook"
+)
+
+const (
+	// templateDirKey is the git config key of the directory git copies into new repositories.
+	templateDirKey = "init.templateDir"
+	// defaultTemplateDir is the template directory in the home directory configured if there is none.
+	defaultTemplateDir = ".git-templates"
+)
+
+var errPerCloneOption = fmt.Errorf("option is configured per clone")
+
+// gitConfigGlobal runs "git config --global" with args and returns its trimmed output.
+// found is false if git exits with 1, which it does for keys which are not set.
+func gitConfigGlobal(args ...string) (value string, found bool, err error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("git", append([]string{"config", "--global"}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return "", false, nil
+	}
+
+	if err != nil {
+		return "", false, fmt.Errorf("git config --global %s failed: %w: %s",
+			strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stdout.String()), true, nil
+}
+
+// TemplateDir returns the directory configured by init.templateDir in the global git config.
+//
+// If there is none and create is set, ~/.git-templates is created and configured, else found is false.
+func TemplateDir(create bool) (dir string, found bool, err error) {
+	dir, found, err = gitConfigGlobal("--path", "--get", templateDirKey)
+	if err != nil || found || !create {
+		return
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return
+	}
+
+	dir = filepath.Join(home, defaultTemplateDir)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+
+	if _, _, err = gitConfigGlobal(templateDirKey, dir); err != nil {
+		return
+	}
+
+	klog.InfoS("Configured git template directory", "key", templateDirKey, "dir", dir)
+
+	return dir, true, nil
+}
+
+// checkTemplateOptions returns an error for the options which are configured per clone,
+// they can be enabled for all clones of a repository in its config file instead.
+func (opts Options) checkTemplateOptions() error {
+	perClone := []struct {
+		flag string
+		set  bool
+	}{
+		{flag: "--module", set: len(opts.Modules) != 0},
+		{flag: "--comment-out", set: opts.CommentOut},
+		{flag: "--verify-staged", set: opts.VerifyStaged},
+		{flag: "--vet", set: opts.Vet},
+		{flag: "--dependents", set: opts.Dependents},
+		{flag: "--profiles", set: opts.Profiles},
+		{flag: "--overlay", set: opts.Overlay},
+	}
+
+	for _, option := range perClone {
+		if option.set {
+			return fmt.Errorf("%w, %s can not be installed into the git template, set it in %s of the repositories instead",
+				errPerCloneOption, option.flag, config.Filename)
+		}
+	}
+
+	return nil
+}
+
+// InstallTemplate installs the hooks into the git template directory, see TemplateDir,
+// so git init and git clone copy them into every new repository.
+//
+// The hooks only run "gogit hook run <name>", what they do is configured by each repository.
+// Options configured per clone are refused.
+func InstallTemplate(opts Options) (reports []HookReport, err error) {
+	if err = opts.checkTemplateOptions(); err != nil {
+		return
+	}
+
+	dir, _, err := TemplateDir(true)
+	if err != nil {
+		return
+	}
+
+	hooksFolder := filepath.Join(dir, "hooks")
+	if err = os.MkdirAll(hooksFolder, 0755); err != nil {
+		return
+	}
+
+	if opts, err = opts.withConfig(config.Config{}).pinned(); err != nil {
+		return
+	}
+
+	templates, err := parseHookTemplates(dir, opts.Shell, nil)
+	if err != nil {
+		return
+	}
+
+	installHook := installLine
+	if opts.Dispatcher {
+		installHook = dispatchLine
+	}
+
+	for _, name := range hook.Names() {
+		body, err := templates.render(opts.templateData(name))
+		if err != nil {
+			return reports, err
+		}
+
+		report, err := installHook(filepath.Join(hooksFolder, name), body, opts)
+		if err != nil {
+			return reports, err
+		}
+
+		reports = append(reports, report)
+	}
+
+	klog.InfoS("Successfully installed hooks into the git template directory", "dir", dir, "hooks", hook.Names())
+
+	return reports, nil
+}
+
+// RemoveTemplate removes gogit's blocks and legacy lines from the hooks in the git template directory.
+// init.templateDir stays configured as other tools may use the template directory too.
+func RemoveTemplate() (err error) {
+	dir, found, err := TemplateDir(false)
+	if err != nil {
+		return
+	}
+
+	if !found {
+		klog.InfoS("No git template directory configured", "key", templateDirKey)
+		return nil
+	}
+
+	hooksFolder := filepath.Join(dir, "hooks")
+
+	exists, err := helper.DoesPathExistErr(hooksFolder)
+	if err != nil || !exists {
+		return
+	}
+
+	for _, name := range hook.Names() {
+		if err = removeLineIfExists(filepath.Join(hooksFolder, name)); err != nil {
+			return
+		}
+	}
+
+	klog.InfoS("Removed gogit from the hooks in the git template directory", "dir", dir)
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package install
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"aduu.dev/tools/gogit/hook"
+)
+
+// globalGitConfigTempDir points the global git config into a temporary home directory.
+func globalGitConfigTempDir(t *testing.T) (home string) {
+	home, err := ioutil.TempDir("", "gogit-home")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(home); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	env := map[string]string{
+		"HOME":                home,
+		"GIT_CONFIG_GLOBAL":   filepath.Join(home, ".gitconfig"),
+		"GIT_CONFIG_NOSYSTEM": "1",
+	}
+
+	for name, value := range env {
+		name := name
+		previous, set := os.LookupEnv(name)
+
+		if err = os.Setenv(name, value); err != nil {
+			t.Fatal(err)
+		}
+
+		t.Cleanup(func() {
+			if set {
+				_ = os.Setenv(name, previous)
+			} else {
+				_ = os.Unsetenv(name)
+			}
+		})
+	}
+
+	return home
+}
+
+func TestInstallTemplate(t *testing.T) {
+	home := globalGitConfigTempDir(t)
+	templateDir := filepath.Join(home, defaultTemplateDir)
+
+	reports, err := InstallTemplate(Options{BaseCommand: "gogit"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, reports, len(hook.Names()))
+
+	dir, found, err := TemplateDir(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, found, "the template directory should be configured")
+	assert.Equal(t, templateDir, dir)
+
+	for _, name := range hook.Names() {
+		fileHasContent(t, filepath.Join(templateDir, "hooks", name), "#!/bin/bash\n\n"+block(`gogit hook run `+name+` "$@"`), "")
+	}
+
+	// Installing again keeps the hooks as they are.
+	if reports, err = InstallTemplate(Options{BaseCommand: "gogit"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, report := range reports {
+		assert.Equal(t, "unchanged", report.Action, report.Hook)
+	}
+
+	// New repositories get the hooks.
+	repo := filepath.Join(home, "repo")
+	if out, err := exec.Command("git", "init", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v: %s", err, out)
+	}
+
+	fileHasContent(t, preCommitFilepath(repo), "#!/bin/bash\n\n"+block(`gogit hook run pre-commit "$@"`), "git init should copy the hook")
+
+	if err = RemoveTemplate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range hook.Names() {
+		fileHasContent(t, filepath.Join(templateDir, "hooks", name), "#!/bin/bash", "gogit's block should be removed")
+	}
+}
+
+func TestInstallTemplate_configured_dir(t *testing.T) {
+	home := globalGitConfigTempDir(t)
+	templateDir := filepath.Join(home, "templates")
+
+	if _, _, err := gitConfigGlobal(templateDirKey, "~/templates"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := InstallTemplate(Options{BaseCommand: "gogit"}); err != nil {
+		t.Fatal(err)
+	}
+
+	fileHasContent(t, filepath.Join(templateDir, "hooks", hook.PreCommit), "#!/bin/bash\n\n"+block(`gogit hook run pre-commit "$@"`),
+		"the hooks should be installed into the configured directory")
+}
+
+func TestInstallTemplate_per_clone_option(t *testing.T) {
+	home := globalGitConfigTempDir(t)
+
+	_, err := InstallTemplate(Options{Profiles: true})
+	assert.True(t, errors.Is(err, errPerCloneOption), "got %v", err)
+	assert.NoDirExists(t, filepath.Join(home, defaultTemplateDir), "nothing should be installed")
+}
+
+func TestRemoveTemplate_not_configured(t *testing.T) {
+	globalGitConfigTempDir(t)
+
+	assert.NoError(t, RemoveTemplate())
+
+	_, found, err := TemplateDir(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.False(t, found, "removing should not configure a template directory")
+}
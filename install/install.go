@@ -4,19 +4,34 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
 
 	"aduu.dev/utils/helper"
 	"k8s.io/klog/v2"
+
+	"aduu.dev/tools/gogit/config"
+	"aduu.dev/tools/gogit/hook"
+	"aduu.dev/tools/gogit/lock"
+	"aduu.dev/tools/gogit/version"
 )
 
 var (
 	errHooksFolderDoesNotExist = fmt.Errorf("hooks folder does not exist")
+	errNotShellHook            = fmt.Errorf("existing hook is written for another shell")
+	errInvalidLogLevel         = fmt.Errorf("invalid log level")
 )
 
 const (
 	defaultBashComment = "GENERATED BY gogit."
+
+	// defaultBaseCommand is the command the hooks run if none is configured.
+	defaultBaseCommand = "gogit"
+	// gogitModule is the module the hooks fall back to with go run.
+	gogitModule = "aduu.dev/tools/gogit"
 )
 
 func hooksPath() string {
@@ -31,22 +46,279 @@ func postCommitFilepath(base string) string {
 	return filepath.Join(base, hooksPath(), "post-commit")
 }
 
-func preCommitLine(baseCommand string) string {
-	return fmt.Sprintf(`%s replace --replace-only-if-staged .`, baseCommand)
+func commitMsgFilepath(base string) string {
+	return filepath.Join(base, hooksPath(), "commit-msg")
 }
 
-func postCommitLine(baseCommand string) string {
-	return fmt.Sprintf(`%s replace --replace-only-if-staged --undo .`, baseCommand)
+func postCheckoutFilepath(base string) string {
+	return filepath.Join(base, hooksPath(), "post-checkout")
 }
 
-func bashFile(line string, comment string) []byte {
-	return []byte(fmt.Sprintf(`#!/bin/bash
+func postMergeFilepath(base string) string {
+	return filepath.Join(base, hooksPath(), "post-merge")
+}
 
-%s`, combinedLine(line, comment)))
+// Options configures the hooks installed by HooksWithOptions.
+//
+// Empty fields are taken from the repository's config file, see config.Config.
+// The options deciding what the hooks do are written to the configuration of the clone,
+// the hooks themselves only run "gogit hook run <name>".
+type Options struct {
+	// BaseCommand is the command the hooks run, e.g. gogit.
+	BaseCommand string
+	// Shell selects the hook templates: ShellBash, ShellSh or ShellPowerShell.
+	Shell string
+	// Modules are the module directories relative to base the hooks work on, "." by default.
+	Modules []string
+	// LogLevel is the klog verbosity the hooks run gogit with, empty for gogit's default.
+	LogLevel string
+	// Profiles keeps a local replace profile per branch on checkout.
+	Profiles bool
+	// Overlay regenerates go.dev.mod from the overlay on checkout and merge.
+	Overlay bool
+	// VerifyStaged blocks commits whose staged module does not build without local replace directives.
+	VerifyStaged bool
+	// Vet additionally runs go vet when VerifyStaged verifies the staged module.
+	Vet bool
+	// Dependents blocks commits if a sibling checkout which locally replaces the module does not build.
+	Dependents bool
+	// CommentOut disables local replace directives with gogit-disabled markers instead of removing them into a backup.
+	CommentOut bool
+	// Dispatcher moves existing hooks to <hook>.d/00-original and installs dispatchers
+	// running every executable in <hook>.d, with gogit as its own step.
+	Dispatcher bool
+	// Repair overwrites gogit blocks in hooks which were edited by hand.
+	Repair bool
+	// Pin embeds the absolute path of BaseCommand, or of the running gogit if there is none, into the hooks
+	// which check that it exists. Git clients with another PATH then run the same gogit.
+	Pin bool
+	// GoRunFallback runs "go run aduu.dev/tools/gogit@<version>" in the version of the running gogit
+	// if the binary does not exist.
+	GoRunFallback bool
+	// Off are the features turned off explicitly, see Features. The configuration of the clone keeps
+	// the features earlier installs enabled otherwise.
+	Off []string
 }
 
-// Hooks installs pre-commit hooks which do remove local replace directives temporarily during a commit.
+// Hooks installs the hooks which remove local replace directives temporarily during a commit.
+//
+// The commit-msg hook records the removed local replace directives as trailers in the commit message.
+// The post-checkout and post-merge hooks do nothing unless profiles or the overlay are enabled.
 func Hooks(base string, baseCommand string) (err error) {
+	return HooksWithOptions(base, Options{BaseCommand: baseCommand})
+}
+
+// HooksWithOptions installs the hooks like Hooks and enables the optional features in opts.
+func HooksWithOptions(base string, opts Options) (err error) {
+	_, err = Install(base, opts)
+	return err
+}
+
+// withConfig fills the empty options from the repository's config and gogit's defaults.
+func (opts Options) withConfig(cfg config.Config) Options {
+	if len(opts.BaseCommand) == 0 {
+		opts.BaseCommand = cfg.Binary
+	}
+
+	if len(opts.BaseCommand) == 0 {
+		opts.BaseCommand = defaultBaseCommand
+	}
+
+	if len(opts.Shell) == 0 {
+		opts.Shell = cfg.Shell
+	}
+
+	if len(opts.Shell) == 0 {
+		opts.Shell = ShellBash
+	}
+
+	if len(opts.Modules) == 0 {
+		opts.Modules = cfg.Modules
+	}
+
+	if len(opts.Modules) == 0 {
+		opts.Modules = []string{"."}
+	}
+
+	if len(opts.LogLevel) == 0 && cfg.LogLevel != nil {
+		opts.LogLevel = strconv.Itoa(*cfg.LogLevel)
+	}
+
+	opts.CommentOut = opts.CommentOut || cfg.Mode == config.ModeCommentOut
+	opts.VerifyStaged = opts.VerifyStaged || config.IsOn(cfg.VerifyStaged)
+	opts.Vet = opts.Vet || config.IsOn(cfg.Vet)
+	opts.Dependents = opts.Dependents || config.IsOn(cfg.Dependents)
+	opts.Profiles = opts.Profiles || config.IsOn(cfg.Profiles)
+	opts.Overlay = opts.Overlay || config.IsOn(cfg.Overlay)
+	opts.Pin = opts.Pin || config.IsOn(cfg.Pin)
+	opts.GoRunFallback = opts.GoRunFallback || config.IsOn(cfg.GoRunFallback)
+
+	return opts
+}
+
+// pinned replaces the base command by its absolute path if opts.Pin is set.
+// The default base command is replaced by the running gogit.
+func (opts Options) pinned() (Options, error) {
+	if !opts.Pin {
+		return opts, nil
+	}
+
+	binary := opts.BaseCommand
+
+	var err error
+	if binary == defaultBaseCommand {
+		binary, err = os.Executable()
+	} else {
+		binary, err = exec.LookPath(binary)
+	}
+
+	if err != nil {
+		return opts, fmt.Errorf("failed to pin the path of %#v: %w", opts.BaseCommand, err)
+	}
+
+	if opts.BaseCommand, err = filepath.Abs(binary); err != nil {
+		return opts, err
+	}
+
+	return opts, nil
+}
+
+// Features which can be turned off with Options.Off, named like the install-hooks flags.
+const (
+	FeatureCommentOut    = "comment-out"
+	FeatureVerifyStaged  = "verify-staged"
+	FeatureVet           = "vet"
+	FeatureDependents    = "dependents"
+	FeatureProfiles      = "profiles"
+	FeatureOverlay       = "overlay"
+	FeaturePin           = "pin"
+	FeatureGoRunFallback = "go-run-fallback"
+)
+
+// Features returns the features which can be turned off with Options.Off.
+func Features() []string {
+	return []string{FeatureCommentOut, FeatureVerifyStaged, FeatureVet, FeatureDependents, FeatureProfiles, FeatureOverlay, FeaturePin, FeatureGoRunFallback}
+}
+
+func (opts Options) off(feature string) bool {
+	for _, f := range opts.Off {
+		if f == feature {
+			return true
+		}
+	}
+
+	return false
+}
+
+// localConfig returns the configuration of the clone existing merged with the options which are given.
+// Features stay as existing configures them unless they are enabled or listed in Off.
+func (opts Options) localConfig(existing config.Config) (cfg config.Config, err error) {
+	cfg = existing
+
+	if len(opts.BaseCommand) != 0 {
+		cfg.Binary = opts.BaseCommand
+	}
+
+	if len(opts.Shell) != 0 {
+		cfg.Shell = opts.Shell
+	}
+
+	if len(opts.Modules) != 0 {
+		cfg.Modules = opts.Modules
+	}
+
+	features := []struct {
+		name    string
+		enabled bool
+		field   **bool
+	}{
+		{name: FeatureVerifyStaged, enabled: opts.VerifyStaged, field: &cfg.VerifyStaged},
+		{name: FeatureVet, enabled: opts.Vet, field: &cfg.Vet},
+		{name: FeatureDependents, enabled: opts.Dependents, field: &cfg.Dependents},
+		{name: FeatureProfiles, enabled: opts.Profiles, field: &cfg.Profiles},
+		{name: FeatureOverlay, enabled: opts.Overlay, field: &cfg.Overlay},
+		{name: FeaturePin, enabled: opts.Pin, field: &cfg.Pin},
+		{name: FeatureGoRunFallback, enabled: opts.GoRunFallback, field: &cfg.GoRunFallback},
+	}
+
+	// Turning a feature off is recorded, it overrides the repository's configuration.
+	for _, f := range features {
+		switch {
+		case f.enabled:
+			*f.field = boolPtr(true)
+		case opts.off(f.name):
+			*f.field = boolPtr(false)
+		}
+	}
+
+	switch {
+	case opts.CommentOut:
+		cfg.Mode = config.ModeCommentOut
+	case opts.off(FeatureCommentOut):
+		cfg.Mode = config.ModeBackup
+	}
+
+	if len(opts.LogLevel) != 0 {
+		level, err := strconv.Atoi(opts.LogLevel)
+		if err != nil {
+			return cfg, fmt.Errorf("%w %#v: must be a number", errInvalidLogLevel, opts.LogLevel)
+		}
+
+		cfg.LogLevel = &level
+	}
+
+	return cfg, nil
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// saveLocalConfig merges the options into the configuration of the clone in base, see localConfig.
+// The file is only written if the options change it, so installing again keeps what earlier installs configured.
+func (opts Options) saveLocalConfig(base string) (err error) {
+	existing, err := config.LoadLocal(base)
+	if err != nil {
+		return
+	}
+
+	cfg, err := opts.localConfig(existing)
+	if err != nil || reflect.DeepEqual(cfg, existing) {
+		return
+	}
+
+	return config.SaveLocal(base, cfg)
+}
+
+// templateData returns the template variables for the hook.
+func (opts Options) templateData(name string) templateData {
+	mode := config.ModeBackup
+	if opts.CommentOut {
+		mode = config.ModeCommentOut
+	}
+
+	var fallback string
+	if opts.GoRunFallback {
+		fallback = gogitModule + "@" + fallbackVersion()
+	}
+
+	return templateData{
+		Hook:         name,
+		Binary:       opts.BaseCommand,
+		Modules:      opts.Modules,
+		Mode:         mode,
+		LogLevel:     opts.LogLevel,
+		Pinned:       opts.Pin,
+		Fallback:     fallback,
+		VerifyStaged: opts.VerifyStaged,
+		Dependents:   opts.Dependents,
+		Profiles:     opts.Profiles,
+		Overlay:      opts.Overlay,
+	}
+}
+
+// Install is HooksWithOptions reporting what it did to each hook.
+func Install(base string, opts Options) (reports []HookReport, err error) {
 	hooksFolder := filepath.Join(base, hooksPath())
 
 	// The hooks folder must exist.
@@ -56,54 +328,139 @@ func Hooks(base string, baseCommand string) (err error) {
 	}
 
 	if !exists {
-		return errHooksFolderDoesNotExist
+		return nil, errHooksFolderDoesNotExist
 	}
 
-	exists, err = helper.DoesPathExistErr(preCommitFilepath(base))
+	l, err := lockHooks(base)
 	if err != nil {
-		return err
+		return
 	}
 
-	// Install line into existing file or into new file, depending whether we found the file
-	if exists {
-		if err = EnsureAddLinesWithComment(preCommitFilepath(base), preCommitLine(baseCommand), defaultBashComment); err != nil {
-			return
+	defer release(l)
+
+	if err = opts.saveLocalConfig(base); err != nil {
+		return
+	}
+
+	cfg, err := config.Load(base)
+	if err != nil {
+		return
+	}
+
+	if opts, err = opts.withConfig(cfg).pinned(); err != nil {
+		return
+	}
+
+	templates, err := parseHookTemplates(base, opts.Shell, cfg.Templates)
+	if err != nil {
+		return
+	}
+
+	installHook := installLine
+	if opts.Dispatcher {
+		installHook = dispatchLine
+	}
+
+	for _, name := range hook.Names() {
+		body, err := templates.render(opts.templateData(name))
+		if err != nil {
+			return reports, err
 		}
-	} else {
-		if err = ioutil.WriteFile(preCommitFilepath(base), bashFile(preCommitLine(baseCommand), defaultBashComment), 0755); err != nil {
-			return
+
+		report, err := installHook(filepath.Join(base, hooksPath(), name), body, opts)
+		if err != nil {
+			return reports, err
 		}
+
+		reports = append(reports, report)
 	}
 
-	exists, err = helper.DoesPathExistErr(postCommitFilepath(base))
+	klog.InfoS("Successuflly installed commit hooks",
+		"hooks", hook.Names(),
+		"profiles", opts.Profiles,
+		"overlay", opts.Overlay,
+		"verify-staged", opts.VerifyStaged,
+		"vet", opts.Vet,
+		"dependents", opts.Dependents,
+		"comment-out", opts.CommentOut,
+		"dispatcher", opts.Dispatcher,
+		"repair", opts.Repair,
+		"pin", opts.Pin,
+		"go-run-fallback", opts.GoRunFallback,
+		"shell", opts.Shell,
+		"modules", opts.Modules,
+	)
+
+	return reports, nil
+}
+
+// lockHooks locks the hooks folder against concurrent gogit invocations editing the hooks.
+// Git only runs hooks with well-known names so the lock file does not interfere.
+func lockHooks(base string) (l *lock.Lock, err error) {
+	return lock.Acquire(filepath.Join(base, hooksPath(), ".gogit.lock"), lock.Timeout())
+}
+
+func release(l *lock.Lock) {
+	if err := l.Release(); err != nil {
+		klog.ErrorS(err, "Failed to release hooks lock")
+	}
+}
+
+// installLine installs body as managed block into the existing hook file or writes a new one
+// and ensures the hook file is executable.
+//
+// Existing hooks which the shell of opts cannot extend are refused, they can be chained with Options.Dispatcher.
+func installLine(hookFile string, body []string, opts Options) (report HookReport, err error) {
+	report.Hook = hookFile
+
+	exists, err := helper.DoesPathExistErr(hookFile)
 	if err != nil {
 		return
 	}
 
-	// Install line into existing file or into new file, depending whether we found the file
+	// Install block into existing file or into new file, depending whether we found the file
 	if exists {
-		if err = EnsureAddLinesWithComment(postCommitFilepath(base), postCommitLine(baseCommand), defaultBashComment); err != nil {
-			return
+		content, err := ioutil.ReadFile(hookFile)
+		if err != nil {
+			return report, err
+		}
+
+		if report.Interpreter, err = checkShell(hookFile, string(content), opts.Shell); err != nil {
+			return report, err
+		}
+
+		if report.Action, err = ensureBlock(hookFile, body, opts.Repair); err != nil {
+			return report, err
 		}
 	} else {
-		if err = ioutil.WriteFile(postCommitFilepath(base), bashFile(postCommitLine(baseCommand), defaultBashComment), 0755); err != nil {
+		report.Action = "created"
+
+		if err = ioutil.WriteFile(hookFile, newHookFile(opts.Shell, body), 0755); err != nil {
 			return
 		}
 	}
 
 	// Ensure existing files are executable.
-	if err = os.Chmod(preCommitFilepath(base), 0755); err != nil {
-		return
-	}
+	return report, os.Chmod(hookFile, 0755)
+}
 
-	if err = os.Chmod(preCommitFilepath(base), 0755); err != nil {
-		return
+// checkShell returns the interpreter of the existing hook and an error if the shell cannot extend it.
+func checkShell(hookFile string, content string, shell string) (interpreter string, err error) {
+	interpreter = analyzeHook(content).Interpreter
+
+	if !interpreters[shell][interpreter] {
+		return interpreter, fmt.Errorf("%w: %#v is run by %s, install with --dispatcher to chain it",
+			errNotShellHook, hookFile, interpreter)
 	}
 
-	klog.InfoS("Successuflly installed commit hooks",
-		"pre-commit", preCommitFilepath(base),
-		"post-commit", postCommitFilepath(base),
-	)
+	return interpreter, nil
+}
+
+// fallbackVersion returns the version of gogit the hooks fall back to, the latest for development builds.
+func fallbackVersion() string {
+	if v := version.Get(); v != version.Devel {
+		return v
+	}
 
-	return nil
+	return "latest"
 }
@@ -1,12 +1,16 @@
 package install
 
 import (
+	"bytes"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"aduu.dev/tools/gogit/config"
 )
 
 func pstring(s string) *string {
@@ -42,12 +46,8 @@ func TestHooks(t *testing.T) {
 				postCommitContent: nil,
 				baseCommand:       "gogit",
 			},
-			wantPreCommitContent: `#!/bin/bash
-
-gogit replace --replace-only-if-staged . # ` + defaultBashComment,
-			wantPostCommitContent: `#!/bin/bash
-
-gogit replace --replace-only-if-staged --undo . # ` + defaultBashComment,
+			wantPreCommitContent:  "#!/bin/bash\n\n" + block(`gogit hook run pre-commit "$@"`),
+			wantPostCommitContent: "#!/bin/bash\n\n" + block(`gogit hook run post-commit "$@"`),
 		},
 
 		{
@@ -57,8 +57,8 @@ gogit replace --replace-only-if-staged --undo . # ` + defaultBashComment,
 				postCommitContent: pstring(""),
 				baseCommand:       "gogit",
 			},
-			wantPreCommitContent:  `gogit replace --replace-only-if-staged . # ` + defaultBashComment,
-			wantPostCommitContent: `gogit replace --replace-only-if-staged --undo . # ` + defaultBashComment,
+			wantPreCommitContent:  block(`gogit hook run pre-commit "$@"`),
+			wantPostCommitContent: block(`gogit hook run post-commit "$@"`),
 		},
 		{
 			name: "add to existing pre-commit file with no match & non-empty file",
@@ -67,12 +67,8 @@ gogit replace --replace-only-if-staged --undo . # ` + defaultBashComment,
 				postCommitContent: pstring("#!/bin/bash"),
 				baseCommand:       "gogit",
 			},
-			wantPreCommitContent: `#!/bin/bash
-
-gogit replace --replace-only-if-staged . # ` + defaultBashComment,
-			wantPostCommitContent: `#!/bin/bash
-
-gogit replace --replace-only-if-staged --undo . # ` + defaultBashComment,
+			wantPreCommitContent:  "#!/bin/bash\n\n" + block(`gogit hook run pre-commit "$@"`),
+			wantPostCommitContent: "#!/bin/bash\n\n" + block(`gogit hook run post-commit "$@"`),
 		},
 
 		{
@@ -82,8 +78,8 @@ gogit replace --replace-only-if-staged --undo . # ` + defaultBashComment,
 				postCommitContent: pstring("# " + defaultBashComment),
 				baseCommand:       "gogit",
 			},
-			wantPreCommitContent:  `gogit replace --replace-only-if-staged . # ` + defaultBashComment,
-			wantPostCommitContent: `gogit replace --replace-only-if-staged --undo . # ` + defaultBashComment,
+			wantPreCommitContent:  block(`gogit hook run pre-commit "$@"`),
+			wantPostCommitContent: block(`gogit hook run post-commit "$@"`),
 		},
 	}
 
@@ -142,6 +138,8 @@ gogit replace --replace-only-if-staged --undo . # ` + defaultBashComment,
 				return
 			}
 
+			fileHasContent(t, commitMsgFilepath(base), "#!/bin/bash\n\n"+block(`gogit hook run commit-msg "$@"`), "commit-msg should be created")
+
 			exec, err := IsFileExecutable(preCommitFilepath(base))
 			if err != nil {
 				t.Fatal(err)
@@ -159,6 +157,205 @@ gogit replace --replace-only-if-staged --undo . # ` + defaultBashComment,
 			if !exec {
 				t.Fatal("post-commit file should be executable")
 			}
+
+			exec, err = IsFileExecutable(commitMsgFilepath(base))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !exec {
+				t.Fatal("commit-msg file should be executable")
+			}
 		})
 	}
 }
+
+func TestHooksWithOptions_optional_hooks(t *testing.T) {
+	tempDir, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(tempDir); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	base := tempDir
+	if err = os.MkdirAll(filepath.Join(base, hooksPath()), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = HooksWithOptions(base, Options{BaseCommand: "gogit", Profiles: true, Overlay: true, VerifyStaged: true, Dependents: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	fileHasContent(t, postCheckoutFilepath(base), "#!/bin/bash\n\n"+block(`gogit hook run post-checkout "$@"`), "post-checkout should be created")
+	fileHasContent(t, postMergeFilepath(base), "#!/bin/bash\n\n"+block(`gogit hook run post-merge "$@"`), "post-merge should be created")
+
+	cfg, err := config.Load(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	on := true
+	assert.Equal(t, config.Config{Binary: "gogit", Profiles: &on, Overlay: &on, VerifyStaged: &on, Dependents: &on}, cfg,
+		"the features should be enabled in the configuration of the clone")
+
+	if err = Remove(base); err != nil {
+		t.Fatal(err)
+	}
+
+	fileHasContent(t, postCheckoutFilepath(base), `#!/bin/bash`, "post-checkout line should be removed")
+	fileHasContent(t, postMergeFilepath(base), `#!/bin/bash`, "post-merge line should be removed")
+
+	cfg, err = config.Load(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, config.Config{}, cfg, "the configuration of the clone should be removed")
+}
+
+func TestHooksWithOptions_keeps_clone_config(t *testing.T) {
+	base := hooksTempDir(t)
+	level := 2
+	on, off := true, false
+
+	steps := []struct {
+		opts Options
+		want config.Config
+	}{
+		{
+			opts: Options{BaseCommand: "my-gogit", Profiles: true, VerifyStaged: true, Vet: true, CommentOut: true, LogLevel: "2"},
+			want: config.Config{Binary: "my-gogit", Mode: config.ModeCommentOut, LogLevel: &level, Profiles: &on, VerifyStaged: &on, Vet: &on},
+		},
+		{
+			opts: Options{},
+			want: config.Config{Binary: "my-gogit", Mode: config.ModeCommentOut, LogLevel: &level, Profiles: &on, VerifyStaged: &on, Vet: &on},
+		},
+		{
+			opts: Options{Overlay: true, Off: []string{FeatureProfiles, FeatureCommentOut, FeatureVet}},
+			want: config.Config{Binary: "my-gogit", Mode: config.ModeBackup, LogLevel: &level, Overlay: &on, Profiles: &off, VerifyStaged: &on, Vet: &off},
+		},
+	}
+
+	for i, step := range steps {
+		if err := HooksWithOptions(base, step.opts); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg, err := config.LoadLocal(base)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, step.want, cfg, "step %d: only the given options should change the configuration of the clone", i)
+	}
+}
+
+func TestHooksWithOptions_off_overrides_repository(t *testing.T) {
+	base := hooksTempDir(t)
+
+	if err := ioutil.WriteFile(config.Filepath(base), []byte(`{"profiles": true, "mode": "comment-out"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := HooksWithOptions(base, Options{Off: []string{FeatureProfiles, FeatureCommentOut}}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.Load(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.False(t, config.IsOn(cfg.Profiles), "turning profiles off should override the repository")
+	assert.Equal(t, config.ModeBackup, cfg.Mode, "turning comment-out off should override the repository")
+}
+
+func TestHooksWithOptions_comment_out(t *testing.T) {
+	tempDir, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(tempDir); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	base := tempDir
+	if err = os.MkdirAll(filepath.Join(base, hooksPath()), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = HooksWithOptions(base, Options{BaseCommand: "gogit", CommentOut: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	fileHasContent(t, preCommitFilepath(base), "#!/bin/bash\n\n"+block(`gogit hook run pre-commit "$@"`), "pre-commit should only call gogit")
+
+	cfg, err := config.Load(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, config.ModeCommentOut, cfg.Mode, "comment-out should be configured for the clone")
+}
+
+func TestHooksWithOptions_pin(t *testing.T) {
+	base := hooksTempDir(t)
+
+	if err := HooksWithOptions(base, Options{Pin: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(preCommitFilepath(base))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Contains(t, string(content), posixQuote(executable)+` hook run pre-commit "$@"`,
+		"the running gogit should be pinned by default")
+
+	// A configured binary is pinned by its absolute path.
+	binary := filepath.Join(base, "bin", "my gogit")
+	if err = os.MkdirAll(filepath.Dir(binary), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ioutil.WriteFile(binary, []byte("#!/bin/sh\necho \"gogit $*\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = HooksWithOptions(base, Options{BaseCommand: binary, Pin: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command("sh", preCommitFilepath(base), "x").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "gogit hook run pre-commit x\n", string(out), "the hook should run the pinned binary")
+
+	if err = os.Remove(binary); err != nil {
+		t.Fatal(err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("sh", preCommitFilepath(base))
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	assert.Error(t, err, "the hook should fail if the pinned binary is gone")
+	assert.Contains(t, stderr.String(), "gogit: "+binary+" not found, reinstall gogit or rerun gogit install-hooks")
+}
@@ -0,0 +1,554 @@
+package install
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"aduu.dev/utils/helper"
+	"k8s.io/klog/v2"
+
+	"aduu.dev/tools/gogit/config"
+	"aduu.dev/tools/gogit/hook"
+	"aduu.dev/tools/gogit/version"
+)
+
+// Hook managers InstallManager adds gogit to instead of writing the hooks into .git/hooks.
+const (
+	ManagerPreCommit = "pre-commit"
+	ManagerLefthook  = "lefthook"
+	ManagerHusky     = "husky"
+)
+
+const (
+	// preCommitConfigFilename is the config of the pre-commit framework in the repository.
+	preCommitConfigFilename = ".pre-commit-config.yaml"
+	// preCommitRepo is gogit's repository, its .pre-commit-hooks.yaml defines the hooks gogit-<hook>.
+	preCommitRepo = "https://github.com/aduu-dev/tools-gogit"
+	// lefthookCommand is the name of gogit's command in the hooks of lefthook.yml.
+	lefthookCommand = "gogit"
+	// huskyDir holds a script per hook husky runs.
+	huskyDir = ".husky"
+)
+
+// lefthookFilenames are the configs lefthook reads, the first one is created if none exists.
+var lefthookFilenames = []string{"lefthook.yml", ".lefthook.yml", "lefthook.yaml", ".lefthook.yaml"}
+
+var (
+	errUnknownManager  = fmt.Errorf("unknown hook manager")
+	errManagerOption   = fmt.Errorf("option is not supported with a hook manager")
+	errManagerNotSetUp = fmt.Errorf("hook manager is not set up")
+	errManagerConfig   = fmt.Errorf("config of the hook manager can not be merged")
+)
+
+// Managers returns the hook managers InstallManager supports.
+func Managers() []string {
+	return []string{ManagerPreCommit, ManagerLefthook, ManagerHusky}
+}
+
+// hookManager adds gogit's entries to the config of a hook manager and removes them again.
+type hookManager struct {
+	install func(base string, opts Options) (reports []HookReport, err error)
+	remove  func(base string) (err error)
+}
+
+func managerFor(name string) (m hookManager, err error) {
+	switch name {
+	case ManagerPreCommit:
+		return hookManager{install: installPreCommit, remove: removePreCommit}, nil
+	case ManagerLefthook:
+		return hookManager{install: installLefthook, remove: removeLefthook}, nil
+	case ManagerHusky:
+		return hookManager{install: installHusky, remove: removeHusky}, nil
+	default:
+		return m, fmt.Errorf("%w %#v: supported are %s", errUnknownManager, name, strings.Join(Managers(), ", "))
+	}
+}
+
+// checkManagerOptions returns an error for the options which only apply to hooks gogit writes itself.
+func (opts Options) checkManagerOptions() error {
+	unsupported := []struct {
+		flag string
+		set  bool
+	}{
+		{flag: "--shell", set: len(opts.Shell) != 0},
+		{flag: "--dispatcher", set: opts.Dispatcher},
+		{flag: "--pin", set: opts.Pin},
+		{flag: "--go-run-fallback", set: opts.GoRunFallback},
+	}
+
+	for _, option := range unsupported {
+		if option.set {
+			return fmt.Errorf("%w, %s can not be used as the hook manager runs gogit", errManagerOption, option.flag)
+		}
+	}
+
+	return nil
+}
+
+// InstallManager adds gogit's hooks to the config of the hook manager in the repository in base
+// instead of writing them into .git/hooks, which the manager owns.
+//
+// The entries only run "gogit hook run <name>" like the hooks Install writes, the options are written
+// to the configuration of the clone. Installing again only updates gogit's entries, other entries are kept.
+func InstallManager(base string, manager string, opts Options) (reports []HookReport, err error) {
+	m, err := managerFor(manager)
+	if err != nil {
+		return
+	}
+
+	if err = opts.checkManagerOptions(); err != nil {
+		return
+	}
+
+	if err = opts.saveLocalConfig(base); err != nil {
+		return
+	}
+
+	cfg, err := config.Load(base)
+	if err != nil {
+		return
+	}
+
+	if found, err := installed(base); err == nil && found {
+		klog.InfoS("gogit's hooks in .git/hooks run besides the hook manager, remove them with remove-hooks", "repo", base)
+	}
+
+	if reports, err = m.install(base, opts.withConfig(cfg)); err != nil {
+		return
+	}
+
+	klog.InfoS("Successfully added gogit to the hook manager", "manager", manager, "repo", base)
+
+	return reports, nil
+}
+
+// RemoveManager removes gogit's entries from the config of the hook manager in the repository in base.
+// Other entries are kept, configs and scripts which only held gogit's entries are deleted.
+func RemoveManager(base string, manager string) (err error) {
+	m, err := managerFor(manager)
+	if err != nil {
+		return
+	}
+
+	if err = m.remove(base); err != nil {
+		return
+	}
+
+	// The configuration of the clone is still needed by gogit's hooks in .git/hooks.
+	found, err := installed(base)
+	if err != nil {
+		return
+	}
+
+	if !found {
+		localConfig, err := config.LocalFilepath(base)
+		if err != nil {
+			return err
+		}
+
+		if err = os.Remove(localConfig); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	klog.InfoS("Removed gogit from the hook manager", "manager", manager, "repo", base)
+
+	return nil
+}
+
+// preCommitRev returns the revision of gogit's repository the pre-commit framework checks out,
+// the default branch for development builds.
+func preCommitRev() string {
+	if v := version.Get(); v != version.Devel {
+		return v
+	}
+
+	return "master"
+}
+
+func installPreCommit(base string, opts Options) (reports []HookReport, err error) {
+	report, err := mergeConfig(filepath.Join(base, preCommitConfigFilename), opts.Repair, func(lines []string) ([]string, error) {
+		i, value := yamlKey(lines, "repos", 0, 0, len(lines))
+		if i < 0 {
+			lines = append(lines, "repos:")
+			i = len(lines) - 1
+		} else if len(value) != 0 {
+			return nil, fmt.Errorf("%w: repos is not a block sequence", errManagerConfig)
+		}
+
+		indent := yamlChildIndent(lines, i+1, yamlSectionEnd(lines, i, 0), 0)
+		prefix := strings.Repeat(" ", indent)
+
+		entry := []string{
+			prefix + "- repo: " + preCommitRepo,
+			prefix + "  rev: " + preCommitRev(),
+			prefix + "  hooks:",
+		}
+
+		for _, name := range hook.Names() {
+			entry = append(entry, prefix+"  - id: gogit-"+name)
+		}
+
+		return insertLines(lines, i+1, indentedBlock(entry, indent)), nil
+	})
+	if err != nil {
+		return
+	}
+
+	klog.InfoS("Install the git hooks of pre-commit for every stage of gogit",
+		"command", "pre-commit install -t "+strings.Join(hook.Names(), " -t "))
+
+	return []HookReport{report}, nil
+}
+
+func removePreCommit(base string) (err error) {
+	return removeConfig(filepath.Join(base, preCommitConfigFilename), func(lines []string) bool {
+		content := yamlContentLines(lines)
+		if len(content) != 1 {
+			return len(content) == 0
+		}
+
+		i, value := yamlKey(content, "repos", 0, 0, 1)
+
+		return i == 0 && len(value) == 0
+	})
+}
+
+// lefthookConfig returns the first existing config of lefthook, see lefthookFilenames.
+func lefthookConfig(base string) (file string, exists bool, err error) {
+	for _, name := range lefthookFilenames {
+		file = filepath.Join(base, name)
+
+		if exists, err = helper.DoesPathExistErr(file); err != nil || exists {
+			return
+		}
+	}
+
+	return filepath.Join(base, lefthookFilenames[0]), false, nil
+}
+
+func installLefthook(base string, opts Options) (reports []HookReport, err error) {
+	file, _, err := lefthookConfig(base)
+	if err != nil {
+		return
+	}
+
+	report, err := mergeConfig(file, opts.Repair, func(lines []string) (_ []string, err error) {
+		for _, name := range hook.Names() {
+			if lines, err = insertLefthookCommand(lines, name, opts.BaseCommand); err != nil {
+				return nil, err
+			}
+		}
+
+		return lines, nil
+	})
+	if err != nil {
+		return
+	}
+
+	return []HookReport{report}, nil
+}
+
+// insertLefthookCommand adds gogit's command to the commands of the hook in lefthook.yml.
+// The hook and its commands are added too if they do not exist.
+func insertLefthookCommand(lines []string, name string, binary string) (_ []string, err error) {
+	run := fmt.Sprintf("run: %s hook run %s {0}", binary, name)
+
+	i, value := yamlKey(lines, name, 0, 0, len(lines))
+	if i < 0 {
+		// Keep one empty line between the existing hooks and the new one.
+		if len(lines) != 0 {
+			lines = append(lines, "")
+		}
+
+		return append(lines, indentedBlock([]string{name + ":", "  commands:", "    " + lefthookCommand + ":", "      " + run}, 0)...), nil
+	}
+
+	if len(value) != 0 {
+		return nil, fmt.Errorf("%w: %s is not a block mapping", errManagerConfig, name)
+	}
+
+	end := yamlSectionEnd(lines, i, 0)
+	step := yamlChildIndent(lines, i+1, end, 2)
+
+	j, value := yamlKey(lines, "commands", step, i+1, end)
+	if j < 0 {
+		return insertLines(lines, i+1, indentedBlock([]string{
+			strings.Repeat(" ", step) + "commands:",
+			strings.Repeat(" ", 2*step) + lefthookCommand + ":",
+			strings.Repeat(" ", 3*step) + run,
+		}, step)), nil
+	}
+
+	if len(value) != 0 {
+		return nil, fmt.Errorf("%w: %s.commands is not a block mapping", errManagerConfig, name)
+	}
+
+	commandsEnd := yamlSectionEnd(lines, j, step)
+	indent := yamlChildIndent(lines, j+1, commandsEnd, 2*step)
+
+	if k, _ := yamlKey(lines, lefthookCommand, indent, j+1, commandsEnd); k >= 0 {
+		return nil, fmt.Errorf("%w: %s already has a command %s which gogit did not add, line %d",
+			errManagerConfig, name, lefthookCommand, k+1)
+	}
+
+	return insertLines(lines, j+1, indentedBlock([]string{
+		strings.Repeat(" ", indent) + lefthookCommand + ":",
+		strings.Repeat(" ", indent+step) + run,
+	}, indent)), nil
+}
+
+func removeLefthook(base string) (err error) {
+	file, exists, err := lefthookConfig(base)
+	if err != nil || !exists {
+		return
+	}
+
+	return removeConfig(file, func(lines []string) bool {
+		return len(yamlContentLines(lines)) == 0
+	})
+}
+
+// huskyPreamble returns the lines husky before version 9 expects at the beginning of its scripts.
+func huskyPreamble(dir string) (preamble string, err error) {
+	exists, err := helper.DoesPathExistErr(filepath.Join(dir, "_", "husky.sh"))
+	if err != nil || !exists {
+		return
+	}
+
+	return "#!/usr/bin/env sh\n. \"$(dirname -- \"$0\")/_/husky.sh\"\n\n", nil
+}
+
+func installHusky(base string, opts Options) (reports []HookReport, err error) {
+	dir := filepath.Join(base, huskyDir)
+
+	exists, err := helper.DoesPathExistErr(dir)
+	if err != nil {
+		return
+	}
+
+	if !exists {
+		return nil, fmt.Errorf("%w: %#v does not exist, set up husky first", errManagerNotSetUp, dir)
+	}
+
+	preamble, err := huskyPreamble(dir)
+	if err != nil {
+		return
+	}
+
+	for _, name := range hook.Names() {
+		report := HookReport{Hook: filepath.Join(dir, name)}
+		body := []string{fmt.Sprintf(`%s hook run %s "$@"`, opts.BaseCommand, name)}
+
+		exists, err := helper.DoesPathExistErr(report.Hook)
+		if err != nil {
+			return reports, err
+		}
+
+		if exists {
+			report.Action, err = ensureBlock(report.Hook, body, opts.Repair)
+		} else {
+			report.Action = "created"
+			err = ioutil.WriteFile(report.Hook, []byte(preamble+strings.Join(blockLines(body), "\n")+"\n"), 0755)
+		}
+
+		if err != nil {
+			return reports, err
+		}
+
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+func removeHusky(base string) (err error) {
+	for _, name := range hook.Names() {
+		err = removeConfig(filepath.Join(base, huskyDir, name), func(lines []string) bool {
+			for _, l := range lines {
+				l = strings.TrimSpace(l)
+				if len(l) != 0 && !strings.HasPrefix(l, "#!") && !strings.HasSuffix(l, "/_/husky.sh\"") {
+					return false
+				}
+			}
+
+			return true
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	return nil
+}
+
+// mergeConfig removes gogit's blocks from the config file of a hook manager and lets insert add them again,
+// so merging the same entries again leaves the file unchanged. The file is created if it does not exist.
+// A block edited by hand is only overwritten with repair, else errBlockDrifted is returned.
+func mergeConfig(file string, repair bool, insert func(lines []string) ([]string, error)) (report HookReport, err error) {
+	report.Hook = file
+
+	content, err := ioutil.ReadFile(file)
+	exists := err == nil
+
+	if err != nil && !os.IsNotExist(err) {
+		return
+	}
+
+	lines := strings.Split(string(content), "\n")
+
+	blocks, err := findBlocks(lines)
+	if err != nil {
+		return report, fmt.Errorf("%#v: %w", file, err)
+	}
+
+	for _, b := range blocks {
+		if b.drifted() && !repair {
+			return report, fmt.Errorf("%#v: %w, rerun with --repair to overwrite the edits", file, errBlockDrifted)
+		}
+	}
+
+	lines, _ = removeManaged(lines, blocks, nil, -1)
+
+	if lines, err = insert(trimTrailingEmptyLines(lines)); err != nil {
+		return report, fmt.Errorf("%#v: %w", file, err)
+	}
+
+	newContent := strings.Join(lines, "\n") + "\n"
+
+	switch {
+	case !exists:
+		report.Action = "created"
+	case newContent == string(content):
+		report.Action = "unchanged"
+		return report, nil
+	case len(blocks) != 0:
+		report.Action = "updated"
+	default:
+		report.Action = "merged"
+	}
+
+	return report, ioutil.WriteFile(file, []byte(newContent), 0644)
+}
+
+// removeConfig removes gogit's blocks from the config file or script of a hook manager.
+// The file is deleted if nothing but what gogit added is left, which empty decides.
+func removeConfig(file string, empty func(lines []string) bool) (err error) {
+	content, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return
+	}
+
+	newContent, drifted, err := removeBlocks(string(content))
+	if err != nil {
+		return fmt.Errorf("%#v: %w", file, err)
+	}
+
+	if newContent == string(content) {
+		return nil
+	}
+
+	if drifted {
+		klog.InfoS("Removed gogit block which was edited by hand", "file", file)
+	}
+
+	if empty(strings.Split(newContent, "\n")) {
+		klog.InfoS("Deleted file which only held gogit's entries", "file", file)
+		return os.Remove(file)
+	}
+
+	return ioutil.WriteFile(file, []byte(newContent+"\n"), 0644)
+}
+
+// indentedBlock returns the lines of body in a managed block whose markers are indented like body.
+func indentedBlock(body []string, indent int) []string {
+	lines := blockLines(body)
+	prefix := strings.Repeat(" ", indent)
+	lines[0] = prefix + lines[0]
+	lines[len(lines)-1] = prefix + lines[len(lines)-1]
+
+	return lines
+}
+
+func insertLines(lines []string, at int, inserted []string) []string {
+	return append(lines[:at], append(inserted, lines[at:]...)...)
+}
+
+// The configs of the hook managers are merged line by line to keep their formatting and comments.
+// The helpers below understand the block style YAML the managers document.
+
+func yamlIndent(l string) int {
+	return len(l) - len(strings.TrimLeft(l, " "))
+}
+
+// yamlContent returns true if the line is neither empty nor a comment.
+func yamlContent(l string) bool {
+	l = strings.TrimSpace(l)
+	return len(l) != 0 && !strings.HasPrefix(l, "#")
+}
+
+func yamlContentLines(lines []string) (content []string) {
+	for _, l := range lines {
+		if yamlContent(l) {
+			content = append(content, l)
+		}
+	}
+
+	return content
+}
+
+// yamlKey returns the index of the line of key at indent in lines[from:to], -1 if there is none.
+// value is the value on the same line without a comment, empty for block mappings and sequences.
+func yamlKey(lines []string, key string, indent int, from int, to int) (i int, value string) {
+	for i = from; i < to; i++ {
+		l := lines[i]
+		if !yamlContent(l) || yamlIndent(l) != indent || !strings.HasPrefix(l[indent:], key+":") {
+			continue
+		}
+
+		value = strings.TrimSpace(l[indent+len(key)+1:])
+		if strings.HasPrefix(value, "#") {
+			value = ""
+		}
+
+		return i, value
+	}
+
+	return -1, ""
+}
+
+// yamlSectionEnd returns the index of the first line after the value of the key at lines[i] with indent.
+// Sequence items at the indent of the key belong to its value.
+func yamlSectionEnd(lines []string, i int, indent int) int {
+	for j := i + 1; j < len(lines); j++ {
+		l := lines[j]
+		if !yamlContent(l) {
+			continue
+		}
+
+		item := strings.HasPrefix(strings.TrimSpace(l), "- ") || strings.TrimSpace(l) == "-"
+		if yamlIndent(l) < indent || (yamlIndent(l) == indent && !item) {
+			return j
+		}
+	}
+
+	return len(lines)
+}
+
+// yamlChildIndent returns the indent of the first content line in lines[from:to], fallback if there is none.
+func yamlChildIndent(lines []string, from int, to int, fallback int) int {
+	for i := from; i < to; i++ {
+		if yamlContent(lines[i]) {
+			return yamlIndent(lines[i])
+		}
+	}
+
+	return fallback
+}
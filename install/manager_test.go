@@ -0,0 +1,255 @@
+package install
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"aduu.dev/tools/gogit/hook"
+)
+
+// indented returns body in a managed block indented like in the configs of the hook managers.
+func indented(indent int, body ...string) string {
+	return strings.Join(indentedBlock(body, indent), "\n")
+}
+
+func lefthookHook(name string) string {
+	return indented(0, name+":", "  commands:", "    gogit:", "      run: gogit hook run "+name+" {0}")
+}
+
+func preCommitEntryBlock(indent int) string {
+	prefix := strings.Repeat(" ", indent)
+	entry := []string{prefix + "- repo: " + preCommitRepo, prefix + "  rev: " + preCommitRev(), prefix + "  hooks:"}
+
+	for _, name := range hook.Names() {
+		entry = append(entry, prefix+"  - id: gogit-"+name)
+	}
+
+	return indented(indent, entry...)
+}
+
+const (
+	huskyHeader   = "#!/usr/bin/env sh\n. \"$(dirname -- \"$0\")/_/husky.sh\"\n\n"
+	preCommitYAML = `# See https://pre-commit.com
+repos:
+  - repo: https://github.com/pre-commit/pre-commit-hooks
+    rev: v4.5.0
+    hooks:
+      - id: trailing-whitespace
+`
+	lefthookYAML = `pre-commit:
+  parallel: true
+  commands:
+    lint:
+      run: golangci-lint run
+
+commit-msg:
+  scripts:
+    "check.sh":
+      runner: bash
+`
+)
+
+func TestInstallManager(t *testing.T) {
+	tests := []struct {
+		name    string
+		manager string
+		files   map[string]string
+		want    map[string]string
+		wantErr error
+	}{
+		{
+			name:    "pre-commit: no config",
+			manager: ManagerPreCommit,
+			want:    map[string]string{preCommitConfigFilename: "repos:\n" + preCommitEntryBlock(0) + "\n"},
+		},
+		{
+			name:    "pre-commit: existing repos",
+			manager: ManagerPreCommit,
+			files:   map[string]string{preCommitConfigFilename: preCommitYAML},
+			want: map[string]string{preCommitConfigFilename: "# See https://pre-commit.com\nrepos:\n" + preCommitEntryBlock(2) + "\n" +
+				strings.SplitN(preCommitYAML, "repos:\n", 2)[1]},
+		},
+		{
+			name:    "pre-commit: flow sequence",
+			manager: ManagerPreCommit,
+			files:   map[string]string{preCommitConfigFilename: "repos: []\n"},
+			wantErr: errManagerConfig,
+		},
+		{
+			name:    "lefthook: no config",
+			manager: ManagerLefthook,
+			want: map[string]string{"lefthook.yml": lefthookHook(hook.PreCommit) + "\n\n" + lefthookHook(hook.CommitMsg) + "\n\n" +
+				lefthookHook(hook.PostCommit) + "\n\n" + lefthookHook(hook.PostCheckout) + "\n\n" + lefthookHook(hook.PostMerge) + "\n"},
+		},
+		{
+			name:    "lefthook: existing hooks",
+			manager: ManagerLefthook,
+			files:   map[string]string{".lefthook.yml": lefthookYAML},
+			want: map[string]string{".lefthook.yml": `pre-commit:
+  parallel: true
+  commands:
+` + indented(4, "    gogit:", "      run: gogit hook run pre-commit {0}") + `
+    lint:
+      run: golangci-lint run
+
+commit-msg:
+` + indented(2, "  commands:", "    gogit:", "      run: gogit hook run commit-msg {0}") + `
+  scripts:
+    "check.sh":
+      runner: bash
+
+` + lefthookHook(hook.PostCommit) + "\n\n" + lefthookHook(hook.PostCheckout) + "\n\n" + lefthookHook(hook.PostMerge) + "\n"},
+		},
+		{
+			name:    "lefthook: own gogit command",
+			manager: ManagerLefthook,
+			files:   map[string]string{"lefthook.yml": "pre-commit:\n  commands:\n    gogit:\n      run: gogit replace .\n"},
+			wantErr: errManagerConfig,
+		},
+		{
+			name:    "husky",
+			manager: ManagerHusky,
+			files:   map[string]string{".husky/pre-commit": "npm test\n"},
+			want: map[string]string{
+				".husky/pre-commit":    "npm test\n\n" + block(`gogit hook run pre-commit "$@"`),
+				".husky/post-checkout": block(`gogit hook run post-checkout "$@"`) + "\n",
+			},
+		},
+		{
+			name:    "husky: before version 9",
+			manager: ManagerHusky,
+			files:   map[string]string{".husky/_/husky.sh": "", ".husky/pre-commit": huskyHeader + "npm test\n"},
+			want: map[string]string{
+				".husky/pre-commit":    huskyHeader + "npm test\n\n" + block(`gogit hook run pre-commit "$@"`),
+				".husky/post-checkout": huskyHeader + block(`gogit hook run post-checkout "$@"`) + "\n",
+			},
+		},
+		{
+			name:    "husky: not set up",
+			manager: ManagerHusky,
+			wantErr: errManagerNotSetUp,
+		},
+		{
+			name:    "unknown",
+			manager: "overcommit",
+			wantErr: errUnknownManager,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := hooksTempDir(t)
+
+			for name, content := range tt.files {
+				file := filepath.Join(base, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, err := InstallManager(base, tt.manager, Options{})
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), "got %v", err)
+
+				for name, content := range tt.files {
+					fileHasContent(t, filepath.Join(base, filepath.FromSlash(name)), content, "no file should be changed")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for name, content := range tt.want {
+				fileHasContent(t, filepath.Join(base, filepath.FromSlash(name)), content, "")
+			}
+
+			// Installing again leaves the configs as they are.
+			reports, err := InstallManager(base, tt.manager, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, report := range reports {
+				assert.Equal(t, "unchanged", report.Action, report.Hook)
+			}
+
+			for name, content := range tt.want {
+				fileHasContent(t, filepath.Join(base, filepath.FromSlash(name)), content, "installing again")
+			}
+
+			if err = RemoveManager(base, tt.manager); err != nil {
+				t.Fatal(err)
+			}
+
+			for name := range tt.want {
+				file := filepath.Join(base, filepath.FromSlash(name))
+
+				content, existed := tt.files[name]
+				if !existed {
+					assert.NoFileExists(t, file, "files which only held gogit's entries should be deleted")
+					continue
+				}
+
+				fileHasContent(t, file, content, "removing should restore the other entries")
+			}
+		})
+	}
+}
+
+func TestInstallManager_drifted(t *testing.T) {
+	base := hooksTempDir(t)
+
+	if _, err := InstallManager(base, ManagerLefthook, Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(base, "lefthook.yml")
+
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edited := strings.Replace(string(content), "{0}", "--verbose {0}", 1)
+	if err = ioutil.WriteFile(file, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = InstallManager(base, ManagerLefthook, Options{})
+	assert.True(t, errors.Is(err, errBlockDrifted), "got %v", err)
+	fileHasContent(t, file, edited, "the edited config should not be changed")
+
+	reports, err := InstallManager(base, ManagerLefthook, Options{Repair: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "updated", reports[0].Action)
+	fileHasContent(t, file, string(content), "repairing should overwrite the edits")
+}
+
+func TestInstallManager_options(t *testing.T) {
+	_, err := InstallManager(hooksTempDir(t), ManagerLefthook, Options{Pin: true})
+	assert.True(t, errors.Is(err, errManagerOption), "got %v", err)
+}
+
+func Test_yamlSectionEnd(t *testing.T) {
+	lines := strings.Split(preCommitYAML, "\n")
+
+	assert.Equal(t, len(lines), yamlSectionEnd(lines, 1, 0), "the sequence should end with the file")
+	assert.Equal(t, 4, yamlSectionEnd(strings.Split("a:\n  b: 1\n\n  # c\nd:\n", "\n"), 0, 0))
+	assert.Equal(t, 2, yamlChildIndent(lines, 2, len(lines), 0))
+}
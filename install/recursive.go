@@ -0,0 +1,331 @@
+package install
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"k8s.io/klog/v2"
+
+	"aduu.dev/tools/gogit/hook"
+	"aduu.dev/tools/gogit/workspace"
+)
+
+// ExcludeFilename is the pattern file in the root of a recursive run listing the repositories to skip.
+const ExcludeFilename = ".gogit-exclude"
+
+// DefaultJobs is the number of repositories processed concurrently by default.
+const DefaultJobs = 8
+
+// Format is an output format of WriteResults.
+type Format string
+
+// Supported output formats.
+const (
+	FormatTable Format = "table"
+	FormatJSON  Format = "json"
+)
+
+// Results of a repository in a recursive run.
+const (
+	resultExcluded     = "excluded"
+	resultFailed       = "failed"
+	resultInstalled    = "installed"
+	resultRemoved      = "removed"
+	resultNotInstalled = "not installed"
+)
+
+var errUnknownFormat = fmt.Errorf("unknown format")
+
+// RecursiveOptions configures InstallRecursive and RemoveRecursive.
+type RecursiveOptions struct {
+	// ExcludeFile lists glob patterns of repository paths relative to the root which are skipped,
+	// one per line, # starts a comment. The root's ExcludeFilename is used if it is empty.
+	ExcludeFile string
+	// Jobs is the maximum number of repositories processed concurrently, DefaultJobs if it is 0.
+	Jobs int
+	// DryRun only reports what would be done.
+	DryRun bool
+}
+
+// RepoResult is the result of installing or removing the hooks of one repository.
+type RepoResult struct {
+	// Repo is the repository directory below the root.
+	Repo string `json:"repo"`
+	// Result is what was done, e.g. "installed", "would remove" or "failed".
+	Result string `json:"result"`
+	// Hooks describes what was done to each hook.
+	Hooks []string `json:"hooks,omitempty"`
+	// Error is the reason the repository failed.
+	Error string `json:"error,omitempty"`
+}
+
+// Failed returns true if the repository failed.
+func (r RepoResult) Failed() bool {
+	return len(r.Error) != 0
+}
+
+// readExcludePatterns returns the patterns in the exclude file, none if the default file does not exist.
+func readExcludePatterns(root string, excludeFile string) (patterns []string, err error) {
+	file := excludeFile
+	if len(file) == 0 {
+		file = filepath.Join(root, ExcludeFilename)
+	}
+
+	content, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) && len(excludeFile) == 0 {
+		return nil, nil
+	}
+
+	if err != nil {
+		return
+	}
+
+	for _, l := range strings.Split(string(content), "\n") {
+		l = strings.TrimSpace(l)
+		if len(l) == 0 || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		if _, err = path.Match(l, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %#v in %#v: %w", l, file, err)
+		}
+
+		patterns = append(patterns, strings.TrimSuffix(l, "/"))
+	}
+
+	return patterns, nil
+}
+
+// isExcluded returns true if a pattern matches the slash separated path relative to the root
+// or one of its parent directories.
+func isExcluded(rel string, patterns []string) bool {
+	for _, pattern := range patterns {
+		for p := rel; p != "." && p != "/"; p = path.Dir(p) {
+			if matched, _ := path.Match(pattern, p); matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// repoRoot returns the directory containing .git which dir is in, walking up to root at most.
+func repoRoot(dir string, root string) (repo string, ok bool) {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, true
+		}
+
+		if dir == root {
+			return "", false
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+
+		dir = parent
+	}
+}
+
+// FindRepos returns the git repositories containing a go.mod below root in order,
+// and the ones excluded by the patterns in the exclude file, see RecursiveOptions.ExcludeFile.
+func FindRepos(root string, excludeFile string) (repos []string, excluded []string, err error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return
+	}
+
+	patterns, err := readExcludePatterns(absRoot, excludeFile)
+	if err != nil {
+		return
+	}
+
+	modules, err := workspace.Modules([]string{absRoot})
+	if err != nil {
+		return
+	}
+
+	seen := make(map[string]bool)
+
+	for _, module := range modules {
+		repo, ok := repoRoot(module.Dir, absRoot)
+		if !ok || seen[repo] {
+			continue
+		}
+
+		seen[repo] = true
+
+		rel, err := filepath.Rel(absRoot, repo)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Keep the paths in the form root was given in.
+		repo = filepath.Join(root, rel)
+
+		if isExcluded(filepath.ToSlash(rel), patterns) {
+			excluded = append(excluded, repo)
+		} else {
+			repos = append(repos, repo)
+		}
+	}
+
+	sort.Strings(repos)
+	sort.Strings(excluded)
+
+	return repos, excluded, nil
+}
+
+// installed returns true if a hook of the repository in base contains gogit's lines.
+func installed(base string) (found bool, err error) {
+	for _, name := range hook.Names() {
+		h, err := readInstalledHook(base, name)
+		if err != nil || h.found {
+			return h.found, err
+		}
+	}
+
+	return false, nil
+}
+
+// InstallRecursive installs the hooks with opts into every repository found by FindRepos below root.
+//
+// The repositories are processed concurrently. A failing repository does not stop the others,
+// its error is reported in its result. err is only returned if the repositories cannot be found.
+func InstallRecursive(root string, opts Options, ropts RecursiveOptions) (results []RepoResult, err error) {
+	return runRecursive(root, ropts, func(repo string) (result RepoResult) {
+		if ropts.DryRun {
+			found, err := installed(repo)
+			if err != nil {
+				return RepoResult{Result: resultFailed, Error: err.Error()}
+			}
+
+			if found {
+				return RepoResult{Result: "would reinstall"}
+			}
+
+			return RepoResult{Result: "would install"}
+		}
+
+		reports, err := Install(repo, opts)
+		for _, report := range reports {
+			result.Hooks = append(result.Hooks, report.String())
+		}
+
+		if err != nil {
+			result.Result, result.Error = resultFailed, err.Error()
+			return result
+		}
+
+		result.Result = resultInstalled
+
+		return result
+	})
+}
+
+// RemoveRecursive removes the hooks from every repository found by FindRepos below root
+// which has gogit hooks, like InstallRecursive.
+func RemoveRecursive(root string, ropts RecursiveOptions) (results []RepoResult, err error) {
+	return runRecursive(root, ropts, func(repo string) RepoResult {
+		found, err := installed(repo)
+		switch {
+		case err != nil:
+			return RepoResult{Result: resultFailed, Error: err.Error()}
+		case !found:
+			return RepoResult{Result: resultNotInstalled}
+		case ropts.DryRun:
+			return RepoResult{Result: "would remove"}
+		}
+
+		if err = Remove(repo); err != nil {
+			return RepoResult{Result: resultFailed, Error: err.Error()}
+		}
+
+		return RepoResult{Result: resultRemoved}
+	})
+}
+
+// runRecursive runs run for the repositories below root with at most ropts.Jobs at a time.
+// The results are in the order of the repositories, followed by the excluded ones.
+func runRecursive(root string, ropts RecursiveOptions, run func(repo string) RepoResult) (results []RepoResult, err error) {
+	repos, excluded, err := FindRepos(root, ropts.ExcludeFile)
+	if err != nil {
+		return
+	}
+
+	jobs := ropts.Jobs
+	if jobs <= 0 {
+		jobs = DefaultJobs
+	}
+
+	klog.InfoS("Found repositories", "root", root, "repos", len(repos), "excluded", len(excluded), "jobs", jobs)
+
+	results = make([]RepoResult, len(repos))
+	limit := make(chan struct{}, jobs)
+
+	var wg sync.WaitGroup
+
+	for i, repo := range repos {
+		wg.Add(1)
+		limit <- struct{}{}
+
+		go func(i int, repo string) {
+			defer wg.Done()
+			defer func() { <-limit }()
+
+			results[i] = run(repo)
+			results[i].Repo = repo
+		}(i, repo)
+	}
+
+	wg.Wait()
+
+	for _, repo := range excluded {
+		results = append(results, RepoResult{Repo: repo, Result: resultExcluded})
+	}
+
+	return results, nil
+}
+
+// WriteResults writes the results as table or as JSON.
+func WriteResults(w io.Writer, results []RepoResult, format Format) (err error) {
+	switch format {
+	case FormatTable:
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "REPO\tRESULT\tDETAILS")
+
+		for _, r := range results {
+			details := r.Error
+			if !r.Failed() {
+				details = strings.Join(r.Hooks, ", ")
+			}
+
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Repo, r.Result, details)
+		}
+
+		return tw.Flush()
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		if results == nil {
+			results = []RepoResult{}
+		}
+
+		return encoder.Encode(results)
+	default:
+		return fmt.Errorf("%w %#v, expected one of %v or %v", errUnknownFormat, format, FormatTable, FormatJSON)
+	}
+}
@@ -0,0 +1,205 @@
+package install
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// reposTempDir creates the repositories below a temporary root.
+// Each entry maps a file relative to the root to its content, directories end in a slash.
+func reposTempDir(t *testing.T, files map[string]string) (root string) {
+	root, err := ioutil.TempDir("", "gogit-repos")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(root); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+
+		if name[len(name)-1] == '/' {
+			if err = os.MkdirAll(path, 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			continue
+		}
+
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root
+}
+
+var reposTree = map[string]string{
+	"a/.git/hooks/":           "",
+	"a/go.mod":                "module a\n",
+	"b/.git/hooks/":           "",
+	"b/api/go.mod":            "module b/api\n",
+	"b/cmd/go.mod":            "module b/cmd\n",
+	"no-module/.git/hooks/":   "",
+	"no-repo/go.mod":          "module norepo\n",
+	"no-hooks/.git/":          "",
+	"no-hooks/go.mod":         "module nohooks\n",
+	"archive/old/.git/hooks/": "",
+	"archive/old/go.mod":      "module old\n",
+	ExcludeFilename:           "# Not maintained anymore.\narchive/\n",
+}
+
+func Test_isExcluded(t *testing.T) {
+	tests := []struct {
+		rel      string
+		patterns []string
+		want     bool
+	}{
+		{rel: "a", patterns: nil, want: false},
+		{rel: "a", patterns: []string{"a"}, want: true},
+		{rel: "archive/old", patterns: []string{"archive"}, want: true},
+		{rel: "archive/old", patterns: []string{"*/old"}, want: true},
+		{rel: "src/forks-x", patterns: []string{"src/forks-*"}, want: true},
+		{rel: "archived", patterns: []string{"archive"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rel, func(t *testing.T) {
+			assert.Equal(t, tt.want, isExcluded(tt.rel, tt.patterns), "patterns %v", tt.patterns)
+		})
+	}
+}
+
+func TestFindRepos(t *testing.T) {
+	root := reposTempDir(t, reposTree)
+
+	repos, excluded, err := FindRepos(root, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{filepath.Join(root, "a"), filepath.Join(root, "b"), filepath.Join(root, "no-hooks")}, repos,
+		"the git repositories containing a go.mod should be found once")
+	assert.Equal(t, []string{filepath.Join(root, "archive", "old")}, excluded)
+
+	// An explicit exclude file replaces the one in the root.
+	excludeFile := filepath.Join(root, "exclude")
+	if err = ioutil.WriteFile(excludeFile, []byte("no-*\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repos, excluded, err = FindRepos(root, excludeFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{filepath.Join(root, "a"), filepath.Join(root, "archive", "old"), filepath.Join(root, "b")}, repos)
+	assert.Equal(t, []string{filepath.Join(root, "no-hooks")}, excluded)
+}
+
+func TestInstallRecursive(t *testing.T) {
+	root := reposTempDir(t, reposTree)
+
+	results := func(repos ...string) (results []RepoResult) {
+		for i := 0; i < len(repos); i += 2 {
+			results = append(results, RepoResult{Repo: filepath.Join(root, filepath.FromSlash(repos[i])), Result: repos[i+1]})
+		}
+
+		return results
+	}
+
+	withoutDetails := func(results []RepoResult) []RepoResult {
+		for i := range results {
+			results[i].Hooks, results[i].Error = nil, ""
+		}
+
+		return results
+	}
+
+	got, err := InstallRecursive(root, Options{BaseCommand: "gogit"}, RecursiveOptions{DryRun: true, Jobs: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, results("a", "would install", "b", "would install", "no-hooks", "would install", "archive/old", "excluded"), got)
+	assert.NoFileExists(t, preCommitFilepath(filepath.Join(root, "a")), "a dry run should not install hooks")
+
+	got, err = InstallRecursive(root, Options{BaseCommand: "gogit"}, RecursiveOptions{Jobs: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, got[0].Hooks, 5)
+	assert.Contains(t, got[2].Error, errHooksFolderDoesNotExist.Error())
+	assert.Equal(t, results("a", "installed", "b", "installed", "no-hooks", "failed", "archive/old", "excluded"), withoutDetails(got))
+	fileHasContent(t, preCommitFilepath(filepath.Join(root, "b")), "#!/bin/bash\n\n"+block(`gogit hook run pre-commit "$@"`), "")
+
+	got, err = RemoveRecursive(root, RecursiveOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, results("a", "would remove", "b", "would remove", "no-hooks", "not installed", "archive/old", "excluded"), got)
+
+	got, err = RemoveRecursive(root, RecursiveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, results("a", "removed", "b", "removed", "no-hooks", "not installed", "archive/old", "excluded"), got)
+	fileHasContent(t, preCommitFilepath(filepath.Join(root, "b")), "#!/bin/bash", "")
+}
+
+func TestWriteResults(t *testing.T) {
+	results := []RepoResult{
+		{Repo: "src/a", Result: "installed", Hooks: []string{"pre-commit: created", "post-commit: created"}},
+		{Repo: "src/longer-name", Result: "failed", Error: "hooks folder does not exist"},
+	}
+
+	var table bytes.Buffer
+	if err := WriteResults(&table, results, FormatTable); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, `REPO             RESULT     DETAILS
+src/a            installed  pre-commit: created, post-commit: created
+src/longer-name  failed     hooks folder does not exist
+`, table.String())
+
+	var json bytes.Buffer
+	if err := WriteResults(&json, results, FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, `[
+  {
+    "repo": "src/a",
+    "result": "installed",
+    "hooks": [
+      "pre-commit: created",
+      "post-commit: created"
+    ]
+  },
+  {
+    "repo": "src/longer-name",
+    "result": "failed",
+    "error": "hooks folder does not exist"
+  }
+]
+`, json.String())
+
+	assert.Error(t, WriteResults(&json, results, "yaml"))
+}
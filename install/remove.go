@@ -2,14 +2,19 @@ package install
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 
 	"aduu.dev/utils/helper"
 	"k8s.io/klog/v2"
+
+	"aduu.dev/tools/gogit/config"
 )
 
-// Remove removes the line containing defaultBashComment from the pre-commit
-// and post-commit hooks residing under the base path.
+// Remove removes gogit's blocks and legacy lines from the pre-commit, commit-msg,
+// post-commit, post-checkout and post-merge hooks residing under the base path.
+//
+// Only the pre-commit and post-commit hooks have to exist, the others are not always installed.
 func Remove(base string) (err error) {
 	hooksFolder := filepath.Join(base, hooksPath())
 
@@ -23,18 +28,84 @@ func Remove(base string) (err error) {
 		return fmt.Errorf("the hooks folder does not exist")
 	}
 
-	if err = EnsureRemoveComment(preCommitFilepath(base), defaultBashComment); err != nil {
+	l, err := lockHooks(base)
+	if err != nil {
+		return
+	}
+
+	defer release(l)
+
+	if err = removeLine(preCommitFilepath(base)); err != nil {
+		return
+	}
+
+	if err = removeLine(postCommitFilepath(base)); err != nil {
+		return
+	}
+
+	if err = removeLineIfExists(commitMsgFilepath(base)); err != nil {
+		return
+	}
+
+	if err = removeLineIfExists(postCheckoutFilepath(base)); err != nil {
+		return
+	}
+
+	if err = removeLineIfExists(postMergeFilepath(base)); err != nil {
+		return
+	}
+
+	// The configuration of the clone only holds the install-hooks flags.
+	localConfig, err := config.LocalFilepath(base)
+	if err != nil {
 		return
 	}
 
-	if err = EnsureRemoveComment(postCommitFilepath(base), defaultBashComment); err != nil {
+	if err = os.Remove(localConfig); err != nil && !os.IsNotExist(err) {
 		return
 	}
 
 	klog.InfoS("Removed gogit replace lines",
 		"from-pre-commit", preCommitFilepath(base),
+		"from-commit-msg", commitMsgFilepath(base),
 		"from-post-commit", postCommitFilepath(base),
+		"from-post-checkout", postCheckoutFilepath(base),
+		"from-post-merge", postMergeFilepath(base),
 	)
 
 	return nil
 }
+
+// removeLine removes gogit's blocks and legacy lines from the hook file,
+// or gogit's step if the hook is a dispatcher.
+func removeLine(hookFile string) (err error) {
+	dispatcher, err := isDispatcher(hookFile)
+	if err != nil {
+		return
+	}
+
+	if dispatcher {
+		return undispatch(hookFile)
+	}
+
+	drifted, err := ensureNoBlock(hookFile)
+	if err != nil {
+		return
+	}
+
+	if drifted {
+		klog.InfoS("Removed gogit block which was edited by hand", "hook", hookFile)
+	}
+
+	return nil
+}
+
+// removeLineIfExists is removeLine for hooks which are not always installed.
+func removeLineIfExists(hookFile string) (err error) {
+	exists, err := helper.DoesPathExistErr(hookFile)
+	if err != nil || !exists {
+		return
+	}
+
+	return removeLine(hookFile)
+}
@@ -0,0 +1,38 @@
+package install
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// HookReport describes how gogit's line was installed into a hook.
+type HookReport struct {
+	// Hook is the path of the hook file, or of the config of a hook manager, see InstallManager.
+	Hook string
+	// Interpreter is the interpreter of the hook which existed before, empty if there was none.
+	Interpreter string
+	// Action describes what was done, e.g. "appended".
+	Action string
+	// From describes the format gogit's lines were in before, empty if it is not known.
+	From string
+}
+
+func (r HookReport) String() string {
+	s := fmt.Sprintf("%s: %s", filepath.Base(r.Hook), r.Action)
+
+	var notes []string
+	if len(r.Interpreter) != 0 {
+		notes = append(notes, fmt.Sprintf("existing %s hook", r.Interpreter))
+	}
+
+	if len(r.From) != 0 {
+		notes = append(notes, "was "+r.From)
+	}
+
+	if len(notes) != 0 {
+		s += " (" + strings.Join(notes, ", ") + ")"
+	}
+
+	return s
+}
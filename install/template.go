@@ -0,0 +1,219 @@
+package install
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// Shells hooks can be generated for.
+const (
+	ShellBash       = "bash"
+	ShellSh         = "sh"
+	ShellPowerShell = "powershell"
+)
+
+var errUnknownShell = fmt.Errorf("unknown hook shell")
+
+// templateData are the variables available to hook templates.
+type templateData struct {
+	// Hook is the name of the hook, e.g. pre-commit.
+	Hook string
+	// Binary is the gogit command.
+	Binary string
+	// Modules are the module directories the hook works on.
+	Modules []string
+	// Mode is config.ModeBackup or config.ModeCommentOut.
+	Mode string
+	// LogLevel is the klog verbosity, empty for gogit's default.
+	LogLevel string
+	// Pinned is true if Binary is an absolute path, the hook checks that it exists.
+	Pinned bool
+	// Fallback is the module version the hook runs with go run if Binary does not exist, empty for none.
+	Fallback string
+
+	VerifyStaged bool
+	Dependents   bool
+	Profiles     bool
+	Overlay      bool
+}
+
+// defaultTemplate is the template of hooks without a template of their own.
+const defaultTemplate = "hook"
+
+// missingBinaryMessage is printed by hooks whose binary does not exist.
+const missingBinaryMessage = `{{printf "gogit: %s not found, reinstall gogit or rerun gogit install-hooks in the repository (git commit --no-verify skips the hooks)" .Binary | quote}}`
+
+// posixTemplates run in bash and in POSIX sh.
+//
+// Pinned hooks fail with an actionable message if the binary is gone instead of "command not found".
+var posixTemplates = map[string]string{
+	"": `
+{{- define "gogit"}}{{quote .Binary}}{{if .LogLevel}} --v={{quote .LogLevel}}{{end}}{{end}}
+{{- define "fallback"}}go run {{quote .Fallback}}{{if .LogLevel}} --v={{quote .LogLevel}}{{end}}{{end}}
+{{- define "mode"}}{{if eq .Mode "comment-out"}} --comment-out{{end}}{{end}}`,
+
+	defaultTemplate: `
+{{- if or .Pinned .Fallback}}
+if command -v {{quote .Binary}} >/dev/null 2>&1; then
+	{{template "gogit" .}} hook run {{quote .Hook}} "$@"
+{{- if .Fallback}}
+elif command -v go >/dev/null 2>&1; then
+	{{template "fallback" .}} hook run {{quote .Hook}} "$@"
+{{- end}}
+else
+	echo ` + missingBinaryMessage + ` >&2
+	exit 1
+fi
+{{- else}}
+{{template "gogit" .}} hook run {{quote .Hook}} "$@"
+{{- end}}`,
+}
+
+// powerShellTemplates exit with the exit code of gogit.
+var powerShellTemplates = map[string]string{
+	"": `
+{{- define "gogit"}}& {{quote .Binary}}{{if .LogLevel}} --v={{quote .LogLevel}}{{end}}{{end}}
+{{- define "fallback"}}& go run {{quote .Fallback}}{{if .LogLevel}} --v={{quote .LogLevel}}{{end}}{{end}}
+{{- define "mode"}}{{if eq .Mode "comment-out"}} --comment-out{{end}}{{end}}
+{{- define "check"}}if ($LASTEXITCODE -ne 0) { exit $LASTEXITCODE }{{end}}`,
+
+	defaultTemplate: `
+{{- if or .Pinned .Fallback}}
+if (Get-Command {{quote .Binary}} -ErrorAction SilentlyContinue) {
+	{{template "gogit" .}} hook run {{quote .Hook}} @args
+{{- if .Fallback}}
+} elseif (Get-Command go -ErrorAction SilentlyContinue) {
+	{{template "fallback" .}} hook run {{quote .Hook}} @args
+{{- end}}
+} else {
+	[Console]::Error.WriteLine(` + missingBinaryMessage + `)
+	exit 1
+}
+{{- else}}
+{{template "gogit" .}} hook run {{quote .Hook}} @args
+{{- end}}
+{{template "check"}}`,
+}
+
+var builtinTemplates = map[string]map[string]string{
+	ShellBash:       posixTemplates,
+	ShellSh:         posixTemplates,
+	ShellPowerShell: powerShellTemplates,
+}
+
+var shebangs = map[string]string{
+	ShellBash:       "#!/bin/bash",
+	ShellSh:         "#!/bin/sh",
+	ShellPowerShell: "#!/usr/bin/env pwsh",
+}
+
+// interpreters are the interpreters which can run hooks generated for a shell.
+var interpreters = map[string]map[string]bool{
+	ShellBash:       posixShells,
+	ShellSh:         posixShells,
+	ShellPowerShell: {"pwsh": true, "powershell": true},
+}
+
+var (
+	posixBareWord      = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+	powerShellBareWord = regexp.MustCompile(`^[A-Za-z0-9_%+=:./\\-]+$`)
+)
+
+// posixQuote quotes s as a single word for POSIX shells.
+func posixQuote(s string) string {
+	if posixBareWord.MatchString(s) {
+		return s
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// powerShellQuote quotes s as a single verbatim string for PowerShell.
+func powerShellQuote(s string) string {
+	if powerShellBareWord.MatchString(s) {
+		return s
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+var quoteFuncs = map[string]func(string) string{
+	ShellBash:       posixQuote,
+	ShellSh:         posixQuote,
+	ShellPowerShell: powerShellQuote,
+}
+
+// hookTemplates are the parsed templates of a shell, named by hook.
+type hookTemplates struct {
+	templates *template.Template
+}
+
+// parseHookTemplates parses the built-in templates of the shell.
+// overrides maps hook names to template files relative to base which replace the built-in one.
+//
+// The overrides can use the templates "gogit", "fallback" and "mode" defined by the built-in templates,
+// the PowerShell ones also "check".
+func parseHookTemplates(base string, shell string, overrides map[string]string) (t hookTemplates, err error) {
+	builtin, ok := builtinTemplates[shell]
+	if !ok {
+		return t, fmt.Errorf("%w %#v: use %s, %s or %s", errUnknownShell, shell, ShellBash, ShellSh, ShellPowerShell)
+	}
+
+	t = hookTemplates{
+		templates: template.New("").Funcs(template.FuncMap{"quote": quoteFuncs[shell]}),
+	}
+
+	if _, err = t.templates.Parse(builtin[""]); err != nil {
+		return
+	}
+
+	texts := make(map[string]string)
+
+	for hook, text := range builtin {
+		if len(hook) != 0 {
+			texts[hook] = text
+		}
+	}
+
+	for hook, file := range overrides {
+		data, err := ioutil.ReadFile(filepath.Join(base, file))
+		if err != nil {
+			return t, fmt.Errorf("failed to read template for %s: %w", hook, err)
+		}
+
+		texts[hook] = string(data)
+	}
+
+	for hook, text := range texts {
+		if _, err = t.templates.New(hook).Parse(text); err != nil {
+			return t, fmt.Errorf("failed to parse template for %s: %w", hook, err)
+		}
+	}
+
+	return t, nil
+}
+
+// render returns the lines the template of the hook generates.
+func (t hookTemplates) render(data templateData) (body []string, err error) {
+	hook := t.templates.Lookup(data.Hook)
+	if hook == nil {
+		hook = t.templates.Lookup(defaultTemplate)
+	}
+
+	var out bytes.Buffer
+	if err = hook.Execute(&out, data); err != nil {
+		return nil, fmt.Errorf("failed to generate %s hook: %w", data.Hook, err)
+	}
+
+	return strings.Split(strings.Trim(out.String(), "\n"), "\n"), nil
+}
+
+// newHookFile returns a new hook for the shell running body in a managed block.
+func newHookFile(shell string, body []string) []byte {
+	return []byte(shebangs[shell] + "\n\n" + strings.Join(blockLines(body), "\n"))
+}
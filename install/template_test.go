@@ -0,0 +1,145 @@
+package install
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"aduu.dev/tools/gogit/config"
+	"aduu.dev/tools/gogit/hook"
+	"aduu.dev/tools/gogit/version"
+)
+
+func Test_hookTemplates_golden(t *testing.T) {
+	templatesFilepath := filepath.Join("testdata", "templates")
+
+	dir, err := ioutil.ReadDir(templatesFilepath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range dir {
+		base := filepath.Join(templatesFilepath, test.Name())
+
+		raw, err := ioutil.ReadFile(filepath.Join(base, "data.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var data templateData
+		if err = json.Unmarshal(raw, &data); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, shell := range []string{ShellBash, ShellSh, ShellPowerShell} {
+			templates, err := parseHookTemplates(base, shell, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, name := range hook.Names() {
+				t.Run(filepath.Join(test.Name(), shell, name), func(t *testing.T) {
+					want, err := ioutil.ReadFile(filepath.Join(base, shell, name))
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					data.Hook = name
+
+					body, err := templates.render(data)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					// The golden files are independent of the version gogit is built with.
+					got := strings.Replace(string(newHookFile(shell, body)), versionPrefix+version.Get()+" ", versionPrefix+version.Devel+" ", 1)
+
+					assert.Equal(t, strings.TrimSuffix(string(want), "\n"), got)
+				})
+			}
+		}
+	}
+}
+
+func Test_posixQuote(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not installed")
+	}
+
+	for _, s := range []string{"gogit", "", "a b", "it's", `"$HOME"`, "`id`", "a\nb", `back\slash`, "*", "x;y"} {
+		out, err := exec.Command(sh, "-c", "printf %s "+posixQuote(s)).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, s, string(out), "quoted as %s", posixQuote(s))
+	}
+}
+
+func Test_powerShellQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "gogit", want: "gogit"},
+		{in: `C:\tools\gogit.exe`, want: `C:\tools\gogit.exe`},
+		{in: "", want: "''"},
+		{in: "it's", want: "'it''s'"},
+		{in: "$env:HOME", want: "'$env:HOME'"},
+		{in: "@args", want: "'@args'"},
+		{in: "a,b", want: "'a,b'"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, powerShellQuote(tt.in))
+	}
+}
+
+func TestHooksWithOptions_config(t *testing.T) {
+	base := hooksTempDir(t)
+
+	cfg := `{
+	"shell": "sh",
+	"binary": "/opt/go bin/gogit",
+	"modules": ["api"],
+	"mode": "comment-out",
+	"logLevel": 0,
+	"templates": {"post-commit": "hooks/post-commit.tmpl"}
+}`
+	if err := ioutil.WriteFile(config.Filepath(base), []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(base, "hooks"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := `{{range .Modules}}echo {{quote .}}{{template "mode" $}} && {{end}}{{template "gogit" .}} hook run {{quote .Hook}} "$@"`
+	if err := ioutil.WriteFile(filepath.Join(base, "hooks", "post-commit.tmpl"), []byte(tmpl), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := HooksWithOptions(base, Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	fileHasContent(t, preCommitFilepath(base), "#!/bin/sh\n\n"+block(`'/opt/go bin/gogit' --v=0 hook run pre-commit "$@"`),
+		"pre-commit should be generated from the config")
+	fileHasContent(t, postCommitFilepath(base), "#!/bin/sh\n\n"+block(`echo api --comment-out && '/opt/go bin/gogit' --v=0 hook run post-commit "$@"`),
+		"post-commit should be generated from the template of the config")
+}
+
+func TestHooksWithOptions_unknown_shell(t *testing.T) {
+	base := hooksTempDir(t)
+
+	_, err := Install(base, Options{Shell: "fish"})
+	assert.True(t, errors.Is(err, errUnknownShell), "got %v", err)
+}
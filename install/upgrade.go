@@ -0,0 +1,374 @@
+package install
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"aduu.dev/utils/helper"
+	"k8s.io/klog/v2"
+
+	"aduu.dev/tools/gogit/config"
+	"aduu.dev/tools/gogit/hook"
+	"aduu.dev/tools/gogit/version"
+)
+
+// UpgradeReport describes what Upgrade did to the hooks of a repository.
+type UpgradeReport struct {
+	// Repo is the repository path as given to Upgrade.
+	Repo string
+	// Installed is false if the repository has no gogit hooks, they are not touched then.
+	Installed bool
+	// DryRun is true if the hooks were only checked.
+	DryRun bool
+	// Hooks describes what was done to each hook.
+	Hooks []HookReport
+}
+
+// Upgraded returns the number of hooks which were changed.
+func (r UpgradeReport) Upgraded() (n int) {
+	for _, h := range r.Hooks {
+		if h.Action != actionUnchanged {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (r UpgradeReport) String() string {
+	if !r.Installed {
+		return fmt.Sprintf("%s: no gogit hooks installed", r.Repo)
+	}
+
+	verb := "upgraded"
+	if r.DryRun {
+		verb = "would upgrade"
+	}
+
+	lines := []string{fmt.Sprintf("%s: %s %d of %d hooks to %s",
+		r.Repo, verb, r.Upgraded(), len(r.Hooks), formatString(currentFormat, version.Get()))}
+
+	for _, h := range r.Hooks {
+		lines = append(lines, "\t"+h.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+const actionUnchanged = "unchanged"
+
+// formatString describes the format of gogit's lines in a hook.
+func formatString(format int, gogitVersion string) string {
+	switch {
+	case format == legacyFormat:
+		return "legacy line"
+	case len(gogitVersion) == 0:
+		return fmt.Sprintf("format %d", format)
+	default:
+		return fmt.Sprintf("format %d by gogit %s", format, gogitVersion)
+	}
+}
+
+// installedFormat returns the format of gogit's lines in content and the version of gogit which wrote them.
+// If there are several, the oldest is returned. found is false if content has no gogit lines.
+func installedFormat(content string) (format int, gogitVersion string, found bool, err error) {
+	lines := strings.Split(content, "\n")
+
+	blocks, err := findBlocks(lines)
+	if err != nil {
+		return
+	}
+
+	legacy, err := legacyLines(lines, blocks)
+	if err != nil {
+		return
+	}
+
+	if len(legacy) != 0 {
+		return legacyFormat, "", true, nil
+	}
+
+	for i, b := range blocks {
+		if i == 0 || b.format < format {
+			format, gogitVersion = b.format, b.version
+		}
+	}
+
+	return format, gogitVersion, len(blocks) != 0, nil
+}
+
+// installedHook is a hook file gogit writes into as found before upgrading it.
+type installedHook struct {
+	name     string
+	hookFile string
+	// file is the hook file, or gogit's step if the hook is a dispatcher.
+	file       string
+	dispatcher bool
+	content    string
+	exists     bool
+	// format and version of gogit's lines, found is false if there are none.
+	format  int
+	version string
+	found   bool
+}
+
+func readInstalledHook(base string, name string) (h installedHook, err error) {
+	h = installedHook{name: name, hookFile: filepath.Join(base, hooksPath(), name)}
+	h.file = h.hookFile
+
+	if h.dispatcher, err = isDispatcher(h.hookFile); err != nil {
+		return
+	}
+
+	if h.dispatcher {
+		h.file = filepath.Join(stepsDir(h.hookFile), gogitStepName)
+	}
+
+	content, err := ioutil.ReadFile(h.file)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+
+	if err != nil {
+		return
+	}
+
+	h.content, h.exists = string(content), true
+	h.format, h.version, h.found, err = installedFormat(h.content)
+
+	return h, err
+}
+
+// gogitLines returns gogit's lines in the hook, the legacy lines and the bodies of the blocks.
+func (h installedHook) gogitLines() (gogitLines []string) {
+	lines := strings.Split(h.content, "\n")
+
+	blocks, err := findBlocks(lines)
+	if err != nil {
+		return nil
+	}
+
+	legacy, err := legacyLines(lines, blocks)
+	if err != nil {
+		return nil
+	}
+
+	for _, i := range legacy {
+		gogitLines = append(gogitLines, lines[i])
+	}
+
+	for _, b := range blocks {
+		gogitLines = append(gogitLines, b.body...)
+	}
+
+	return gogitLines
+}
+
+// inferOptions returns the options hooks were installed with before the configuration of the clone existed.
+// Those hooks ran the gogit commands directly, so the features are recognized by the commands.
+// The base command is taken from the first line if it is a plain word.
+func inferOptions(hooks []installedHook) (opts Options) {
+	for _, h := range hooks {
+		for _, l := range h.gogitLines() {
+			fields := strings.Fields(l)
+			if len(opts.BaseCommand) == 0 && len(fields) != 0 && !strings.ContainsAny(fields[0], `'"&$`) && fields[0] != "gogit" {
+				opts.BaseCommand = fields[0]
+			}
+
+			opts.CommentOut = opts.CommentOut || strings.Contains(l, "--comment-out")
+			opts.VerifyStaged = opts.VerifyStaged || strings.Contains(l, " verify-staged ")
+			opts.Dependents = opts.Dependents || strings.Contains(l, " dependents --build ")
+			opts.Profiles = opts.Profiles || strings.Contains(l, " profile post-checkout ")
+			opts.Overlay = opts.Overlay || strings.Contains(l, " overlay sync ")
+		}
+	}
+
+	return opts
+}
+
+// Upgrade rewrites gogit's lines in the hooks of the repository in base to the current format,
+// including the legacy lines of earlier versions, and installs the hooks which are missing.
+//
+// Repositories without gogit hooks are not touched. The hooks keep the options they were installed with:
+// from the configuration of the clone or, for hooks installed before it existed, from their lines.
+// With dryRun nothing is written and the report tells what would be done.
+func Upgrade(base string, dryRun bool) (report UpgradeReport, err error) {
+	report = UpgradeReport{Repo: base, DryRun: dryRun}
+
+	exists, err := helper.DoesPathExistErr(filepath.Join(base, hooksPath()))
+	if err != nil {
+		return
+	}
+
+	if !exists {
+		return report, errHooksFolderDoesNotExist
+	}
+
+	l, err := lockHooks(base)
+	if err != nil {
+		return
+	}
+
+	defer release(l)
+
+	var hooks []installedHook
+
+	for _, name := range hook.Names() {
+		h, err := readInstalledHook(base, name)
+		if err != nil {
+			return report, err
+		}
+
+		report.Installed = report.Installed || h.found
+		hooks = append(hooks, h)
+	}
+
+	if !report.Installed {
+		return report, nil
+	}
+
+	opts, cfg, inferred, err := upgradeOptions(base, hooks)
+	if err != nil {
+		return
+	}
+
+	templates, err := parseHookTemplates(base, opts.Shell, cfg.Templates)
+	if err != nil {
+		return
+	}
+
+	// Check every hook before writing any, so a hook which cannot be upgraded leaves all of them as they are.
+	bodies := make([][]string, len(hooks))
+
+	for i, h := range hooks {
+		if bodies[i], err = templates.render(opts.templateData(h.name)); err != nil {
+			return report, err
+		}
+
+		hookReport, err := planUpgrade(h, bodies[i], opts)
+		if err != nil {
+			return report, err
+		}
+
+		report.Hooks = append(report.Hooks, hookReport)
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	// The hooks only run "gogit hook run <name>", the options have to be kept in the configuration of the clone.
+	if inferred != nil {
+		if err = config.SaveLocal(base, *inferred); err != nil {
+			return
+		}
+	}
+
+	installHook := installLine
+	if opts.Dispatcher {
+		installHook = dispatchLine
+	}
+
+	for i, h := range hooks {
+		if report.Hooks[i].Action == actionUnchanged {
+			continue
+		}
+
+		if _, err = installHook(h.hookFile, bodies[i], opts); err != nil {
+			return report, err
+		}
+	}
+
+	klog.InfoS("Upgraded hooks", "repo", base, "upgraded", report.Upgraded(), "format", currentFormat, "version", version.Get())
+
+	return report, nil
+}
+
+// upgradeOptions returns the options the hooks were installed with and the configuration of the repository.
+//
+// inferred is the configuration of the clone for hooks installed before it existed, nil if it exists.
+func upgradeOptions(base string, hooks []installedHook) (opts Options, cfg config.Config, inferred *config.Config, err error) {
+	localConfig, err := config.LocalFilepath(base)
+	if err != nil {
+		return
+	}
+
+	exists, err := helper.DoesPathExistErr(localConfig)
+	if err != nil {
+		return
+	}
+
+	if !exists {
+		opts = inferOptions(hooks)
+
+		klog.InfoS("Inferred options of hooks installed by an earlier gogit", "repo", base,
+			"base-command", opts.BaseCommand,
+			"comment-out", opts.CommentOut,
+			"verify-staged", opts.VerifyStaged,
+			"dependents", opts.Dependents,
+			"profiles", opts.Profiles,
+			"overlay", opts.Overlay,
+		)
+
+		local, err := opts.localConfig(config.Config{})
+		if err != nil {
+			return opts, cfg, nil, err
+		}
+
+		inferred = &local
+	}
+
+	for _, h := range hooks {
+		opts.Dispatcher = opts.Dispatcher || h.dispatcher
+	}
+
+	if cfg, err = config.Load(base); err != nil {
+		return
+	}
+
+	opts, err = opts.withConfig(cfg).pinned()
+
+	return opts, cfg, inferred, err
+}
+
+// planUpgrade returns what upgrading the hook to body will do without writing anything.
+func planUpgrade(h installedHook, body []string, opts Options) (report HookReport, err error) {
+	report = HookReport{Hook: h.hookFile, Action: actionUnchanged}
+	if h.found {
+		report.From = formatString(h.format, h.version)
+	}
+
+	switch {
+	case h.dispatcher && !h.exists:
+		report.Action = "added step to dispatcher"
+	case h.dispatcher:
+		if h.content != string(newHookFile(opts.Shell, body)) {
+			report.Action = "updated step " + gogitStepName
+		}
+	case !h.exists && opts.Dispatcher:
+		report.Action = "created dispatcher"
+	case !h.exists:
+		report.Action = "created"
+	case opts.Dispatcher:
+		report.Action = "moved to " + filepath.Join(filepath.Base(stepsDir(h.hookFile)), originalStepName) + " behind dispatcher"
+		report.Interpreter = analyzeHook(h.content).Interpreter
+	default:
+		if report.Interpreter, err = checkShell(h.file, h.content, opts.Shell); err != nil {
+			return
+		}
+
+		newContent, action, err := addBlock(h.content, body, false)
+		if err != nil {
+			return report, fmt.Errorf("%#v: %w", h.file, err)
+		}
+
+		if newContent != h.content {
+			report.Action = action
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,174 @@
+package install
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"aduu.dev/tools/gogit/config"
+	"aduu.dev/tools/gogit/hook"
+)
+
+func TestUpgrade(t *testing.T) {
+	legacyHooks := map[string]string{
+		hook.PreCommit:  "#!/bin/bash\n\nmy-gogit replace --replace-only-if-staged --comment-out . # " + defaultBashComment,
+		hook.PostCommit: "#!/bin/bash\n\nmy-gogit replace --replace-only-if-staged --undo --note --comment-out . # " + defaultBashComment,
+	}
+
+	// checksumBlock returns line in a block of checksumFormat.
+	checksumBlock := func(line string) string {
+		return "# >>> gogit >>> " + blockChecksum([]string{line}) + "\n" + line + "\n# <<< gogit <<<"
+	}
+
+	checksumHooks := map[string]string{
+		hook.PreCommit:    "#!/bin/bash\n\n" + checksumBlock("gogit replace --replace-only-if-staged ."),
+		hook.PostCheckout: "#!/bin/bash\n\n" + checksumBlock(`gogit profile post-checkout . "$@"`),
+	}
+
+	upgraded := func(binary string) map[string]string {
+		hooks := make(map[string]string)
+		for _, name := range hook.Names() {
+			hooks[name] = "#!/bin/bash\n\n" + block(binary+` hook run `+name+` "$@"`)
+		}
+
+		return hooks
+	}
+
+	tests := []struct {
+		name          string
+		hooks         map[string]string
+		dryRun        bool
+		wantInstalled bool
+		wantActions   []string
+		wantFrom      []string
+		wantHooks     map[string]string
+		wantConfig    config.Config
+		wantErr       error
+	}{
+		{
+			name:          "not installed",
+			hooks:         map[string]string{hook.PreCommit: "#!/bin/sh\necho hi"},
+			wantInstalled: false,
+			wantHooks:     map[string]string{hook.PreCommit: "#!/bin/sh\necho hi"},
+		},
+		{
+			name:          "legacy lines",
+			hooks:         legacyHooks,
+			wantInstalled: true,
+			wantActions:   []string{"replaced legacy line", "created", "replaced legacy line", "created", "created"},
+			wantFrom:      []string{"legacy line", "", "legacy line", "", ""},
+			wantHooks:     upgraded("my-gogit"),
+			wantConfig:    config.Config{Binary: "my-gogit", Mode: config.ModeCommentOut},
+		},
+		{
+			name:          "legacy lines: dry run",
+			hooks:         legacyHooks,
+			dryRun:        true,
+			wantInstalled: true,
+			wantActions:   []string{"replaced legacy line", "created", "replaced legacy line", "created", "created"},
+			wantFrom:      []string{"legacy line", "", "legacy line", "", ""},
+			wantHooks:     legacyHooks,
+		},
+		{
+			name:          "checksum format",
+			hooks:         checksumHooks,
+			wantInstalled: true,
+			wantActions:   []string{"updated", "created", "created", "updated", "created"},
+			wantFrom:      []string{"format 1", "", "", "format 1", ""},
+			wantHooks:     upgraded("gogit"),
+			wantConfig:    config.Config{Profiles: boolPtr(true)},
+		},
+		{
+			name: "edited by hand",
+			hooks: map[string]string{
+				hook.PreCommit: "#!/bin/bash\n\n" + checksumBlock("gogit replace --replace-only-if-staged ."),
+				hook.PostCommit: "#!/bin/bash\n\n# >>> gogit >>> format=2 version=v0.1.0 sha256:00\n" +
+					"gogit hook run post-commit \"$@\"\n# <<< gogit <<<",
+			},
+			wantErr: errBlockDrifted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := hooksTempDir(t)
+
+			for name, content := range tt.hooks {
+				if err := ioutil.WriteFile(filepath.Join(base, hooksPath(), name), []byte(content), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			report, err := Upgrade(base, tt.dryRun)
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), "got %v", err)
+
+				for name, content := range tt.hooks {
+					fileHasContent(t, filepath.Join(base, hooksPath(), name), content, "no hook should be changed")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.wantInstalled, report.Installed)
+
+			var actions, from []string
+			for _, h := range report.Hooks {
+				actions = append(actions, h.Action)
+				from = append(from, h.From)
+			}
+
+			assert.Equal(t, tt.wantActions, actions)
+			assert.Equal(t, tt.wantFrom, from)
+
+			for _, name := range hook.Names() {
+				file := filepath.Join(base, hooksPath(), name)
+
+				want, ok := tt.wantHooks[name]
+				if !ok {
+					assert.NoFileExists(t, file, "the hook should not be created")
+					continue
+				}
+
+				fileHasContent(t, file, want, "")
+			}
+
+			cfg, err := config.Load(base)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.wantConfig, cfg, "the options of the hooks should be kept in the configuration of the clone")
+		})
+	}
+}
+
+func TestUpgrade_current(t *testing.T) {
+	base := hooksTempDir(t)
+
+	if err := HooksWithOptions(base, Options{BaseCommand: "gogit", Overlay: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Upgrade(base, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, report.Installed)
+	assert.Equal(t, 0, report.Upgraded(), "hooks of the current format should be unchanged: %s", report)
+
+	cfg, err := config.Load(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, config.IsOn(cfg.Overlay), "the configuration of the clone should be kept")
+}
@@ -0,0 +1,49 @@
+package lock
+
+import (
+	"os"
+	"time"
+)
+
+// exclusiveFilepath is the file whose existence is the lock on path where flock is not available.
+func exclusiveFilepath(path string) string {
+	return path + ".excl"
+}
+
+// tryLockExclusive locks path by creating its exclusive file, which fails while another process holds the lock.
+// The exclusive file records the holder like the lock file, so a left over one can be told apart.
+func tryLockExclusive(path string) (locked bool, err error) {
+	exclusive := exclusiveFilepath(path)
+
+	file, err := os.OpenFile(exclusive, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return
+	}
+
+	if err = writeHolder(file, Holder{PID: os.Getpid(), Since: time.Now()}); err != nil {
+		file.Close()
+		os.Remove(exclusive)
+
+		return
+	}
+
+	if err = file.Close(); err != nil {
+		os.Remove(exclusive)
+		return
+	}
+
+	return true, nil
+}
+
+// unlockExclusive releases a lock taken by tryLockExclusive.
+func unlockExclusive(path string) (err error) {
+	if err = os.Remove(exclusiveFilepath(path)); err != nil && !os.IsNotExist(err) {
+		return
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package lock
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_tryLockExclusive(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	path := filepath.Join(dir, "lock")
+
+	locked, err := tryLockExclusive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, locked)
+
+	data, err := ioutil.ReadFile(exclusiveFilepath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, strings.HasPrefix(string(data), fmt.Sprintf("%d ", os.Getpid())), "the exclusive file should name the holder")
+
+	locked, err = tryLockExclusive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.False(t, locked, "the lock is held already")
+
+	if err = unlockExclusive(path); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoFileExists(t, exclusiveFilepath(path))
+
+	locked, err = tryLockExclusive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, locked, "the released lock should be free again")
+
+	if err = unlockExclusive(path); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,181 @@
+// Package lock provides advisory file locks so concurrent gogit invocations,
+// e.g. hooks run by an IDE and a terminal at the same time, do not race on the same files.
+package lock
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// TimeoutEnv is the environment variable configuring how long to wait for a lock, e.g. "30s".
+	TimeoutEnv = "GOGIT_LOCK_TIMEOUT"
+
+	// DefaultTimeout is used if TimeoutEnv is not set.
+	DefaultTimeout = 10 * time.Second
+
+	retryInterval = 50 * time.Millisecond
+)
+
+var (
+	errLocked = fmt.Errorf("locked")
+)
+
+// Lock is a held advisory lock on a file.
+type Lock struct {
+	file *os.File
+}
+
+// Holder is the process which acquired a lock as recorded in the lock file.
+type Holder struct {
+	PID   int
+	Since time.Time
+}
+
+func (h Holder) String() string {
+	return fmt.Sprintf("pid %d since %s", h.PID, h.Since.Format(time.RFC3339))
+}
+
+// Timeout returns the timeout configured in $GOGIT_LOCK_TIMEOUT or DefaultTimeout.
+func Timeout() time.Duration {
+	value := os.Getenv(TimeoutEnv)
+	if len(value) == 0 {
+		return DefaultTimeout
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		klog.ErrorS(err, "Ignoring invalid lock timeout", "env", TimeoutEnv, "value", value)
+		return DefaultTimeout
+	}
+
+	return timeout
+}
+
+// Acquire locks the file at path, waiting at most timeout for another process to release it.
+//
+// The lock file records the pid of the holder for error messages. Where flock is available the lock
+// is released by the operating system if the holder dies and a left over record is only logged as stale.
+// Elsewhere the lock is an exclusively created file which has to be removed by hand after a crash.
+func Acquire(path string, timeout time.Duration) (l *Lock, err error) {
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			file.Close()
+		}
+	}()
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		locked, err := tryLock(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock %#v: %w", path, err)
+		}
+
+		if locked {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			return nil, lockedError(path)
+		}
+
+		time.Sleep(retryInterval)
+	}
+
+	// Release normally empties the record, a record left over means the holder died.
+	if holder, ok := readHolder(path); ok {
+		klog.InfoS("Taking over stale lock", "lock", path, "holder", holder.String())
+	}
+
+	if err = writeHolder(file, Holder{PID: os.Getpid(), Since: time.Now()}); err != nil {
+		unlock(file)
+		return
+	}
+
+	return &Lock{file: file}, nil
+}
+
+// Release releases the lock.
+func (l *Lock) Release() (err error) {
+	if err = l.file.Truncate(0); err != nil {
+		klog.ErrorS(err, "Failed to clear lock holder", "lock", l.file.Name())
+	}
+
+	if err = unlock(l.file); err != nil {
+		l.file.Close()
+		return
+	}
+
+	return l.file.Close()
+}
+
+// lockedError describes who holds the lock at path.
+func lockedError(path string) error {
+	holder, ok := readHolder(path)
+	if !ok {
+		// The holder may have died before recording itself in the lock file.
+		holder, ok = readHolder(exclusiveFilepath(path))
+	}
+
+	if !ok {
+		return fmt.Errorf("%w: %#v is locked by another process", errLocked, path)
+	}
+
+	if !processRunning(holder.PID) {
+		return fmt.Errorf("%w: %#v is locked by %s which is not running anymore, %s",
+			errLocked, path, holder, staleHint(path))
+	}
+
+	return fmt.Errorf("%w: %#v is locked by %s, set $%s to wait longer", errLocked, path, holder, TimeoutEnv)
+}
+
+func readHolder(path string) (holder Holder, ok bool) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return holder, false
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return holder, false
+	}
+
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return holder, false
+	}
+
+	since, err := time.Parse(time.RFC3339, fields[1])
+	if err != nil {
+		return holder, false
+	}
+
+	return Holder{PID: pid, Since: since}, true
+}
+
+func writeHolder(file *os.File, holder Holder) (err error) {
+	if err = file.Truncate(0); err != nil {
+		return
+	}
+
+	_, err = file.WriteAt([]byte(fmt.Sprintf("%d %s\n", holder.PID, holder.Since.Format(time.RFC3339))), 0)
+
+	return err
+}
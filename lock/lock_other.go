@@ -0,0 +1,31 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package lock
+
+import (
+	"fmt"
+	"os"
+)
+
+// Without flock the lock is an exclusively created file next to the lock file.
+// It is not released by the operating system if the holder dies.
+
+func tryLock(file *os.File) (locked bool, err error) {
+	return tryLockExclusive(file.Name())
+}
+
+func unlock(file *os.File) error {
+	return unlockExclusive(file.Name())
+}
+
+// processRunning reports whether a process with pid exists, on Windows finding it opens it.
+func processRunning(pid int) bool {
+	_, err := os.FindProcess(pid)
+	return err == nil
+}
+
+// staleHint tells how to release a lock whose holder is not running anymore.
+func staleHint(path string) string {
+	return fmt.Sprintf("remove %#v if no gogit is running", exclusiveFilepath(path))
+}
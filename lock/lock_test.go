@@ -0,0 +1,98 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquire(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	path := filepath.Join(dir, "gogit", "lock")
+
+	l, err := Acquire(path, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// flock locks belong to the open file, so a second acquisition in the same process conflicts.
+	_, err = Acquire(path, 100*time.Millisecond)
+	if assert.True(t, errors.Is(err, errLocked), "got %v", err) {
+		assert.Contains(t, err.Error(), fmt.Sprintf("locked by pid %d since ", os.Getpid()))
+	}
+
+	if err = l.Release(); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = Acquire(path, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = l.Release(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A holder which died without releasing leaves its record behind.
+	if err = ioutil.WriteFile(path, []byte("999999 2020-01-02T03:04:05Z\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = Acquire(path, time.Second)
+	if err != nil {
+		t.Fatal("a stale record must not block: ", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, strings.HasPrefix(string(data), fmt.Sprintf("%d ", os.Getpid())), "the record should name the new holder")
+
+	if err = l.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	old, had := os.LookupEnv(TimeoutEnv)
+
+	t.Cleanup(func() {
+		if had {
+			os.Setenv(TimeoutEnv, old)
+		} else {
+			os.Unsetenv(TimeoutEnv)
+		}
+	})
+
+	os.Unsetenv(TimeoutEnv)
+	assert.Equal(t, DefaultTimeout, Timeout())
+
+	os.Setenv(TimeoutEnv, "30s")
+	assert.Equal(t, 30*time.Second, Timeout())
+
+	os.Setenv(TimeoutEnv, "soon")
+	assert.Equal(t, DefaultTimeout, Timeout(), "invalid values fall back to the default")
+}
@@ -25,7 +25,24 @@ func RootCMD() *cobra.Command {
 	cmd.SetOut(os.Stdout)
 	cmd.SetErr(os.Stderr)
 	cmd.AddCommand(gogitcmd.GogitInstallHooksCMD())
+	cmd.AddCommand(gogitcmd.GogitRemoveHooksCMD())
+	cmd.AddCommand(gogitcmd.GogitUpgradeHooksCMD())
+	cmd.AddCommand(gogitcmd.GogitVersionCMD())
+	cmd.AddCommand(gogitcmd.GogitHookCMD())
 	cmd.AddCommand(gogitcmd.GogitReplaceCMD())
+	cmd.AddCommand(gogitcmd.GogitCommitMsgCMD())
+	cmd.AddCommand(gogitcmd.GogitRestoreLocalCMD())
+	cmd.AddCommand(gogitcmd.GogitProfileCMD())
+	cmd.AddCommand(gogitcmd.GogitOverlayCMD())
+	cmd.AddCommand(gogitcmd.GogitExecCMD())
+	cmd.AddCommand(gogitcmd.GogitVerifyStagedCMD())
+	cmd.AddCommand(gogitcmd.GogitGraphCMD())
+	cmd.AddCommand(gogitcmd.GogitReleasePlanCMD())
+	cmd.AddCommand(gogitcmd.GogitDependentsCMD())
+	cmd.AddCommand(gogitcmd.GogitAddReplaceCMD())
+	cmd.AddCommand(gogitcmd.GogitDropReplaceCMD())
+	cmd.AddCommand(gogitcmd.GogitEnableLocalCMD())
+	cmd.AddCommand(gogitcmd.GogitDisableLocalCMD())
 	return cmd
 }
 
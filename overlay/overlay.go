@@ -0,0 +1,234 @@
+// Package overlay merges a personal go.local.mod containing only replace directives
+// into a generated go.dev.mod, so local replace directives never have to be in go.mod.
+//
+// The go command uses the generated file with GOFLAGS=-modfile=<module>/go.dev.mod
+// and keeps its checksums in go.dev.sum.
+package overlay
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"aduu.dev/utils/helper"
+	"golang.org/x/mod/modfile"
+	"k8s.io/klog/v2"
+)
+
+var (
+	errOverlayDoesNotExist   = fmt.Errorf("overlay go.local.mod does not exist")
+	errOverlayNotOnlyReplace = fmt.Errorf("overlay go.local.mod may only contain replace directives")
+	errPathWithSpace         = fmt.Errorf("GOFLAGS can not hold a path containing white space")
+)
+
+// OverlayFilename is the name of the personal overlay file next to go.mod.
+func OverlayFilename() string {
+	return "go.local.mod"
+}
+
+// DevFilename is the name of the generated go.mod to use with -modfile.
+func DevFilename() string {
+	return "go.dev.mod"
+}
+
+// devSumFilename is the go.sum the go command uses together with DevFilename.
+func devSumFilename() string {
+	return strings.TrimSuffix(DevFilename(), ".mod") + ".sum"
+}
+
+// Env returns the environment variable assignment which makes the go command use the generated go.mod
+// of the module in base.
+//
+// The path is absolute as the go command resolves -modfile relative to its working directory,
+// which is a package directory or whatever an editor starts it in.
+func Env(base string) (env string, err error) {
+	devFilepath, err := filepath.Abs(filepath.Join(base, DevFilename()))
+	if err != nil {
+		return
+	}
+
+	// GOFLAGS is split at white space.
+	if strings.ContainsAny(devFilepath, " \t\n") {
+		return "", fmt.Errorf("%w: %#v", errPathWithSpace, devFilepath)
+	}
+
+	return "GOFLAGS=-modfile=" + devFilepath, nil
+}
+
+// Exists returns true if there is an overlay next to the go.mod in base.
+func Exists(base string) (exists bool, err error) {
+	return helper.DoesPathExistErr(filepath.Join(base, OverlayFilename()))
+}
+
+// Sync generates go.dev.mod from go.mod and the replace directives of go.local.mod in base.
+// go.dev.sum receives all checksums of go.sum and keeps the ones the go command added to it.
+func Sync(base string) (err error) {
+	overlayFilepath := filepath.Join(base, OverlayFilename())
+
+	exists, err := helper.DoesPathExistErr(overlayFilepath)
+	if err != nil {
+		return
+	}
+
+	if !exists {
+		return fmt.Errorf("%w in %#v", errOverlayDoesNotExist, base)
+	}
+
+	overlayData, err := ioutil.ReadFile(overlayFilepath)
+	if err != nil {
+		return
+	}
+
+	gomodFilepath := filepath.Join(base, "go.mod")
+
+	gomodData, err := ioutil.ReadFile(gomodFilepath)
+	if err != nil {
+		return
+	}
+
+	merged, err := merge(gomodFilepath, gomodData, overlayFilepath, overlayData)
+	if err != nil {
+		return
+	}
+
+	devFilepath := filepath.Join(base, DevFilename())
+	if err = writeIfChanged(devFilepath, merged); err != nil {
+		return
+	}
+
+	if err = syncSum(base); err != nil {
+		return
+	}
+
+	klog.InfoS("Synced overlay", "go.mod", gomodFilepath, "overlay", overlayFilepath, "generated", devFilepath)
+
+	return nil
+}
+
+// merge returns go.mod with the replace directives of the overlay added.
+// Replace directives of the overlay win over the ones in go.mod for the same module.
+func merge(gomodFilepath string, gomodData []byte, overlayFilepath string, overlayData []byte) (merged []byte, err error) {
+	file, err := modfile.Parse(gomodFilepath, gomodData, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse modfile at %#v: %w", gomodFilepath, err)
+	}
+
+	overlay, err := modfile.Parse(overlayFilepath, overlayData, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse overlay at %#v: %w", overlayFilepath, err)
+	}
+
+	if err = checkOnlyReplace(overlay); err != nil {
+		return nil, fmt.Errorf("%w: %v", err, overlayFilepath)
+	}
+
+	for _, rep := range overlay.Replace {
+		// Drop the replace for any version so the overlay fully takes over the module.
+		for _, existing := range file.Replace {
+			if existing.Old.Path == rep.Old.Path {
+				if err = file.DropReplace(existing.Old.Path, existing.Old.Version); err != nil {
+					return
+				}
+			}
+		}
+
+		if err = file.AddReplace(rep.Old.Path, rep.Old.Version, rep.New.Path, rep.New.Version); err != nil {
+			return
+		}
+	}
+
+	file.Cleanup()
+
+	return file.Format()
+}
+
+// checkOnlyReplace returns an error if the overlay contains anything but replace directives.
+func checkOnlyReplace(overlay *modfile.File) error {
+	for _, stmt := range overlay.Syntax.Stmt {
+		var verb string
+
+		switch stmt := stmt.(type) {
+		case *modfile.Line:
+			verb = stmt.Token[0]
+		case *modfile.LineBlock:
+			verb = stmt.Token[0]
+		default:
+			continue
+		}
+
+		if verb != "replace" {
+			return fmt.Errorf("%w, found %#v", errOverlayNotOnlyReplace, verb)
+		}
+	}
+
+	return nil
+}
+
+// syncSum adds all lines of go.sum to go.dev.sum.
+func syncSum(base string) (err error) {
+	sum, err := readLines(filepath.Join(base, "go.sum"))
+	if err != nil {
+		return
+	}
+
+	devSumFilepath := filepath.Join(base, devSumFilename())
+
+	devSum, err := readLines(devSumFilepath)
+	if err != nil {
+		return
+	}
+
+	seen := make(map[string]bool, len(sum)+len(devSum))
+	lines := make([]string, 0, len(sum)+len(devSum))
+
+	for _, line := range append(sum, devSum...) {
+		if seen[line] {
+			continue
+		}
+
+		seen[line] = true
+		lines = append(lines, line)
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	sort.Strings(lines)
+
+	return writeIfChanged(devSumFilepath, []byte(strings.Join(lines, "\n")+"\n"))
+}
+
+// readLines returns the non-empty lines of file. A missing file has no lines.
+func readLines(file string) (lines []string, err error) {
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if len(strings.TrimSpace(line)) != 0 {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, nil
+}
+
+// writeIfChanged writes data to file unless it has that content already.
+// Not touching the file keeps tools watching it quiet.
+func writeIfChanged(file string, data []byte) (err error) {
+	existing, err := ioutil.ReadFile(file)
+	if err == nil && string(existing) == string(data) {
+		return nil
+	}
+
+	return ioutil.WriteFile(file, data, 0644)
+}
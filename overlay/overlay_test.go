@@ -0,0 +1,169 @@
+package overlay
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_merge(t *testing.T) {
+	gomod := `module aduu.dev/k
+
+go 1.14
+
+require (
+	aduu.dev/other v1.0.0
+	aduu.dev/utils v1.0.0
+)
+
+replace aduu.dev/other => aduu.dev/fork v1.0.0
+`
+
+	tests := []struct {
+		name    string
+		overlay string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "add local replace",
+			overlay: "replace aduu.dev/utils => ../utils\n",
+			want: gomod + `
+replace aduu.dev/utils => ../utils
+`,
+		},
+		{
+			name: "overlay wins over go.mod",
+			overlay: `replace (
+	aduu.dev/other => ../other
+)
+`,
+			want: `module aduu.dev/k
+
+go 1.14
+
+require (
+	aduu.dev/other v1.0.0
+	aduu.dev/utils v1.0.0
+)
+
+replace aduu.dev/other => ../other
+`,
+		},
+		{
+			name:    "overlay may only contain replace directives",
+			overlay: "require aduu.dev/utils v1.0.0\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := merge("go.mod", []byte(gomod), OverlayFilename(), []byte(tt.overlay))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("merge() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestSync(t *testing.T) {
+	base, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(base); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if err = Sync(base); err == nil {
+		t.Fatal("Sync should fail without overlay")
+	}
+
+	files := map[string]string{
+		"go.mod":          "module aduu.dev/k\n\ngo 1.14\n\nrequire aduu.dev/utils v1.0.0\n",
+		"go.sum":          "aduu.dev/utils v1.0.0 h1:a=\n",
+		devSumFilename():  "aduu.dev/extra v1.0.0 h1:b=\n",
+		OverlayFilename(): "replace aduu.dev/utils => ../utils\n",
+	}
+
+	for name, content := range files {
+		if err = ioutil.WriteFile(filepath.Join(base, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = Sync(base); err != nil {
+		t.Fatal(err)
+	}
+
+	devMod, err := ioutil.ReadFile(filepath.Join(base, DevFilename()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, files["go.mod"]+"\nreplace aduu.dev/utils => ../utils\n", string(devMod))
+
+	devSum, err := ioutil.ReadFile(filepath.Join(base, devSumFilename()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "aduu.dev/extra v1.0.0 h1:b=\naduu.dev/utils v1.0.0 h1:a=\n", string(devSum), "go.dev.sum should keep its own checksums")
+}
+
+func TestEnv(t *testing.T) {
+	base, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(base); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.Chdir(wd); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if err = os.Chdir(base); err != nil {
+		t.Fatal(err)
+	}
+
+	abs, err := filepath.Abs(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := Env(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "GOFLAGS=-modfile="+filepath.Join(abs, DevFilename()), env, "the path should not depend on the working directory")
+
+	_, err = Env(filepath.Join(base, "with space"))
+	assert.True(t, errors.Is(err, errPathWithSpace), "got %v", err)
+}
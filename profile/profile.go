@@ -0,0 +1,314 @@
+// Package profile remembers the local replace directives of go.mod per branch
+// so they follow branch checkouts.
+//
+// Profiles are stored below the git directory in gogit/profiles/<branch>,
+// one local replace directive per line. The profiles of a module which is not at the top of the
+// repository are kept apart in gogit/profiles/.modules/<escaped module directory>/<branch>.
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"aduu.dev/utils/helper"
+	"github.com/go-git/go-git/v5"
+	"k8s.io/klog/v2"
+
+	"aduu.dev/tools/gogit/gitdir"
+	"aduu.dev/tools/gogit/replace"
+)
+
+const (
+	// currentFilename stores the branch whose local replaces are currently in go.mod.
+	// Branch names can not start with a dot so it can not clash with a profile.
+	currentFilename = ".current"
+
+	// modulesDirname holds the profiles of the modules below the top of the repository.
+	// Like currentFilename it can not clash with a branch.
+	modulesDirname = ".modules"
+
+	// branchCheckoutFlag is the third argument of the post-checkout hook for branch checkouts.
+	branchCheckoutFlag = "1"
+)
+
+var (
+	errProfileDoesNotExist = fmt.Errorf("profile does not exist")
+)
+
+// profilesDir returns the directory holding the profiles and the current branch of the module in base.
+// Every module of a repository has its own so their profiles do not overwrite each other.
+func profilesDir(base string) (dir string, err error) {
+	gogitDir, err := gitdir.Gogit(base)
+	if err != nil {
+		return
+	}
+
+	module, err := gitdir.ModuleDir(base)
+	if err != nil {
+		return
+	}
+
+	dir = filepath.Join(gogitDir, "profiles")
+	if module == "." {
+		return dir, nil
+	}
+
+	return filepath.Join(dir, modulesDirname, url.PathEscape(module)), nil
+}
+
+func profileFilepath(base string, branch string) (file string, err error) {
+	dir, err := profilesDir(base)
+	if err != nil {
+		return
+	}
+
+	return filepath.Join(dir, filepath.FromSlash(branch)), nil
+}
+
+// Save stores the local replace directives currently in the go.mod in base as profile of branch.
+//
+// Without local replace directives the profile is stored empty, so applying it removes them.
+func Save(base string, branch string) (err error) {
+	replaces, err := replace.LocalReplaces(base)
+	if err != nil {
+		return
+	}
+
+	file, err := profileFilepath(base, branch)
+	if err != nil {
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return
+	}
+
+	lines := make([]string, 0, len(replaces))
+	for _, rep := range replaces {
+		lines = append(lines, rep.String())
+	}
+
+	if err = ioutil.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return
+	}
+
+	klog.InfoS("Saved local replace profile", "branch", branch, "count", len(replaces))
+
+	return nil
+}
+
+// Show returns the local replace directives stored in the profile of branch.
+func Show(base string, branch string) (replaces []replace.LocalReplace, err error) {
+	file, err := profileFilepath(base, branch)
+	if err != nil {
+		return
+	}
+
+	exists, err := helper.DoesPathExistErr(file)
+	if err != nil {
+		return
+	}
+
+	if !exists {
+		return nil, fmt.Errorf("%w: %#v", errProfileDoesNotExist, branch)
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		rep, err := replace.ParseLocalReplace(line)
+		if err != nil {
+			return nil, fmt.Errorf("profile %#v is corrupt: %w", branch, err)
+		}
+
+		replaces = append(replaces, rep)
+	}
+
+	return replaces, nil
+}
+
+// List returns the branches which have a profile.
+func List(base string) (branches []string, err error) {
+	dir, err := profilesDir(base)
+	if err != nil {
+		return
+	}
+
+	exists, err := helper.DoesPathExistErr(dir)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Branch names can not contain components starting with a dot, these are the other modules.
+		if info.IsDir() && path != dir && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		if info.IsDir() || info.Name() == currentFilename {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		branches = append(branches, filepath.ToSlash(rel))
+
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	sort.Strings(branches)
+
+	return branches, nil
+}
+
+// Drop removes the profile of branch. Dropping a non-existing profile is no error.
+func Drop(base string, branch string) (err error) {
+	file, err := profileFilepath(base, branch)
+	if err != nil {
+		return
+	}
+
+	if err = os.Remove(file); err != nil && !os.IsNotExist(err) {
+		return
+	}
+
+	klog.InfoS("Dropped local replace profile", "branch", branch)
+
+	return nil
+}
+
+// Apply replaces the local replace directives in the go.mod in base with the profile of branch.
+//
+// If branch has no profile yet, e.g. as it was just created, the local replace directives
+// are kept and saved as its first profile.
+func Apply(base string, branch string) (err error) {
+	replaces, err := Show(base, branch)
+	if errors.Is(err, errProfileDoesNotExist) {
+		klog.InfoS("Keeping local replaces for branch without profile", "branch", branch)
+		return Save(base, branch)
+	}
+
+	if err != nil {
+		return
+	}
+
+	return replace.SetLocalReplaces(base, replaces)
+}
+
+// PostCheckout is run by the post-checkout hook with the hook's arguments.
+//
+// On branch checkouts it saves the local replace directives in go.mod as profile of the
+// previously checked out branch and applies the profile of the newly checked out branch, see Apply.
+func PostCheckout(base string, previousHead string, newHead string, flag string) (err error) {
+	if flag != branchCheckoutFlag {
+		return nil
+	}
+
+	previous, known, err := current(base)
+	if err != nil {
+		return
+	}
+
+	branch, err := currentBranch(base)
+	if err != nil {
+		return
+	}
+
+	// Without knowing which branch the local replaces in go.mod belong to do not touch them.
+	if !known {
+		klog.InfoS("Starting to track local replace profiles", "branch", branch)
+		return setCurrent(base, branch)
+	}
+
+	klog.InfoS("Switching local replace profile", "from", previous, "to", branch, "previous-head", previousHead, "new-head", newHead)
+
+	if len(previous) != 0 {
+		if err = Save(base, previous); err != nil {
+			return
+		}
+	}
+
+	// Keep the local replaces on a detached HEAD but do not attribute them to any branch.
+	if len(branch) != 0 {
+		if err = Apply(base, branch); err != nil {
+			return
+		}
+	}
+
+	return setCurrent(base, branch)
+}
+
+// currentBranch returns the short name of the checked out branch or an empty string on a detached HEAD.
+func currentBranch(base string) (branch string, err error) {
+	r, err := git.PlainOpenWithOptions(base, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return
+	}
+
+	head, err := r.Head()
+	if err != nil {
+		return
+	}
+
+	if !head.Name().IsBranch() {
+		return "", nil
+	}
+
+	return head.Name().Short(), nil
+}
+
+// current returns the branch whose local replaces are in go.mod.
+// The branch is empty for a detached HEAD. known is false if profiles were not tracked so far.
+func current(base string) (branch string, known bool, err error) {
+	dir, err := profilesDir(base)
+	if err != nil {
+		return
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, currentFilename))
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+
+	if err != nil {
+		return
+	}
+
+	return strings.TrimSpace(string(data)), true, nil
+}
+
+func setCurrent(base string, branch string) (err error) {
+	dir, err := profilesDir(base)
+	if err != nil {
+		return
+	}
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, currentFilename), []byte(branch+"\n"), 0644)
+}
@@ -0,0 +1,297 @@
+package profile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+
+	"aduu.dev/tools/gogit/replace"
+)
+
+const (
+	strippedGomod = `module aduu.dev/k
+
+go 1.14
+
+require aduu.dev/utils v1.0.0
+`
+	localGomod = strippedGomod + `
+replace aduu.dev/utils => ../utils
+`
+)
+
+func initRepo(t *testing.T, base string) *git.Repository {
+	r, err := git.PlainInit(base, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(base, "go.mod"), []byte(strippedGomod), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = w.Add("go.mod"); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := w.Commit("init", &git.CommitOptions{
+		Author: &object.Signature{Name: "gogit", Email: "gogit@aduu.dev"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = r.Storer.SetReference(plumbing.NewHashReference("refs/heads/feature", hash)); err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+// switchBranch points HEAD to the branch without touching the worktree like a checkout carrying over local changes.
+func switchBranch(t *testing.T, r *git.Repository, branch string) {
+	if err := r.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName(branch))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func localReplaces(t *testing.T, base string) []replace.LocalReplace {
+	replaces, err := replace.LocalReplaces(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return replaces
+}
+
+func TestPostCheckout(t *testing.T) {
+	base, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(base); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	r := initRepo(t, base)
+
+	// The first checkout only starts tracking.
+	if err = PostCheckout(base, "", "", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Develop against a local checkout on master.
+	if err = ioutil.WriteFile(filepath.Join(base, "go.mod"), []byte(localGomod), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// File checkouts do not switch profiles.
+	if err = PostCheckout(base, "", "", "0"); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, localReplaces(t, base), 1, "file checkouts should not change go.mod")
+
+	switchBranch(t, r, "feature")
+
+	if err = PostCheckout(base, "", "", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, localReplaces(t, base), 1, "feature has no profile yet and keeps the local replaces")
+
+	// Stop developing against the local checkout on feature.
+	if err = ioutil.WriteFile(filepath.Join(base, "go.mod"), []byte(strippedGomod), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	switchBranch(t, r, "master")
+
+	if err = PostCheckout(base, "", "", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	branches, err := List(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{"feature", "master"}, branches)
+
+	replaces, err := Show(base, "master")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, replaces, 1) {
+		assert.Equal(t, "aduu.dev/utils => ../utils", replaces[0].String())
+	}
+
+	assert.Equal(t, replaces, localReplaces(t, base), "master's local replaces should be restored")
+
+	switchBranch(t, r, "feature")
+
+	if err = PostCheckout(base, "", "", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Empty(t, localReplaces(t, base), "feature's empty profile should remove the local replaces")
+
+	if err = Drop(base, "master"); err != nil {
+		t.Fatal(err)
+	}
+
+	branches, err = List(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{"feature"}, branches)
+
+	if _, err = Show(base, "master"); err == nil {
+		t.Fatal("Show should fail for a dropped profile")
+	}
+}
+
+func TestPostCheckout_new_branch(t *testing.T) {
+	base, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(base); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	r := initRepo(t, base)
+
+	if err = ioutil.WriteFile(filepath.Join(base, "go.mod"), []byte(localGomod), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = PostCheckout(base, "", "", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// git checkout -b topic
+	head, err := r.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = r.Storer.SetReference(plumbing.NewHashReference("refs/heads/topic", head.Hash())); err != nil {
+		t.Fatal(err)
+	}
+
+	switchBranch(t, r, "topic")
+
+	if err = PostCheckout(base, head.Hash().String(), head.Hash().String(), "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(base, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, localGomod, string(data), "a new branch should keep the local replaces")
+
+	replaces, err := Show(base, "topic")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, replaces, 1) {
+		assert.Equal(t, "aduu.dev/utils => ../utils", replaces[0].String(), "the local replaces should be the first profile of the new branch")
+	}
+}
+
+func TestPostCheckout_modules(t *testing.T) {
+	base, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(base); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	r := initRepo(t, base)
+	api := filepath.Join(base, "api")
+
+	if err = os.MkdirAll(api, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	gomods := map[string]string{
+		base: localGomod,
+		api:  "module aduu.dev/k/api\n\ngo 1.14\n\nrequire aduu.dev/utils v1.0.0\n\nreplace aduu.dev/utils => ../../utils\n",
+	}
+
+	for dir, gomod := range gomods {
+		if err = ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte(gomod), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The hook runs once per module.
+	postCheckout := func() {
+		for _, dir := range []string{base, api} {
+			if err := PostCheckout(dir, "", "", "1"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	postCheckout()
+	switchBranch(t, r, "feature")
+	postCheckout()
+
+	for dir, target := range map[string]string{base: "../utils", api: "../../utils"} {
+		branches, err := List(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, []string{"feature", "master"}, branches, "every module should have its own profiles")
+
+		replaces, err := Show(dir, "master")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if assert.Len(t, replaces, 1) {
+			assert.Equal(t, "aduu.dev/utils => "+target, replaces[0].String())
+		}
+	}
+
+	switchBranch(t, r, "master")
+	postCheckout()
+
+	for dir, gomod := range gomods {
+		data, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, gomod, string(data), "the local replaces of master should be restored")
+	}
+}
@@ -0,0 +1,89 @@
+package replace
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	"aduu.dev/utils/helper"
+)
+
+// backupSuffix is appended to a file's name for its backup, e.g. go.mod.b.
+const backupSuffix = ".b"
+
+// sideFilenames are the files next to go.mod which the go command may rewrite while the local replace
+// directives are removed, e.g. go.sum gaining the checksums of the replaced modules.
+// They are backed up together with go.mod so undo restores all of them.
+func sideFilenames() []string {
+	return []string{"go.sum", "go.work", "go.work.sum"}
+}
+
+// backupSideFiles stages a backup of each side file of the module in base which exists.
+func backupSideFiles(tx *transaction, base string) (err error) {
+	for _, name := range sideFilenames() {
+		path := filepath.Join(base, name)
+
+		if err = backupFile(tx, path, path+backupSuffix); err != nil {
+			return
+		}
+	}
+
+	return nil
+}
+
+// restoreSideFiles stages restoring each side file of the module in base from its backup if there is one.
+func restoreSideFiles(tx *transaction, base string) (err error) {
+	for _, name := range sideFilenames() {
+		path := filepath.Join(base, name)
+
+		if err = restoreFile(tx, path, path+backupSuffix); err != nil {
+			return
+		}
+	}
+
+	return nil
+}
+
+// backupFile stages a copy of path at backup if path exists.
+func backupFile(tx *transaction, path string, backup string) (err error) {
+	exists, err := helper.DoesPathExistErr(path)
+	if err != nil || !exists {
+		return
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	mode, err := fileMode(path, 0644)
+	if err != nil {
+		return
+	}
+
+	tx.write(backup, data, mode)
+
+	return nil
+}
+
+// restoreFile stages restoring path from backup and removing backup if there is one.
+func restoreFile(tx *transaction, path string, backup string) (err error) {
+	exists, err := helper.DoesPathExistErr(backup)
+	if err != nil || !exists {
+		return
+	}
+
+	data, err := ioutil.ReadFile(backup)
+	if err != nil {
+		return
+	}
+
+	mode, err := fileMode(path, 0644)
+	if err != nil {
+		return
+	}
+
+	tx.write(path, data, mode)
+	tx.remove(backup)
+
+	return nil
+}
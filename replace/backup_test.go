@@ -0,0 +1,55 @@
+package replace
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoveLocalReplacesFromGomod_side_files(t *testing.T) {
+	base, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(base); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	gomod := "module aduu.dev/k\n\ngo 1.14\n\nrequire aduu.dev/utils v1.0.0\n\nreplace aduu.dev/utils => ../utils\n"
+	gosum := "aduu.dev/other v1.0.0 h1:abc=\n"
+	gosumFilepath := filepath.Join(base, "go.sum")
+	goworkFilepath := filepath.Join(base, "go.work")
+
+	if err = ioutil.WriteFile(filepath.Join(base, "go.mod"), []byte(gomod), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ioutil.WriteFile(gosumFilepath, []byte(gosum), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = RemoveLocalReplacesFromGomod(base, false); err != nil {
+		t.Fatal(err)
+	}
+
+	fileHasContent(t, gosumFilepath+".b", gosum, "go.sum should be backed up")
+	assert.NoFileExists(t, goworkFilepath+".b", "a missing go.work should not be backed up")
+
+	// The go command adds the checksums of the replaced module while it is not replaced.
+	if err = ioutil.WriteFile(gosumFilepath, []byte(gosum+"aduu.dev/utils v1.0.0 h1:def=\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = UndoRemovingLocalReplacesFromGomod(base, false); err != nil {
+		t.Fatal(err)
+	}
+
+	fileHasContent(t, gosumFilepath, gosum, "go.sum should be restored")
+	assert.NoFileExists(t, gosumFilepath+".b", "backup should be removed")
+}
@@ -0,0 +1,192 @@
+package replace
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"k8s.io/klog/v2"
+)
+
+// disabledMarker prefixes local replace directives which gogit commented out.
+//
+// Outside of a replace block the marked line keeps the replace keyword:
+//
+//	// gogit-disabled: replace aduu.dev/utils => ../utils
+const disabledMarker = "// gogit-disabled: "
+
+var (
+	errEnabledGomodInvalid = fmt.Errorf("go.mod is invalid after enabling the disabled local replaces")
+)
+
+// DisableLocalReplaces comments out the local replace directives in the go.mod in base with a marker
+// instead of removing them, so EnableLocalReplaces can restore them without a backup, e.g. on a fresh clone.
+//
+// With workOnStagedOnly nothing happens if go.mod is not staged.
+func DisableLocalReplaces(base string, workOnStagedOnly bool) (disabled []LocalReplace, err error) {
+	tx, err := begin(base)
+	if err != nil {
+		return
+	}
+
+	defer tx.end()
+
+	if workOnStagedOnly {
+		staged, err := isGomodStaged(base)
+		if err != nil || !staged {
+			return nil, err
+		}
+	}
+
+	gomodFilepath, data, err := getGoModFilepathAndData(base)
+	if err != nil {
+		return
+	}
+
+	file, err := modfile.Parse(gomodFilepath, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse modfile at %#v: %w", gomodFilepath, err)
+	}
+
+	localReplaces := removeLocalReplaceDirectives(file.Replace)
+	if len(localReplaces) == 0 {
+		return nil, nil
+	}
+
+	for _, rep := range localReplaces {
+		disabled = append(disabled, LocalReplace{Old: rep.Old, New: rep.New.Path})
+	}
+
+	mode, err := fileMode(gomodFilepath, 0644)
+	if err != nil {
+		return
+	}
+
+	tx.write(gomodFilepath, disableReplaceLines(data, localReplaces), mode)
+
+	if err = tx.commit(); err != nil {
+		return nil, err
+	}
+
+	klog.InfoS("Disabled local replace directives", "go.mod", gomodFilepath, "count", len(disabled))
+
+	return disabled, nil
+}
+
+// EnableLocalReplaces re-enables the local replace directives DisableLocalReplaces commented out.
+func EnableLocalReplaces(base string) (enabled []LocalReplace, err error) {
+	tx, err := begin(base)
+	if err != nil {
+		return
+	}
+
+	defer tx.end()
+
+	gomodFilepath, data, err := getGoModFilepathAndData(base)
+	if err != nil {
+		return
+	}
+
+	enabled = disabledReplaces(data)
+	if len(enabled) == 0 {
+		return nil, nil
+	}
+
+	dataOut := enableReplaceLines(data)
+
+	if _, err = modfile.Parse(gomodFilepath, dataOut, nil); err != nil {
+		return nil, fmt.Errorf("%w: %v", errEnabledGomodInvalid, err)
+	}
+
+	mode, err := fileMode(gomodFilepath, 0644)
+	if err != nil {
+		return
+	}
+
+	tx.write(gomodFilepath, dataOut, mode)
+
+	if err = tx.commit(); err != nil {
+		return nil, err
+	}
+
+	klog.InfoS("Enabled local replace directives", "go.mod", gomodFilepath, "count", len(enabled))
+
+	return enabled, nil
+}
+
+// DisabledLocalReplaces returns the local replace directives commented out in the go.mod in base.
+func DisabledLocalReplaces(base string) (replaces []LocalReplace, err error) {
+	_, data, err := getGoModFilepathAndData(base)
+	if err != nil {
+		return
+	}
+
+	return disabledReplaces(data), nil
+}
+
+// disableReplaceLines puts the marker in front of the lines of the replace directives,
+// keeping their indentation and every other byte.
+func disableReplaceLines(data []byte, replaces []*modfile.Replace) []byte {
+	disable := map[int]bool{}
+
+	for _, rep := range replaces {
+		for line := rep.Syntax.Start.Line; line <= rep.Syntax.End.Line; line++ {
+			disable[line] = true
+		}
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+
+	for i, line := range lines {
+		if disable[i+1] {
+			indent := len(line) - len(strings.TrimLeft(line, " \t"))
+			lines[i] = line[:indent] + disabledMarker + line[indent:]
+		}
+	}
+
+	return []byte(strings.Join(lines, ""))
+}
+
+// enableReplaceLines removes the markers put by disableReplaceLines.
+func enableReplaceLines(data []byte) []byte {
+	lines := strings.SplitAfter(string(data), "\n")
+
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t")
+
+		if strings.HasPrefix(trimmed, disabledMarker) {
+			lines[i] = line[:len(line)-len(trimmed)] + strings.TrimPrefix(trimmed, disabledMarker)
+		}
+	}
+
+	return []byte(strings.Join(lines, ""))
+}
+
+// disabledReplaces parses the directives behind the markers. Lines which do not parse are skipped.
+func disabledReplaces(data []byte) (replaces []LocalReplace) {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, disabledMarker) {
+			continue
+		}
+
+		directive := strings.TrimPrefix(trimmed, disabledMarker)
+
+		// Drop a trailing comment.
+		if i := strings.Index(directive, "//"); i >= 0 {
+			directive = directive[:i]
+		}
+
+		directive = strings.TrimPrefix(strings.TrimSpace(directive), "replace ")
+
+		rep, err := ParseLocalReplace(directive)
+		if err != nil {
+			klog.InfoS("Skipping invalid disabled local replace", "line", trimmed, "err", err)
+			continue
+		}
+
+		replaces = append(replaces, rep)
+	}
+
+	return replaces
+}
@@ -0,0 +1,98 @@
+package replace
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+func TestDisableLocalReplaces(t *testing.T) {
+	tests := []struct {
+		name         string
+		gomod        string
+		wantDisabled string
+		want         []LocalReplace
+	}{
+		{
+			name:         "single line",
+			gomod:        "module aduu.dev/k\n\nreplace aduu.dev/utils => ../utils // local\n",
+			wantDisabled: "module aduu.dev/k\n\n// gogit-disabled: replace aduu.dev/utils => ../utils // local\n",
+			want:         []LocalReplace{{Old: module.Version{Path: "aduu.dev/utils"}, New: "../utils"}},
+		},
+		{
+			name:         "block with CRLF",
+			gomod:        "module aduu.dev/k\r\n\r\nreplace (\r\n\taduu.dev/fork => aduu.dev/fork2 v1.1.0\r\n\taduu.dev/utils v1.0.0 => ./utils\r\n)\r\n",
+			wantDisabled: "module aduu.dev/k\r\n\r\nreplace (\r\n\taduu.dev/fork => aduu.dev/fork2 v1.1.0\r\n\t// gogit-disabled: aduu.dev/utils v1.0.0 => ./utils\r\n)\r\n",
+			want:         []LocalReplace{{Old: module.Version{Path: "aduu.dev/utils", Version: "v1.0.0"}, New: "./utils"}},
+		},
+		{
+			name:         "whole block",
+			gomod:        "module aduu.dev/k\n\nreplace (\n\taduu.dev/utils => ../utils\n\taduu.dev/other => ../other\n)\n",
+			wantDisabled: "module aduu.dev/k\n\nreplace (\n\t// gogit-disabled: aduu.dev/utils => ../utils\n\t// gogit-disabled: aduu.dev/other => ../other\n)\n",
+			want: []LocalReplace{
+				{Old: module.Version{Path: "aduu.dev/utils"}, New: "../utils"},
+				{Old: module.Version{Path: "aduu.dev/other"}, New: "../other"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, err := ioutil.TempDir(os.TempDir(), "disable")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			t.Cleanup(func() {
+				if err = os.RemoveAll(base); err != nil {
+					t.Fatal(err)
+				}
+			})
+
+			gomodFilepath := filepath.Join(base, "go.mod")
+			if err = ioutil.WriteFile(gomodFilepath, []byte(tt.gomod), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			disabled, err := DisableLocalReplaces(base, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.want, disabled)
+			fileHasContent(t, gomodFilepath, tt.wantDisabled, "")
+
+			data, err := ioutil.ReadFile(gomodFilepath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			file, err := modfile.Parse(gomodFilepath, data, nil)
+			if err != nil {
+				t.Fatal("disabled go.mod should parse: ", err)
+			}
+
+			assert.Empty(t, removeLocalReplaceDirectives(file.Replace), "the go command should not see local replaces")
+
+			removed, err := RemovedLocalReplaces(base)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.want, removed, "disabled replaces count as removed without a backup")
+
+			enabled, err := EnableLocalReplaces(base)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.want, enabled)
+			fileHasContent(t, gomodFilepath, tt.gomod, "enabling should restore go.mod byte by byte")
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package replace
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"k8s.io/klog/v2"
+
+	"aduu.dev/tools/gogit/gitdir"
+)
+
+var (
+	errNoLocalReplace       = fmt.Errorf("no local replace directive for module")
+	errNoDroppedReplaces    = fmt.Errorf("no dropped local replace directives recorded")
+	errDroppedRecordCorrupt = fmt.Errorf("record of dropped local replace directives is corrupt")
+	errNoModuleDirective    = fmt.Errorf("go.mod has no module directive")
+)
+
+// AddLocalReplace adds the local replace directive to the go.mod in base.
+// An existing replace directive for the same module version is overwritten.
+func AddLocalReplace(base string, rep LocalReplace) (err error) {
+	gomodFilepath, data, err := getGoModFilepathAndData(base)
+	if err != nil {
+		return
+	}
+
+	dataOut, err := setLocalReplaceLines(gomodFilepath, data, []LocalReplace{rep}, false)
+	if err != nil {
+		return
+	}
+
+	if err = writeGomod(gomodFilepath, dataOut); err != nil {
+		return
+	}
+
+	klog.InfoS("Added local replace", "go.mod", gomodFilepath, "replace", rep.String())
+
+	return nil
+}
+
+// DropLocalReplaces removes the local replace directives of the given modules from the go.mod in base,
+// all local replace directives if no module is given.
+//
+// The dropped directives are recorded below the git directory so RestoreDroppedLocalReplaces can add them again.
+func DropLocalReplaces(base string, modulePaths []string) (dropped []LocalReplace, err error) {
+	gomodFilepath, data, err := getGoModFilepathAndData(base)
+	if err != nil {
+		return
+	}
+
+	file, err := modfile.Parse(gomodFilepath, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse modfile at %#v: %w", gomodFilepath, err)
+	}
+
+	wanted := make(map[string]bool, len(modulePaths))
+	for _, modulePath := range modulePaths {
+		wanted[modulePath] = true
+	}
+
+	found := map[string]bool{}
+
+	var drop []*modfile.Replace
+
+	for _, rep := range removeLocalReplaceDirectives(file.Replace) {
+		if len(wanted) != 0 && !wanted[rep.Old.Path] {
+			continue
+		}
+
+		found[rep.Old.Path] = true
+
+		drop = append(drop, rep)
+		dropped = append(dropped, LocalReplace{Old: rep.Old, New: rep.New.Path})
+	}
+
+	for _, modulePath := range modulePaths {
+		if !found[modulePath] {
+			return nil, fmt.Errorf("%w %#v", errNoLocalReplace, modulePath)
+		}
+	}
+
+	if len(dropped) == 0 {
+		return nil, nil
+	}
+
+	modulePath, err := modulePathOf(file, gomodFilepath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Record first, a lost record is worse than a go.mod which still has the directives.
+	if err = recordDropped(base, modulePath, dropped); err != nil {
+		return nil, err
+	}
+
+	if err = writeGomod(gomodFilepath, dropReplaceLines(data, file, drop)); err != nil {
+		return
+	}
+
+	klog.InfoS("Dropped local replaces", "go.mod", gomodFilepath, "count", len(dropped))
+
+	return dropped, nil
+}
+
+// RestoreDroppedLocalReplaces adds the local replace directives recorded by DropLocalReplaces
+// to the go.mod in base again and removes the record.
+func RestoreDroppedLocalReplaces(base string) (restored []LocalReplace, err error) {
+	gomodFilepath, data, err := getGoModFilepathAndData(base)
+	if err != nil {
+		return
+	}
+
+	file, err := modfile.Parse(gomodFilepath, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse modfile at %#v: %w", gomodFilepath, err)
+	}
+
+	modulePath, err := modulePathOf(file, gomodFilepath)
+	if err != nil {
+		return
+	}
+
+	record, err := droppedFilepath(base, modulePath)
+	if err != nil {
+		return
+	}
+
+	restored, err = readDropped(record)
+	if os.IsNotExist(err) {
+		return nil, errNoDroppedReplaces
+	}
+
+	if err != nil {
+		return
+	}
+
+	dataOut, err := setLocalReplaceLines(gomodFilepath, data, restored, false)
+	if err != nil {
+		return
+	}
+
+	if err = writeGomod(gomodFilepath, dataOut); err != nil {
+		return
+	}
+
+	if err = os.Remove(record); err != nil {
+		return
+	}
+
+	klog.InfoS("Restored dropped local replaces", "go.mod", gomodFilepath, "count", len(restored))
+
+	return restored, nil
+}
+
+// modulePathOf returns the module path of the parsed go.mod, the records of dropped local replaces are kept per module.
+func modulePathOf(file *modfile.File, gomodFilepath string) (modulePath string, err error) {
+	if file.Module == nil {
+		return "", fmt.Errorf("failed to parse modfile at %#v: %w", gomodFilepath, errNoModuleDirective)
+	}
+
+	return file.Module.Mod.Path, nil
+}
+
+// droppedFilepath returns the file recording the dropped local replaces of the module.
+// The module path is escaped as a repository may contain several modules.
+func droppedFilepath(base string, modulePath string) (file string, err error) {
+	gogitDir, err := gitdir.Gogit(base)
+	if err != nil {
+		return
+	}
+
+	return filepath.Join(gogitDir, "dropped", url.PathEscape(modulePath)), nil
+}
+
+// recordDropped adds the replaces to the record of the module.
+// A replace recorded earlier for the same module version is overwritten.
+func recordDropped(base string, modulePath string, replaces []LocalReplace) (err error) {
+	record, err := droppedFilepath(base, modulePath)
+	if err != nil {
+		return
+	}
+
+	existing, err := readDropped(record)
+	if err != nil && !os.IsNotExist(err) {
+		return
+	}
+
+	lines := make([]string, 0, len(existing)+len(replaces))
+
+	for _, old := range existing {
+		overwritten := false
+
+		for _, rep := range replaces {
+			if rep.Old == old.Old {
+				overwritten = true
+				break
+			}
+		}
+
+		if !overwritten {
+			lines = append(lines, old.String())
+		}
+	}
+
+	for _, rep := range replaces {
+		lines = append(lines, rep.String())
+	}
+
+	if err = os.MkdirAll(filepath.Dir(record), 0755); err != nil {
+		return
+	}
+
+	return ioutil.WriteFile(record, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+func readDropped(record string) (replaces []LocalReplace, err error) {
+	data, err := ioutil.ReadFile(record)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		rep, err := ParseLocalReplace(line)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %#v: %v", errDroppedRecordCorrupt, record, err)
+		}
+
+		replaces = append(replaces, rep)
+	}
+
+	return replaces, nil
+}
@@ -0,0 +1,132 @@
+package replace
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/mod/module"
+)
+
+func TestDropLocalReplaces(t *testing.T) {
+	base, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(base); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if _, err = git.PlainInit(base, false); err != nil {
+		t.Fatal(err)
+	}
+
+	gomodFilepath := filepath.Join(base, "go.mod")
+
+	gomod := `module aduu.dev/k
+
+go 1.14
+
+require (
+	aduu.dev/other v1.0.0
+	aduu.dev/utils v1.0.0
+)
+
+replace aduu.dev/other => ../other
+
+replace aduu.dev/utils => ../utils
+`
+	if err = ioutil.WriteFile(gomodFilepath, []byte(gomod), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = DropLocalReplaces(base, []string{"aduu.dev/unknown"})
+	assert.True(t, errors.Is(err, errNoLocalReplace), "got %v", err)
+
+	dropped, err := DropLocalReplaces(base, []string{"aduu.dev/other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []LocalReplace{{Old: module.Version{Path: "aduu.dev/other"}, New: "../other"}}, dropped)
+
+	replaces, err := LocalReplaces(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []LocalReplace{{Old: module.Version{Path: "aduu.dev/utils"}, New: "../utils"}}, replaces, "only the given module should be dropped")
+
+	if _, err = DropLocalReplaces(base, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	replaces, err = LocalReplaces(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Empty(t, replaces, "all local replaces should be dropped")
+
+	restored, err := RestoreDroppedLocalReplaces(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, restored, 2, "both drops should be recorded")
+
+	replaces, err = LocalReplaces(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.ElementsMatch(t, []LocalReplace{
+		{Old: module.Version{Path: "aduu.dev/other"}, New: "../other"},
+		{Old: module.Version{Path: "aduu.dev/utils"}, New: "../utils"},
+	}, replaces)
+
+	_, err = RestoreDroppedLocalReplaces(base)
+	assert.True(t, errors.Is(err, errNoDroppedReplaces), "the record should be removed after restoring, got %v", err)
+}
+
+func TestDropLocalReplaces_no_module_directive(t *testing.T) {
+	base, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(base); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if _, err = git.PlainInit(base, false); err != nil {
+		t.Fatal(err)
+	}
+
+	gomod := "go 1.14\n\nrequire aduu.dev/utils v1.0.0\n\nreplace aduu.dev/utils => ../utils\n"
+	if err = ioutil.WriteFile(filepath.Join(base, "go.mod"), []byte(gomod), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = DropLocalReplaces(base, nil)
+	assert.True(t, errors.Is(err, errNoModuleDirective), "got %v", err)
+
+	_, err = RestoreDroppedLocalReplaces(base)
+	assert.True(t, errors.Is(err, errNoModuleDirective), "got %v", err)
+
+	data, err := ioutil.ReadFile(filepath.Join(base, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, gomod, string(data), "go.mod should not be changed")
+}
@@ -0,0 +1,308 @@
+package replace
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// dropReplaceLines returns data without the lines of the given replace directives of file,
+// which has to be parsed from data.
+//
+// Unlike modfile.File.Format it leaves every other byte untouched, including comments,
+// blank lines and CRLF line endings, so commits do not contain unrelated changes.
+// A replace block which becomes empty is removed together with its parentheses.
+func dropReplaceLines(data []byte, file *modfile.File, replaces []*modfile.Replace) []byte {
+	drop := map[*modfile.Line]bool{}
+	for _, rep := range replaces {
+		drop[rep.Syntax] = true
+	}
+
+	// deleted marks the 1-based line numbers to delete.
+	deleted := map[int]bool{}
+
+	deleteLines := func(start, end int) {
+		for line := start; line <= end; line++ {
+			deleted[line] = true
+		}
+	}
+
+	for _, stmt := range file.Syntax.Stmt {
+		switch x := stmt.(type) {
+		case *modfile.Line:
+			if drop[x] {
+				deleteLines(x.Start.Line, x.End.Line)
+			}
+		case *modfile.LineBlock:
+			remaining := 0
+
+			for _, line := range x.Line {
+				if drop[line] {
+					deleteLines(line.Start.Line, line.End.Line)
+				} else {
+					remaining++
+				}
+			}
+
+			if remaining == 0 && len(x.Line) != 0 && isReplaceBlock(x) {
+				deleteLines(x.Start.Line, x.RParen.Pos.Line)
+			}
+		}
+	}
+
+	if len(deleted) == 0 {
+		return data
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	kept := make([]string, 0, len(lines))
+
+	for i, line := range lines {
+		if !deleted[i+1] {
+			kept = append(kept, line)
+		}
+	}
+
+	return []byte(strings.Join(kept, ""))
+}
+
+func isReplaceBlock(block *modfile.LineBlock) bool {
+	return len(block.Token) == 1 && block.Token[0] == "replace"
+}
+
+// directive is a go.mod directive to write without its verb, e.g. "aduu.dev/utils => ../utils".
+type directive struct {
+	// line is the existing directive to overwrite, nil to add a new one.
+	line *modfile.Line
+	spec string
+}
+
+// edit replaces the bytes from start to end with text.
+type edit struct {
+	start, end int
+	text       string
+}
+
+// setDirectiveLines returns data with the directives of verb overwritten or added, file has to be parsed from data.
+//
+// Like dropReplaceLines it leaves every other byte untouched. An overwritten directive keeps its place,
+// indentation and comments. New directives go into the last block of verb, else after its last
+// directive, else to the end of the file.
+func setDirectiveLines(data []byte, file *modfile.File, verb string, directives []directive) []byte {
+	eol := "\n"
+	if strings.Contains(string(data), "\r\n") {
+		eol = "\r\n"
+	}
+
+	var (
+		edits []edit
+		added []string
+	)
+
+	for _, d := range directives {
+		if d.line == nil {
+			added = append(added, d.spec)
+			continue
+		}
+
+		text := d.spec
+		if len(d.line.Token) != 0 && d.line.Token[0] == verb {
+			text = verb + " " + text
+		}
+
+		edits = append(edits, edit{start: d.line.Start.Byte, end: d.line.End.Byte, text: text})
+	}
+
+	if len(added) != 0 {
+		edits = append(edits, addDirectives(data, file, verb, added, eol))
+	}
+
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].start > edits[j].start
+	})
+
+	out := string(data)
+	for _, e := range edits {
+		out = out[:e.start] + e.text + out[e.end:]
+	}
+
+	return []byte(out)
+}
+
+// addDirectives returns the edit inserting the directives of verb with the given specs.
+func addDirectives(data []byte, file *modfile.File, verb string, specs []string, eol string) edit {
+	var (
+		block *modfile.LineBlock
+		last  *modfile.Line
+	)
+
+	for _, stmt := range file.Syntax.Stmt {
+		switch x := stmt.(type) {
+		case *modfile.Line:
+			if len(x.Token) != 0 && x.Token[0] == verb {
+				last = x
+			}
+		case *modfile.LineBlock:
+			if len(x.Token) == 1 && x.Token[0] == verb {
+				block = x
+			}
+		}
+	}
+
+	var lines []string
+
+	switch {
+	case block != nil:
+		for _, spec := range specs {
+			lines = append(lines, "\t"+spec+eol)
+		}
+
+		at := lineStart(data, block.RParen.Pos.Byte)
+
+		return edit{start: at, end: at, text: strings.Join(lines, "")}
+	case last != nil:
+		for _, spec := range specs {
+			lines = append(lines, verb+" "+spec+eol)
+		}
+
+		at := lineEnd(data, last.End.Byte)
+		if at == len(data) && !strings.HasSuffix(string(data), "\n") {
+			lines[0] = eol + lines[0]
+		}
+
+		return edit{start: at, end: at, text: strings.Join(lines, "")}
+	}
+
+	for _, spec := range specs {
+		lines = append(lines, verb+" "+spec+eol)
+	}
+
+	text := eol + strings.Join(lines, "")
+	if len(data) != 0 && !strings.HasSuffix(string(data), "\n") {
+		text = eol + text
+	}
+
+	return edit{start: len(data), end: len(data), text: text}
+}
+
+// lineStart returns the offset of the start of the line containing offset.
+func lineStart(data []byte, offset int) int {
+	return strings.LastIndex(string(data[:offset]), "\n") + 1
+}
+
+// lineEnd returns the offset after the line ending of the line containing offset.
+func lineEnd(data []byte, offset int) int {
+	i := strings.Index(string(data[offset:]), "\n")
+	if i < 0 {
+		return len(data)
+	}
+
+	return offset + i + 1
+}
+
+// setLocalReplaceLines returns the go.mod data with the given local replace directives line by line.
+//
+// Like modfile.File.AddReplace an existing replace directive of the same module version,
+// of any version if the version is empty, is overwritten and further ones are dropped.
+// With dropOtherLocal the local replace directives of other modules are dropped as well.
+func setLocalReplaceLines(gomodFilepath string, data []byte, replaces []LocalReplace, dropOtherLocal bool) (dataOut []byte, err error) {
+	file, err := modfile.Parse(gomodFilepath, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse modfile at %#v: %w", gomodFilepath, err)
+	}
+
+	matches := func(r *modfile.Replace, rep LocalReplace) bool {
+		return r.Old.Path == rep.Old.Path && (len(rep.Old.Version) == 0 || r.Old.Version == rep.Old.Version)
+	}
+
+	local := map[*modfile.Replace]bool{}
+	for _, r := range removeLocalReplaceDirectives(file.Replace) {
+		local[r] = true
+	}
+
+	match := func(r *modfile.Replace) int {
+		for i, rep := range replaces {
+			if matches(r, rep) {
+				return i
+			}
+		}
+
+		return -1
+	}
+
+	var drop []*modfile.Replace
+
+	// The first directive matching a replace is overwritten, the others go.
+	claimed := make([]bool, len(replaces))
+
+	for _, r := range file.Replace {
+		switch i := match(r); {
+		case i >= 0 && !claimed[i]:
+			claimed[i] = true
+		case i >= 0, dropOtherLocal && local[r]:
+			drop = append(drop, r)
+		}
+	}
+
+	data = dropReplaceLines(data, file, drop)
+
+	if file, err = modfile.Parse(gomodFilepath, data, nil); err != nil {
+		return nil, fmt.Errorf("failed to parse modfile at %#v: %w", gomodFilepath, err)
+	}
+
+	directives := make([]directive, 0, len(replaces))
+
+	for _, rep := range replaces {
+		spec := modfile.AutoQuote(rep.Old.Path)
+		if len(rep.Old.Version) != 0 {
+			spec += " " + rep.Old.Version
+		}
+
+		d := directive{spec: spec + " => " + modfile.AutoQuote(rep.New)}
+		unchanged := false
+
+		for _, r := range file.Replace {
+			if matches(r, rep) {
+				d.line = r.Syntax
+				unchanged = r.New.Path == rep.New && len(r.New.Version) == 0
+
+				break
+			}
+		}
+
+		// A directive which is already as wanted is left byte for byte.
+		if !unchanged {
+			directives = append(directives, d)
+		}
+	}
+
+	return setDirectiveLines(data, file, "replace", directives), nil
+}
+
+// setRequireLine returns the go.mod data with the required version of the module set line by line.
+func setRequireLine(gomodFilepath string, data []byte, modulePath string, version string) (dataOut []byte, err error) {
+	file, err := modfile.Parse(gomodFilepath, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse modfile at %#v: %w", gomodFilepath, err)
+	}
+
+	d := directive{spec: modfile.AutoQuote(modulePath) + " " + version}
+
+	for _, req := range file.Require {
+		if req.Mod.Path != modulePath {
+			continue
+		}
+
+		if req.Mod.Version == version {
+			return data, nil
+		}
+
+		d.line = req.Syntax
+
+		break
+	}
+
+	return setDirectiveLines(data, file, "require", []directive{d}), nil
+}
@@ -0,0 +1,92 @@
+package replace
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/mod/modfile"
+)
+
+// Test_edit_golden edits the input of each directory in testdata/edit and compares it with want.
+//
+// The edit is given by an optional file: "set" holds the local replaces for SetLocalReplaces,
+// "require" the module and version for SetRequire. Without one the local replaces are stripped.
+func Test_edit_golden(t *testing.T) {
+	editFilepath := filepath.Join("testdata", "edit")
+
+	dir, err := ioutil.ReadDir(editFilepath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range dir {
+		base := filepath.Join(editFilepath, test.Name())
+
+		t.Run(test.Name(), func(t *testing.T) {
+			input, err := ioutil.ReadFile(filepath.Join(base, "input"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want, err := ioutil.ReadFile(filepath.Join(base, "want"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := editGolden(t, base, input)
+
+			// Compare as strings for a readable diff, they are compared byte by byte.
+			assert.Equal(t, string(want), string(got))
+
+			if _, err = modfile.Parse("go.mod", got, nil); err != nil {
+				t.Errorf("stripped go.mod does not parse: %v", err)
+			}
+		})
+	}
+}
+
+func editGolden(t *testing.T, base string, input []byte) (got []byte) {
+	if set, err := ioutil.ReadFile(filepath.Join(base, "set")); err == nil {
+		var replaces []LocalReplace
+
+		for _, line := range strings.Split(strings.TrimSpace(string(set)), "\n") {
+			rep, err := ParseLocalReplace(line)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			replaces = append(replaces, rep)
+		}
+
+		if got, err = setLocalReplaceLines("go.mod", input, replaces, true); err != nil {
+			t.Fatal(err)
+		}
+
+		return got
+	}
+
+	if require, err := ioutil.ReadFile(filepath.Join(base, "require")); err == nil {
+		fields := strings.Fields(string(require))
+
+		if got, err = setRequireLine("go.mod", input, fields[0], fields[1]); err != nil {
+			t.Fatal(err)
+		}
+
+		return got
+	}
+
+	file, err := modfile.Parse("go.mod", input, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, stripped := stripLocalReplaces(file, input)
+	if !stripped {
+		return input
+	}
+
+	return got
+}
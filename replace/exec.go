@@ -0,0 +1,192 @@
+package replace
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"aduu.dev/utils/helper"
+	copy2 "github.com/otiai10/copy"
+	"golang.org/x/mod/modfile"
+	"k8s.io/klog/v2"
+)
+
+// ExecOptions configures Exec.
+type ExecOptions struct {
+	// Modfile runs the command against a stripped temporary copy of go.mod passed via GOFLAGS=-modfile
+	// instead of stripping go.mod itself.
+	Modfile bool
+}
+
+// Exec runs the command in base against a go.mod without local replace directives
+// with stdin, stdout and stderr passed through and returns the command's exit code.
+//
+// By default go.mod is stripped with RemoveLocalReplacesFromGomod and always restored
+// with UndoRemovingLocalReplacesFromGomod afterwards, also when the command fails or
+// gogit receives SIGINT or SIGTERM, see run.
+func Exec(base string, opts ExecOptions, name string, args ...string) (exitCode int, err error) {
+	// Catch the signals before go.mod is stripped, so it is restored even if one arrives right after.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	defer signal.Stop(signals)
+
+	cmd := exec.Command(name, args...)
+	cmd.Dir = base
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+
+	if opts.Modfile {
+		modfilePath, cleanup, err := strippedModfileCopy(base)
+		if err != nil {
+			return 0, err
+		}
+
+		defer cleanup()
+
+		cmd.Env = append(cmd.Env, "GOFLAGS="+strings.TrimSpace(os.Getenv("GOFLAGS")+" -modfile="+modfilePath))
+	} else {
+		if err = RemoveLocalReplacesFromGomod(base, false); err != nil {
+			return 0, err
+		}
+
+		defer func() {
+			if undoErr := UndoRemovingLocalReplacesFromGomod(base, false); undoErr != nil && err == nil {
+				err = fmt.Errorf("failed to restore go.mod: %w", undoErr)
+			}
+		}()
+	}
+
+	return run(cmd, signals)
+}
+
+// run runs cmd while the signals caught by Exec do not terminate gogit, so the deferred restore always runs.
+//
+// SIGTERM is forwarded to the command. SIGINT is not, a terminal sends Ctrl-C to its whole foreground
+// process group, so the command got it already. If a signal arrived before, the command is not started.
+func run(cmd *exec.Cmd, signals <-chan os.Signal) (exitCode int, err error) {
+	select {
+	case sig := <-signals:
+		klog.InfoS("Not running command after signal", "signal", sig.String())
+		return signalExitCode(sig), nil
+	default:
+	}
+
+	if err = cmd.Start(); err != nil {
+		return 0, err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				if sig != syscall.SIGTERM {
+					klog.InfoS("Waiting for command which received the signal from the terminal", "signal", sig.String())
+					continue
+				}
+
+				klog.InfoS("Forwarding signal to command", "signal", sig.String())
+
+				if err := cmd.Process.Signal(sig); err != nil {
+					klog.ErrorS(err, "Failed to forward signal", "signal", sig.String())
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err = cmd.Wait()
+	if err == nil {
+		return 0, nil
+	}
+
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return 0, err
+	}
+
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return signalExitCode(status.Signal()), nil
+	}
+
+	return exitErr.ExitCode(), nil
+}
+
+// signalExitCode returns the exit code of a command killed by the signal, 128+signal like in shells.
+func signalExitCode(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+
+	return 1
+}
+
+// strippedModfileCopy writes go.mod without local replace directives together with go.sum into a temporary directory.
+// The returned cleanup removes the directory.
+func strippedModfileCopy(base string) (modfilePath string, cleanup func(), err error) {
+	gomodFilepath, data, err := getGoModFilepathAndData(base)
+	if err != nil {
+		return
+	}
+
+	file, err := modfile.Parse(gomodFilepath, data, nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse modfile at %#v: %w", gomodFilepath, err)
+	}
+
+	dataOut, stripped := stripLocalReplaces(file, data)
+	if !stripped {
+		dataOut = data
+	}
+
+	tempDir, err := ioutil.TempDir("", "gogit-exec")
+	if err != nil {
+		return
+	}
+
+	cleanup = func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			klog.ErrorS(err, "Failed to remove temporary modfile", "dir", tempDir)
+		}
+	}
+
+	defer func() {
+		if err != nil {
+			cleanup()
+		}
+	}()
+
+	modfilePath = filepath.Join(tempDir, "go.mod")
+	if err = ioutil.WriteFile(modfilePath, dataOut, 0644); err != nil {
+		return
+	}
+
+	// The go command expects the checksums next to the modfile.
+	sum := filepath.Join(filepath.Dir(gomodFilepath), "go.sum")
+
+	exists, err := helper.DoesPathExistErr(sum)
+	if err != nil {
+		return
+	}
+
+	if exists {
+		if err = copy2.Copy(sum, filepath.Join(tempDir, "go.sum")); err != nil {
+			return
+		}
+	}
+
+	klog.InfoS("Running against temporary modfile", "modfile", modfilePath, "stripped", stripped)
+
+	return modfilePath, cleanup, nil
+}
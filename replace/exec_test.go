@@ -0,0 +1,128 @@
+package replace
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const execGomod = `module aduu.dev/k
+
+go 1.14
+
+require aduu.dev/utils v1.0.0
+
+replace aduu.dev/utils => ../utils
+`
+
+func TestExec(t *testing.T) {
+	base, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(base); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	gomodFilepath := filepath.Join(base, "go.mod")
+	if err = ioutil.WriteFile(gomodFilepath, []byte(execGomod), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	exitCode, err := Exec(base, ExecOptions{}, "sh", "-c", "cat go.mod > seen; exit 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 3, exitCode, "exit code should be forwarded")
+
+	seen, err := ioutil.ReadFile(filepath.Join(base, "seen"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotContains(t, string(seen), "=> ../utils", "the command should see go.mod without local replaces")
+	fileHasContent(t, gomodFilepath, execGomod, "go.mod should be restored after a failing command")
+	assert.NoFileExists(t, filepath.Join(base, backupFilename()), "backup should be removed")
+}
+
+func Test_run_signal_before_start(t *testing.T) {
+	base, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(base); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	signals := make(chan os.Signal, 1)
+	signals <- syscall.SIGTERM
+
+	cmd := exec.Command("sh", "-c", "touch ran")
+	cmd.Dir = base
+
+	exitCode, err := run(cmd, signals)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 128+int(syscall.SIGTERM), exitCode)
+	assert.NoFileExists(t, filepath.Join(base, "ran"), "the command should not be started after a signal")
+}
+
+func TestExec_modfile(t *testing.T) {
+	base, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(base); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	gomodFilepath := filepath.Join(base, "go.mod")
+	if err = ioutil.WriteFile(gomodFilepath, []byte(execGomod), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	exitCode, err := Exec(base, ExecOptions{Modfile: true}, "sh", "-c", `cat go.mod > seen; echo "$GOFLAGS" > flags`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 0, exitCode)
+	fileHasContent(t, filepath.Join(base, "seen"), execGomod, "go.mod should not be touched")
+
+	flags, err := ioutil.ReadFile(filepath.Join(base, "flags"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var modfilePath string
+
+	for _, flag := range strings.Fields(string(flags)) {
+		if strings.HasPrefix(flag, "-modfile=") {
+			modfilePath = strings.TrimPrefix(flag, "-modfile=")
+		}
+	}
+
+	if !assert.NotEmpty(t, modfilePath, "GOFLAGS should contain -modfile") {
+		return
+	}
+
+	assert.NoFileExists(t, modfilePath, "temporary modfile should be removed")
+}
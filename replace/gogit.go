@@ -9,7 +9,6 @@ import (
 
 	"aduu.dev/utils/helper"
 	"github.com/go-git/go-git/v5"
-	copy2 "github.com/otiai10/copy"
 	"golang.org/x/mod/modfile"
 	"k8s.io/klog/v2"
 )
@@ -118,7 +117,18 @@ func isGomodStaged(base string) (staged bool, err error) {
 }
 
 // RemoveLocalReplacesFromGomod removes go.mod replace directives which are pointing to local folders.
+//
+// The backups and the modified go.mod are written in one transaction, so an interruption
+// never leaves one without the other. go.sum and go.work are backed up as well, as the go command
+// may rewrite them while the local replace directives are removed.
 func RemoveLocalReplacesFromGomod(arg string, workOnStagedOnly bool) (err error) {
+	tx, err := begin(arg)
+	if err != nil {
+		return
+	}
+
+	defer tx.end()
+
 	backup := filepath.Join(arg, backupFilename())
 
 	exists, err := helper.DoesPathExistErr(backup)
@@ -139,11 +149,22 @@ func RemoveLocalReplacesFromGomod(arg string, workOnStagedOnly bool) (err error)
 	if len(gomodFilepath) == 0 {
 		return fmt.Errorf("goModFilepath is not set")
 	}
+
+	mode, err := fileMode(gomodFilepath, 0644)
+	if err != nil {
+		return
+	}
+
 	// Create backup.
 	klog.InfoS("Creating backup", "from", gomodFilepath, "backup", backup)
+	tx.write(backup, data, mode)
+
+	if err = backupSideFiles(tx, arg); err != nil {
+		return
+	}
 
-	if err = copy2.Copy(gomodFilepath, backup); err != nil {
-		return fmt.Errorf("failed to create backup at %#v: %w", backup, err)
+	if err = backupVendorModules(tx, arg); err != nil {
+		return
 	}
 
 	file, err := modfile.Parse(gomodFilepath, data, nil)
@@ -152,62 +173,69 @@ func RemoveLocalReplacesFromGomod(arg string, workOnStagedOnly bool) (err error)
 	}
 
 	// If we do not require go.mod to be staged we can start removing the local directives immediately.
-	if !workOnStagedOnly {
-		err = removeLocalDirectivesInFile(file, gomodFilepath)
-		if err != nil {
-			return err
-		}
-	} else {
+	remove := !workOnStagedOnly
+	if workOnStagedOnly {
 		// Find out the staging status of go.mod.
-		isGomodStaged, err := isGomodStaged(arg)
+		// If we require go.mod to be staged and go.mod is also staged then remove the replace directives.
+		remove, err = isGomodStaged(arg)
 		if err != nil {
 			return err
 		}
+	}
 
-		// If we require go.mod to be staged and go.mod is also staged then remove the replace directives.
-		if isGomodStaged {
-			err = removeLocalDirectivesInFile(file, gomodFilepath)
-			if err != nil {
-				return err
-			}
+	if remove {
+		if err = removeLocalDirectivesInFile(tx, file, data, gomodFilepath, mode); err != nil {
+			return err
 		}
 	}
 
+	if err = tx.commit(); err != nil {
+		return fmt.Errorf("failed to create backup at %#v and modify go.mod: %w", backup, err)
+	}
+
 	klog.InfoS("Finished removing local replace directives", "go.mod", gomodFilepath, "backup", backup)
 
 	return nil
 }
 
-func removeLocalDirectivesInFile(file *modfile.File, gomodFilepath string) (err error) {
-	localReplaces := removeLocalReplaceDirectives(file.Replace)
+func removeLocalDirectivesInFile(tx *transaction, file *modfile.File, data []byte, gomodFilepath string, mode os.FileMode) (err error) {
+	dataOut, stripped := stripLocalReplaces(file, data)
 
 	// Only write out a modified version in case we actually removed a replace directive.
 	//
 	// Else we create unnecessary noise inside git commits.
-	if len(localReplaces) > 0 {
-		for _, localReplace := range localReplaces {
-			if err = file.DropReplace(localReplace.Old.Path, localReplace.Old.Version); err != nil {
-				return err
-			}
-		}
+	if stripped {
+		// Write out modified "go.mod".
+		tx.write(gomodFilepath, dataOut, mode)
 
-		var dataOut []byte
-		dataOut, err = file.Format()
-		if err != nil {
+		if err = stripVendorModules(tx, filepath.Dir(gomodFilepath), removeLocalReplaceDirectives(file.Replace)); err != nil {
 			return err
 		}
-
-		// Write out modified "go.mod".
-		if err = ioutil.WriteFile(gomodFilepath, dataOut, 0755); err != nil {
-			return fmt.Errorf("failed to write modified go.mod file to %#v", gomodFilepath)
-		}
 	}
 
 	return nil
 }
 
-// UndoRemovingLocalReplacesFromGomod replaces the local go.mod with the backup.
+// stripLocalReplaces returns data without the local replace directives of file, which has to be parsed from data.
+// stripped is false if there was no local replace directive.
+func stripLocalReplaces(file *modfile.File, data []byte) (dataOut []byte, stripped bool) {
+	localReplaces := removeLocalReplaceDirectives(file.Replace)
+	if len(localReplaces) == 0 {
+		return nil, false
+	}
+
+	return dropReplaceLines(data, file, localReplaces), true
+}
+
+// UndoRemovingLocalReplacesFromGomod replaces the local go.mod, go.sum and go.work with their backups.
 func UndoRemovingLocalReplacesFromGomod(arg string, workOnStaged bool) (err error) {
+	tx, err := begin(arg)
+	if err != nil {
+		return
+	}
+
+	defer tx.end()
+
 	// Run tests and get go.mod filepath.
 	goModFilepath, _, err := getGoModFilepathAndData(arg)
 	if err != nil {
@@ -226,13 +254,29 @@ func UndoRemovingLocalReplacesFromGomod(arg string, workOnStaged bool) (err erro
 		return errBackupDoesNotExist
 	}
 
-	// Copy from backup to "go.mod".
-	if err = copy2.Copy(backup, goModFilepath); err != nil {
+	data, err := ioutil.ReadFile(backup)
+	if err != nil {
+		return
+	}
+
+	mode, err := fileMode(goModFilepath, 0644)
+	if err != nil {
+		return
+	}
+
+	// Copy from backup to "go.mod" and remove the backup.
+	tx.write(goModFilepath, data, mode)
+	tx.remove(backup)
+
+	if err = restoreSideFiles(tx, arg); err != nil {
+		return
+	}
+
+	if err = restoreVendorModules(tx, arg); err != nil {
 		return
 	}
 
-	// Remove backup.
-	if err = os.Remove(backup); err != nil {
+	if err = tx.commit(); err != nil {
 		return
 	}
 
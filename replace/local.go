@@ -0,0 +1,83 @@
+package replace
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"golang.org/x/mod/modfile"
+	"k8s.io/klog/v2"
+)
+
+// LocalReplaces returns the local replace directives in the go.mod in base.
+func LocalReplaces(base string) (replaces []LocalReplace, err error) {
+	gomodFilepath, data, err := getGoModFilepathAndData(base)
+	if err != nil {
+		return
+	}
+
+	file, err := modfile.Parse(gomodFilepath, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse modfile at %#v: %w", gomodFilepath, err)
+	}
+
+	for _, rep := range removeLocalReplaceDirectives(file.Replace) {
+		replaces = append(replaces, LocalReplace{
+			Old: rep.Old,
+			New: rep.New.Path,
+		})
+	}
+
+	return replaces, nil
+}
+
+// SetLocalReplaces replaces all local replace directives in the go.mod in base with the given ones.
+//
+// go.mod is only written if it changes, and only the lines of the changed directives.
+func SetLocalReplaces(base string, replaces []LocalReplace) (err error) {
+	gomodFilepath, data, err := getGoModFilepathAndData(base)
+	if err != nil {
+		return
+	}
+
+	dataOut, err := setLocalReplaceLines(gomodFilepath, data, replaces, true)
+	if err != nil {
+		return
+	}
+
+	if string(dataOut) == string(data) {
+		return nil
+	}
+
+	if err = writeGomod(gomodFilepath, dataOut); err != nil {
+		return
+	}
+
+	klog.InfoS("Set local replaces", "go.mod", gomodFilepath, "count", len(replaces))
+
+	return nil
+}
+
+// writeGomod atomically writes out a modified go.mod keeping its file mode.
+func writeGomod(gomodFilepath string, data []byte) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("failed to write modified go.mod file to %#v: %w", gomodFilepath, err)
+		}
+	}()
+
+	tx, err := begin(filepath.Dir(gomodFilepath))
+	if err != nil {
+		return
+	}
+
+	defer tx.end()
+
+	mode, err := fileMode(gomodFilepath, 0644)
+	if err != nil {
+		return
+	}
+
+	tx.write(gomodFilepath, data, mode)
+
+	return tx.commit()
+}
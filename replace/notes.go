@@ -0,0 +1,484 @@
+package replace
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"aduu.dev/utils/helper"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"golang.org/x/mod/modfile"
+	"k8s.io/klog/v2"
+
+	"aduu.dev/tools/gogit/gitdir"
+)
+
+// NotesRef is the ref under which gogit stores its git notes.
+const NotesRef = plumbing.ReferenceName("refs/notes/gogit")
+
+var (
+	errNoNote                  = fmt.Errorf("no gogit note")
+	errNoLocalReplaceRestored  = fmt.Errorf("none of the noted local replace targets exist")
+	errLocalReplaceModuleDiffs = fmt.Errorf("local replace target declares a different module")
+)
+
+// WriteLocalReplacesNote writes a git note to HEAD listing the local replace directives
+// RemoveLocalReplacesFromGomod removed from the go.mod in base together with the HEADs of their targets.
+//
+// It has to run before UndoRemovingLocalReplacesFromGomod. Nothing is written if nothing was removed.
+func WriteLocalReplacesNote(base string) (err error) {
+	return WriteModulesLocalReplacesNote([]string{base})
+}
+
+// WriteModulesLocalReplacesNote is WriteLocalReplacesNote for the modules in bases which are committed together.
+//
+// A commit has a single note, it lists the local replaces grouped by the directory of their module:
+//
+//	[api]
+//	aduu.dev/utils => ../../utils @ <head>
+//
+// The sections of other modules in an existing note are kept.
+func WriteModulesLocalReplacesNote(bases []string) (err error) {
+	removed := make(map[string][]LocalReplace, len(bases))
+	count := 0
+
+	for _, base := range bases {
+		moduleRemoved, err := RemovedLocalReplaces(base)
+		if err != nil {
+			return err
+		}
+
+		dir, err := gitdir.ModuleDir(base)
+		if err != nil {
+			return err
+		}
+
+		removed[dir] = moduleRemoved
+		count += len(moduleRemoved)
+	}
+
+	if count == 0 {
+		return nil
+	}
+
+	r, err := git.PlainOpenWithOptions(bases[0], &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return
+	}
+
+	head, err := r.Head()
+	if err != nil {
+		return
+	}
+
+	sections := map[string][]LocalReplace{}
+
+	note, err := readNote(r, head.Hash())
+	switch {
+	case err == nil:
+		if sections, err = parseNote(note); err != nil {
+			return
+		}
+	case !errors.Is(err, errNoNote):
+		return
+	}
+
+	// A note without sections of an older gogit is replaced as its module is unknown.
+	delete(sections, "")
+
+	for dir, moduleRemoved := range removed {
+		if len(moduleRemoved) == 0 {
+			delete(sections, dir)
+			continue
+		}
+
+		sections[dir] = moduleRemoved
+	}
+
+	if err = writeNote(r, head.Hash(), formatNote(sections)); err != nil {
+		return fmt.Errorf("failed to write note for %v: %w", head.Hash(), err)
+	}
+
+	klog.InfoS("Wrote local replaces note", "commit", head.Hash().String(), "ref", NotesRef, "count", count)
+
+	return nil
+}
+
+// ReadLocalReplacesNote reads the local replaces noted by WriteLocalReplacesNote for the given revision
+// and the module in base.
+func ReadLocalReplacesNote(base string, revision string) (replaces []LocalReplace, err error) {
+	r, err := git.PlainOpenWithOptions(base, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return
+	}
+
+	hash, err := r.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %#v: %w", revision, err)
+	}
+
+	note, err := readNote(r, *hash)
+	if err != nil {
+		return
+	}
+
+	sections, err := parseNote(note)
+	if err != nil {
+		return
+	}
+
+	dir, err := gitdir.ModuleDir(base)
+	if err != nil {
+		return
+	}
+
+	// Notes of older gogit versions have no sections and belong to any module.
+	return append(sections[""], sections[dir]...), nil
+}
+
+// parseNote parses the output of formatNote into the local replaces by module directory.
+// Lines before the first section header are returned for the empty directory.
+func parseNote(note string) (sections map[string][]LocalReplace, err error) {
+	sections = map[string][]LocalReplace{}
+	dir := ""
+
+	for _, line := range strings.Split(note, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			dir = line[1 : len(line)-1]
+			continue
+		}
+
+		rep, err := ParseLocalReplace(line)
+		if err != nil {
+			return nil, err
+		}
+
+		sections[dir] = append(sections[dir], rep)
+	}
+
+	return sections, nil
+}
+
+// formatNote formats the local replaces by module directory with a section per directory in order.
+func formatNote(sections map[string][]LocalReplace) string {
+	dirs := make([]string, 0, len(sections))
+	for dir := range sections {
+		dirs = append(dirs, dir)
+	}
+
+	sort.Strings(dirs)
+
+	var lines []string
+
+	for _, dir := range dirs {
+		lines = append(lines, "["+dir+"]")
+
+		for _, rep := range sections[dir] {
+			lines = append(lines, rep.String())
+		}
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// RestoreLocalReplaces re-adds the local replaces noted for revision to the go.mod in base.
+//
+// Replaces whose target directory does not exist relative to base are skipped.
+// It is an error if the target declares a different module.
+func RestoreLocalReplaces(base string, revision string) (err error) {
+	replaces, err := ReadLocalReplacesNote(base, revision)
+	if err != nil {
+		return
+	}
+
+	gomodFilepath, data, err := getGoModFilepathAndData(base)
+	if err != nil {
+		return
+	}
+
+	var restored []LocalReplace
+
+	for _, rep := range replaces {
+		ok, err := isLocalReplaceTargetPresent(base, rep)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			klog.InfoS("Skipping local replace because its target does not exist", "replace", rep.String())
+			continue
+		}
+
+		if head := localHead(base, rep.New); len(rep.Head) != 0 && head != rep.Head {
+			klog.InfoS("Local replace target moved on since the commit", "replace", rep.String(), "head", head)
+		}
+
+		restored = append(restored, rep)
+	}
+
+	if len(restored) == 0 {
+		return errNoLocalReplaceRestored
+	}
+
+	dataOut, err := setLocalReplaceLines(gomodFilepath, data, restored, false)
+	if err != nil {
+		return
+	}
+
+	if err = writeGomod(gomodFilepath, dataOut); err != nil {
+		return
+	}
+
+	klog.InfoS("Restored local replaces", "go.mod", gomodFilepath, "revision", revision, "count", len(restored))
+
+	return nil
+}
+
+// isLocalReplaceTargetPresent returns true if the replace target exists relative to base.
+func isLocalReplaceTargetPresent(base string, rep LocalReplace) (ok bool, err error) {
+	target := filepath.Join(base, rep.New, "go.mod")
+
+	exists, err := helper.DoesPathExistErr(target)
+	if err != nil || !exists {
+		return false, err
+	}
+
+	data, err := ioutil.ReadFile(target)
+	if err != nil {
+		return
+	}
+
+	if modulePath := modfile.ModulePath(data); modulePath != rep.Old.Path {
+		return false, fmt.Errorf("%w: %#v declares %#v instead of %#v", errLocalReplaceModuleDiffs, target, modulePath, rep.Old.Path)
+	}
+
+	return true, nil
+}
+
+// readNote reads the note attached to the given commit from NotesRef.
+func readNote(r *git.Repository, commit plumbing.Hash) (note string, err error) {
+	tree, err := notesTree(r)
+	if err != nil {
+		return
+	}
+
+	if tree == nil {
+		return "", fmt.Errorf("%w for %v", errNoNote, commit)
+	}
+
+	name := commit.String()
+
+	// Git fans notes out into levels of sub-directories once there are many of them.
+	file, err := tree.File(name)
+	for fanout := 1; err == object.ErrFileNotFound && 2*fanout < len(name); fanout++ {
+		file, err = tree.File(fanoutPath(name, fanout))
+	}
+
+	if err == object.ErrFileNotFound {
+		return "", fmt.Errorf("%w for %v", errNoNote, commit)
+	}
+
+	if err != nil {
+		return
+	}
+
+	return file.Contents()
+}
+
+// writeNote attaches the note to the given commit by committing it to NotesRef.
+func writeNote(r *git.Repository, commit plumbing.Hash, note string) (err error) {
+	blob := r.Storer.NewEncodedObject()
+	blob.SetType(plumbing.BlobObject)
+
+	w, err := blob.Writer()
+	if err != nil {
+		return
+	}
+
+	if _, err = w.Write([]byte(note)); err != nil {
+		return
+	}
+
+	if err = w.Close(); err != nil {
+		return
+	}
+
+	blobHash, err := r.Storer.SetEncodedObject(blob)
+	if err != nil {
+		return
+	}
+
+	var parents []plumbing.Hash
+
+	var entries []object.TreeEntry
+
+	ref, err := r.Reference(NotesRef, true)
+	switch err {
+	case nil:
+		parents = append(parents, ref.Hash())
+
+		tree, err := notesTree(r)
+		if err != nil {
+			return err
+		}
+
+		entries = tree.Entries
+	case plumbing.ErrReferenceNotFound:
+	default:
+		return err
+	}
+
+	treeHash, err := storeNotesTree(r, entries, commit.String(), blobHash)
+	if err != nil {
+		return
+	}
+
+	signature := notesSignature(r)
+
+	commitObject := r.Storer.NewEncodedObject()
+	if err = (&object.Commit{
+		Author:       signature,
+		Committer:    signature,
+		Message:      "Notes added by 'gogit'\n",
+		TreeHash:     treeHash,
+		ParentHashes: parents,
+	}).Encode(commitObject); err != nil {
+		return
+	}
+
+	commitHash, err := r.Storer.SetEncodedObject(commitObject)
+	if err != nil {
+		return
+	}
+
+	return r.Storer.SetReference(plumbing.NewHashReference(NotesRef, commitHash))
+}
+
+// storeNotesTree stores the notes tree with entries and the note blob for name, the rest of the commit hash
+// below the fan-out directories entries are in.
+//
+// Like git it descends into an existing fan-out directory named after the next two characters of name.
+// A note for the same commit at this level is replaced, so there is only ever one.
+func storeNotesTree(r *git.Repository, entries []object.TreeEntry, name string, blob plumbing.Hash) (hash plumbing.Hash, err error) {
+	kept := make([]object.TreeEntry, 0, len(entries)+1)
+
+	var fanout *object.TreeEntry
+
+	for i, entry := range entries {
+		switch {
+		case entry.Name == name:
+		case entry.Mode == filemode.Dir && len(name) > 2 && entry.Name == name[:2]:
+			fanout = &entries[i]
+		default:
+			kept = append(kept, entry)
+		}
+	}
+
+	note := object.TreeEntry{Name: name, Mode: filemode.Regular, Hash: blob}
+
+	if fanout != nil {
+		subtree, err := r.TreeObject(fanout.Hash)
+		if err != nil {
+			return hash, err
+		}
+
+		subtreeHash, err := storeNotesTree(r, subtree.Entries, name[2:], blob)
+		if err != nil {
+			return hash, err
+		}
+
+		note = object.TreeEntry{Name: fanout.Name, Mode: filemode.Dir, Hash: subtreeHash}
+	}
+
+	kept = append(kept, note)
+	sortTreeEntries(kept)
+
+	treeObject := r.Storer.NewEncodedObject()
+	if err = (&object.Tree{Entries: kept}).Encode(treeObject); err != nil {
+		return
+	}
+
+	return r.Storer.SetEncodedObject(treeObject)
+}
+
+// fanoutPath returns the path of the note for name below the given number of fan-out directories,
+// e.g. ab/cd/ef01… for two.
+func fanoutPath(name string, fanout int) string {
+	parts := make([]string, 0, fanout+1)
+	for i := 0; i < fanout; i++ {
+		parts = append(parts, name[2*i:2*i+2])
+	}
+
+	return strings.Join(append(parts, name[2*fanout:]), "/")
+}
+
+// notesTree returns the tree NotesRef points to or nil if there are no notes yet.
+func notesTree(r *git.Repository) (tree *object.Tree, err error) {
+	ref, err := r.Reference(NotesRef, true)
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return
+	}
+
+	commit, err := r.CommitObject(ref.Hash())
+	if err != nil {
+		return
+	}
+
+	return commit.Tree()
+}
+
+// sortTreeEntries sorts the entries the way git expects them, directories sort as if they had a trailing slash.
+func sortTreeEntries(entries []object.TreeEntry) {
+	key := func(e object.TreeEntry) string {
+		if e.Mode == filemode.Dir {
+			return e.Name + "/"
+		}
+
+		return e.Name
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return key(entries[i]) < key(entries[j])
+	})
+}
+
+// notesSignature returns the user configured in the repository or a gogit signature.
+func notesSignature(r *git.Repository) object.Signature {
+	signature := object.Signature{
+		Name:  "gogit",
+		Email: "gogit@localhost",
+		When:  time.Now(),
+	}
+
+	cfg, err := r.Config()
+	if err != nil {
+		return signature
+	}
+
+	user := cfg.Raw.Section("user")
+	if name := user.Option("name"); len(name) != 0 {
+		signature.Name = name
+	}
+
+	if email := user.Option("email"); len(email) != 0 {
+		signature.Email = email
+	}
+
+	return signature
+}
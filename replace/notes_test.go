@@ -0,0 +1,230 @@
+package replace
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+func TestLocalReplacesNote(t *testing.T) {
+	tempDir, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(tempDir); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	base := filepath.Join(tempDir, "k")
+	utils := filepath.Join(tempDir, "utils")
+
+	for _, dir := range []string{base, utils} {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	utilsHead := commitFile(t, utils, "go.mod", "module aduu.dev/utils\n")
+
+	stripped := `module aduu.dev/k
+
+go 1.14
+
+require aduu.dev/utils v1.0.0
+`
+	local := stripped + `
+replace aduu.dev/utils => ../utils
+`
+	commitFile(t, base, "go.mod", stripped)
+
+	// Simulate a commit: pre-commit strips, post-commit notes and undoes.
+	if err = ioutil.WriteFile(filepath.Join(base, "go.mod"), []byte(local), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = RemoveLocalReplacesFromGomod(base, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = WriteLocalReplacesNote(base); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = UndoRemovingLocalReplacesFromGomod(base, false); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []LocalReplace{
+		{
+			Old:  module.Version{Path: "aduu.dev/utils"},
+			New:  "../utils",
+			Head: utilsHead,
+		},
+	}
+
+	got, err := ReadLocalReplacesNote(base, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, want, got)
+
+	// Writing the note again replaces it instead of adding a second one.
+	if err = RemoveLocalReplacesFromGomod(base, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = WriteLocalReplacesNote(base); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = UndoRemovingLocalReplacesFromGomod(base, false); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err = ReadLocalReplacesNote(base, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, want, got)
+
+	// Restore on a "fresh clone" with the same sibling layout.
+	if err = ioutil.WriteFile(filepath.Join(base, "go.mod"), []byte(stripped), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = RestoreLocalReplaces(base, "HEAD"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(base, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := modfile.Parse("go.mod", data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, file.Replace, 1) {
+		assert.Equal(t, "aduu.dev/utils", file.Replace[0].Old.Path)
+		assert.Equal(t, "../utils", file.Replace[0].New.Path)
+	}
+}
+
+func TestRestoreLocalReplaces_errors_without_note(t *testing.T) {
+	tempDir, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(tempDir); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	commitFile(t, tempDir, "go.mod", "module aduu.dev/k\n")
+
+	if err = RestoreLocalReplaces(tempDir, "HEAD"); err == nil {
+		t.Fatal("RestoreLocalReplaces should fail if there is no note")
+	}
+}
+
+func Test_writeNote_fanout(t *testing.T) {
+	tempDir, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(tempDir); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	head := plumbing.NewHash(commitFile(t, tempDir, "go.mod", "module aduu.dev/k\n"))
+	name := head.String()
+
+	r, err := git.PlainOpen(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fan the notes out like git does once there are many of them: the note of HEAD is below an empty
+	// directory named after the first two characters of its hash.
+	emptyTree := r.Storer.NewEncodedObject()
+	if err = (&object.Tree{}).Encode(emptyTree); err != nil {
+		t.Fatal(err)
+	}
+
+	emptyTreeHash, err := r.Storer.SetEncodedObject(emptyTree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	treeHash, err := storeNotesTree(r, []object.TreeEntry{{Name: name[:2], Mode: filemode.Dir, Hash: emptyTreeHash}}, name, plumbing.ZeroHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commitObject := r.Storer.NewEncodedObject()
+	if err = (&object.Commit{TreeHash: treeHash}).Encode(commitObject); err != nil {
+		t.Fatal(err)
+	}
+
+	commitHash, err := r.Storer.SetEncodedObject(commitObject)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = r.Storer.SetReference(plumbing.NewHashReference(NotesRef, commitHash)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = writeNote(r, head, "first\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = writeNote(r, head, "second\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	note, err := readNote(r, head)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "second\n", note)
+
+	tree, err := notesTree(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = tree.File(name)
+	assert.Equal(t, object.ErrFileNotFound, err, "the note should not be added flat next to the fan-out directory")
+
+	_, err = tree.File(fanoutPath(name, 1))
+	assert.NoError(t, err, "the note should be replaced below the fan-out directory")
+	assert.Len(t, tree.Entries, 1)
+}
+
+func Test_fanoutPath(t *testing.T) {
+	assert.Equal(t, "ab/cdef", fanoutPath("abcdef", 1))
+	assert.Equal(t, "ab/cd/ef", fanoutPath("abcdef", 2))
+}
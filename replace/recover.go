@@ -0,0 +1,15 @@
+package replace
+
+import (
+	"path/filepath"
+
+	"aduu.dev/utils/helper"
+)
+
+// HasBackup returns true if the go.mod in base was stripped into a backup which was not undone yet.
+//
+// Before a commit this means the previous commit was aborted after the pre-commit hook
+// stripped go.mod, so the post-commit hook never restored it.
+func HasBackup(base string) (exists bool, err error) {
+	return helper.DoesPathExistErr(filepath.Join(base, backupFilename()))
+}
@@ -0,0 +1,168 @@
+package replace
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"aduu.dev/utils/helper"
+	"github.com/go-git/go-git/v5"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"k8s.io/klog/v2"
+)
+
+var (
+	errInvalidLocalReplace = fmt.Errorf("invalid local replace")
+)
+
+// LocalReplace describes a local replace directive together with the HEAD
+// of the checkout it points to.
+type LocalReplace struct {
+	Old module.Version
+	// New is the local path the module is replaced with.
+	New string
+	// Head is the HEAD commit hash of the checkout at New. It is empty if unknown.
+	Head string
+}
+
+// String returns the local replace in the form
+// "aduu.dev/utils => ../aduu-dev-utils @ <head>".
+func (r LocalReplace) String() string {
+	s := r.Old.Path
+	if len(r.Old.Version) != 0 {
+		s += " " + r.Old.Version
+	}
+
+	s += " => " + r.New
+
+	if len(r.Head) != 0 {
+		s += " @ " + r.Head
+	}
+
+	return s
+}
+
+// ParseLocalReplace parses the output of LocalReplace.String.
+func ParseLocalReplace(s string) (rep LocalReplace, err error) {
+	parts := strings.SplitN(s, "=>", 2)
+	if len(parts) != 2 {
+		return rep, fmt.Errorf("%w: %#v is missing =>", errInvalidLocalReplace, s)
+	}
+
+	old := strings.Fields(parts[0])
+	switch len(old) {
+	case 1:
+		rep.Old.Path = old[0]
+	case 2:
+		rep.Old.Path, rep.Old.Version = old[0], old[1]
+	default:
+		return rep, fmt.Errorf("%w: %#v has an invalid module", errInvalidLocalReplace, s)
+	}
+
+	newParts := strings.SplitN(parts[1], " @ ", 2)
+	rep.New = strings.TrimSpace(newParts[0])
+
+	if len(newParts) == 2 {
+		rep.Head = strings.TrimSpace(newParts[1])
+	}
+
+	if len(rep.New) == 0 {
+		return rep, fmt.Errorf("%w: %#v has no local path", errInvalidLocalReplace, s)
+	}
+
+	return rep, nil
+}
+
+// RemovedLocalReplaces returns the local replace directives which are in the
+// backup go.mod.b but no longer in go.mod, i.e. the ones RemoveLocalReplacesFromGomod removed.
+//
+// Without a backup it returns the local replace directives DisableLocalReplaces commented out.
+func RemovedLocalReplaces(base string) (removed []LocalReplace, err error) {
+	backup := filepath.Join(base, backupFilename())
+
+	exists, err := helper.DoesPathExistErr(backup)
+	if err != nil {
+		return
+	}
+
+	if !exists {
+		return disabledWithHeads(base)
+	}
+
+	backupData, err := ioutil.ReadFile(backup)
+	if err != nil {
+		return
+	}
+
+	backupFile, err := modfile.Parse(backup, backupData, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse backup at %#v: %w", backup, err)
+	}
+
+	gomodFilepath, data, err := getGoModFilepathAndData(base)
+	if err != nil {
+		return
+	}
+
+	file, err := modfile.Parse(gomodFilepath, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse modfile at %#v: %w", gomodFilepath, err)
+	}
+
+	remaining := make(map[module.Version]bool, len(file.Replace))
+	for _, rep := range file.Replace {
+		remaining[rep.Old] = true
+	}
+
+	for _, rep := range removeLocalReplaceDirectives(backupFile.Replace) {
+		if remaining[rep.Old] {
+			continue
+		}
+
+		removed = append(removed, LocalReplace{
+			Old:  rep.Old,
+			New:  rep.New.Path,
+			Head: localHead(base, rep.New.Path),
+		})
+	}
+
+	return removed, nil
+}
+
+func disabledWithHeads(base string) (disabled []LocalReplace, err error) {
+	disabled, err = DisabledLocalReplaces(base)
+	if err != nil {
+		return
+	}
+
+	for i := range disabled {
+		disabled[i].Head = localHead(base, disabled[i].New)
+	}
+
+	return disabled, nil
+}
+
+// localHead returns the HEAD commit hash of the git checkout containing the local path
+// relative to base. It returns an empty string if there is none.
+func localHead(base string, localPath string) string {
+	target := localPath
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(base, localPath)
+	}
+
+	r, err := git.PlainOpenWithOptions(target, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		klog.InfoS("Local replace target is no git repository", "path", target, "err", err)
+		return ""
+	}
+
+	head, err := r.Head()
+	if err != nil {
+		klog.InfoS("Failed to resolve HEAD of local replace target", "path", target, "err", err)
+		return ""
+	}
+
+	return head.Hash().String()
+}
@@ -0,0 +1,113 @@
+package replace
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/mod/module"
+)
+
+func TestRemovedLocalReplaces(t *testing.T) {
+	tempDir, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(tempDir); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	base := filepath.Join(tempDir, "k")
+	utils := filepath.Join(tempDir, "utils")
+
+	for _, dir := range []string{base, utils} {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The replace target is a git repository with one commit.
+	head := commitFile(t, utils, "go.mod", "module aduu.dev/utils\n")
+
+	gomod := `module aduu.dev/k
+
+go 1.14
+
+require (
+	aduu.dev/other v1.0.0
+	aduu.dev/utils v1.0.0
+)
+
+replace aduu.dev/utils => ../utils
+
+replace aduu.dev/other => aduu.dev/fork v1.0.0
+`
+	if err = ioutil.WriteFile(filepath.Join(base, "go.mod"), []byte(gomod), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := RemovedLocalReplaces(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Empty(t, removed, "nothing is removed without a backup")
+
+	if err = RemoveLocalReplacesFromGomod(base, false); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err = RemovedLocalReplaces(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []LocalReplace{
+		{
+			Old:  module.Version{Path: "aduu.dev/utils"},
+			New:  "../utils",
+			Head: head,
+		},
+	}, removed)
+}
+
+// commitFile initializes a git repository in dir if necessary, commits the file and returns the commit hash.
+func commitFile(t *testing.T, dir string, name string, content string) string {
+	r, err := git.PlainOpen(dir)
+	if err == git.ErrRepositoryNotExists {
+		r, err = git.PlainInit(dir, false)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = w.Add(name); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := w.Commit("add "+name, &git.CommitOptions{
+		Author: &object.Signature{Name: "gogit", Email: "gogit@aduu.dev"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hash.String()
+}
@@ -0,0 +1,58 @@
+package replace
+
+import (
+	"fmt"
+
+	"golang.org/x/mod/modfile"
+	"k8s.io/klog/v2"
+)
+
+// Requires returns the required module versions of the go.mod in base keyed by module path.
+func Requires(base string) (requires map[string]string, err error) {
+	gomodFilepath, data, err := getGoModFilepathAndData(base)
+	if err != nil {
+		return
+	}
+
+	return parseRequires(gomodFilepath, data)
+}
+
+// ParseRequires returns the required module versions of the go.mod content keyed by module path.
+func ParseRequires(data []byte) (requires map[string]string, err error) {
+	return parseRequires("go.mod", data)
+}
+
+func parseRequires(gomodFilepath string, data []byte) (requires map[string]string, err error) {
+	file, err := modfile.Parse(gomodFilepath, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse modfile at %#v: %w", gomodFilepath, err)
+	}
+
+	requires = make(map[string]string, len(file.Require))
+	for _, req := range file.Require {
+		requires[req.Mod.Path] = req.Mod.Version
+	}
+
+	return requires, nil
+}
+
+// SetRequire sets the required version of the module in the go.mod in base.
+func SetRequire(base string, modulePath string, version string) (err error) {
+	gomodFilepath, data, err := getGoModFilepathAndData(base)
+	if err != nil {
+		return
+	}
+
+	dataOut, err := setRequireLine(gomodFilepath, data, modulePath, version)
+	if err != nil {
+		return
+	}
+
+	if err = writeGomod(gomodFilepath, dataOut); err != nil {
+		return
+	}
+
+	klog.InfoS("Set required version", "go.mod", gomodFilepath, "module", modulePath, "version", version)
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package replace
+
+import (
+	"fmt"
+
+	"golang.org/x/mod/modfile"
+	"k8s.io/klog/v2"
+)
+
+// Strip removes the local replace directives from the go.mod in base without creating a backup.
+//
+// It is meant for throw-away copies of a module. stripped is false if there was nothing to remove.
+func Strip(base string) (stripped bool, err error) {
+	gomodFilepath, data, err := getGoModFilepathAndData(base)
+	if err != nil {
+		return
+	}
+
+	file, err := modfile.Parse(gomodFilepath, data, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse modfile at %#v: %w", gomodFilepath, err)
+	}
+
+	dataOut, stripped := stripLocalReplaces(file, data)
+	if !stripped {
+		return
+	}
+
+	if err = writeGomod(gomodFilepath, dataOut); err != nil {
+		return
+	}
+
+	klog.InfoS("Stripped local replace directives without backup", "go.mod", gomodFilepath)
+
+	return true, nil
+}
@@ -0,0 +1,142 @@
+package replace
+
+import (
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	localReplaceTrailer = "Local-Replace"
+	scissorsLine        = "# ------------------------ >8 ------------------------"
+)
+
+var trailerLine = regexp.MustCompile(`^[A-Za-z0-9-]+:\s`)
+
+// AddLocalReplaceTrailers appends a Local-Replace trailer to the commit message in messageFile
+// for each local replace directive RemoveLocalReplacesFromGomod removed from the go.mod in base.
+//
+// Existing Local-Replace trailers are replaced so running it again on amend is idempotent,
+// also when nothing was removed and stale trailers of the amended commit have to go.
+func AddLocalReplaceTrailers(base string, messageFile string) (err error) {
+	return AddModulesLocalReplaceTrailers([]string{base}, messageFile)
+}
+
+// AddModulesLocalReplaceTrailers is AddLocalReplaceTrailers for the modules in bases which are committed together.
+// The trailers of all modules replace the existing ones at once.
+func AddModulesLocalReplaceTrailers(bases []string, messageFile string) (err error) {
+	var removed []LocalReplace
+
+	for _, base := range bases {
+		moduleRemoved, err := RemovedLocalReplaces(base)
+		if err != nil {
+			return err
+		}
+
+		removed = append(removed, moduleRemoved...)
+	}
+
+	stat, err := os.Stat(messageFile)
+	if err != nil {
+		return
+	}
+
+	message, err := ioutil.ReadFile(messageFile)
+	if err != nil {
+		return
+	}
+
+	newMessage := withLocalReplaceTrailers(string(message), removed)
+	if newMessage == string(message) {
+		return nil
+	}
+
+	if err = ioutil.WriteFile(messageFile, []byte(newMessage), stat.Mode()); err != nil {
+		return
+	}
+
+	klog.InfoS("Added local replace trailers", "message", messageFile, "count", len(removed))
+
+	return nil
+}
+
+// withLocalReplaceTrailers returns message with all Local-Replace trailers replaced by the given ones.
+//
+// Trailers are added in front of the comment lines git appends to the message.
+// An empty message stays empty so git still aborts the commit.
+func withLocalReplaceTrailers(message string, replaces []LocalReplace) string {
+	lines := strings.Split(message, "\n")
+
+	// Everything below the scissors line is ignored by git.
+	end := len(lines)
+	for i, l := range lines {
+		if l == scissorsLine {
+			end = i
+			break
+		}
+	}
+
+	// Find the last line which is neither empty nor a comment.
+	last := -1
+	for i := 0; i < end; i++ {
+		if len(strings.TrimSpace(lines[i])) != 0 && !strings.HasPrefix(lines[i], "#") {
+			last = i
+		}
+	}
+
+	if last == -1 {
+		return message
+	}
+
+	body := make([]string, 0, last+1+len(replaces))
+	for _, l := range lines[:last+1] {
+		if strings.HasPrefix(l, localReplaceTrailer+":") {
+			continue
+		}
+
+		body = append(body, l)
+	}
+
+	for len(body) > 0 && len(strings.TrimSpace(body[len(body)-1])) == 0 {
+		body = body[:len(body)-1]
+	}
+
+	if len(replaces) != 0 && !endsWithTrailerBlock(body) {
+		body = append(body, "")
+	}
+
+	for _, rep := range replaces {
+		body = append(body, localReplaceTrailer+": "+rep.String())
+	}
+
+	rest := lines[last+1:]
+	if len(rest) == 0 {
+		return strings.Join(body, "\n")
+	}
+
+	return strings.Join(body, "\n") + "\n" + strings.Join(rest, "\n")
+}
+
+// endsWithTrailerBlock returns true if the last paragraph of lines consists of trailers only.
+// The subject paragraph never counts as a trailer block.
+func endsWithTrailerBlock(lines []string) bool {
+	start := len(lines)
+	for start > 0 && len(strings.TrimSpace(lines[start-1])) != 0 {
+		start--
+	}
+
+	if start == 0 || start == len(lines) {
+		return false
+	}
+
+	for _, l := range lines[start:] {
+		if !trailerLine.MatchString(l) {
+			return false
+		}
+	}
+
+	return true
+}
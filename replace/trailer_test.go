@@ -0,0 +1,179 @@
+package replace
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/mod/module"
+)
+
+func Test_withLocalReplaceTrailers(t *testing.T) {
+	replaces := []LocalReplace{
+		{
+			Old:  module.Version{Path: "aduu.dev/utils"},
+			New:  "../aduu-dev-utils",
+			Head: "0123456789abcdef0123456789abcdef01234567",
+		},
+	}
+
+	trailer := "Local-Replace: aduu.dev/utils => ../aduu-dev-utils @ 0123456789abcdef0123456789abcdef01234567"
+
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{
+			name:    "subject only",
+			message: "subject\n",
+			want:    "subject\n\n" + trailer + "\n",
+		},
+		{
+			name:    "subject and body",
+			message: "subject\n\nbody",
+			want:    "subject\n\nbody\n\n" + trailer,
+		},
+		{
+			name:    "append to existing trailer block",
+			message: "subject\n\nSigned-off-by: me <me@example.com>\n",
+			want:    "subject\n\nSigned-off-by: me <me@example.com>\n" + trailer + "\n",
+		},
+		{
+			name:    "amend replaces existing trailers",
+			message: "subject\n\nLocal-Replace: aduu.dev/utils => ../old\n",
+			want:    "subject\n\n" + trailer + "\n",
+		},
+		{
+			name:    "trailers go before git comments",
+			message: "subject\n\n# Please enter the commit message.\n#\n",
+			want:    "subject\n\n" + trailer + "\n\n# Please enter the commit message.\n#\n",
+		},
+		{
+			name:    "ignore everything after the scissors line",
+			message: "subject\n" + scissorsLine + "\ndiff --git a/go.mod b/go.mod\n",
+			want:    "subject\n\n" + trailer + "\n" + scissorsLine + "\ndiff --git a/go.mod b/go.mod\n",
+		},
+		{
+			name:    "empty message stays empty",
+			message: "\n# Please enter the commit message.\n",
+			want:    "\n# Please enter the commit message.\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := withLocalReplaceTrailers(tt.message, replaces)
+			assert.Equal(t, tt.want, got)
+
+			// Running it again (e.g. on amend) must not change anything.
+			assert.Equal(t, tt.want, withLocalReplaceTrailers(got, replaces), "should be idempotent")
+		})
+	}
+}
+
+func Test_withLocalReplaceTrailers_none(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{
+			name:    "amend removes stale trailers",
+			message: "subject\n\nbody\n\nLocal-Replace: aduu.dev/utils => ../old\n",
+			want:    "subject\n\nbody\n",
+		},
+		{
+			name:    "other trailers are kept",
+			message: "subject\n\nSigned-off-by: me <me@example.com>\nLocal-Replace: aduu.dev/utils => ../old\n",
+			want:    "subject\n\nSigned-off-by: me <me@example.com>\n",
+		},
+		{
+			name:    "message without trailers is unchanged",
+			message: "subject\n\nbody\n\n# Please enter the commit message.\n",
+			want:    "subject\n\nbody\n\n# Please enter the commit message.\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, withLocalReplaceTrailers(tt.message, nil))
+		})
+	}
+}
+
+func TestAddLocalReplaceTrailers_amend_without_removed(t *testing.T) {
+	base, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(base); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if err = ioutil.WriteFile(filepath.Join(base, "go.mod"), []byte("module aduu.dev/k\n\ngo 1.14\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	messageFile := filepath.Join(base, "COMMIT_EDITMSG")
+	if err = ioutil.WriteFile(messageFile, []byte("subject\n\nLocal-Replace: aduu.dev/utils => ../old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = AddLocalReplaceTrailers(base, messageFile); err != nil {
+		t.Fatal(err)
+	}
+
+	fileHasContent(t, messageFile, "subject\n", "the trailers of the amended commit should be removed")
+}
+
+func TestParseLocalReplace(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    LocalReplace
+		wantErr bool
+	}{
+		{
+			name: "with head",
+			in:   "aduu.dev/utils => ../aduu-dev-utils @ abc",
+			want: LocalReplace{Old: module.Version{Path: "aduu.dev/utils"}, New: "../aduu-dev-utils", Head: "abc"},
+		},
+		{
+			name: "with version and without head",
+			in:   "aduu.dev/utils v1.0.0 => ./utils",
+			want: LocalReplace{Old: module.Version{Path: "aduu.dev/utils", Version: "v1.0.0"}, New: "./utils"},
+		},
+		{
+			name:    "missing arrow",
+			in:      "aduu.dev/utils ../aduu-dev-utils",
+			wantErr: true,
+		},
+		{
+			name:    "missing local path",
+			in:      "aduu.dev/utils =>",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLocalReplace(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLocalReplace(%#v) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.in, got.String(), "String should be the inverse of ParseLocalReplace")
+		})
+	}
+}
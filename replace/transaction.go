@@ -0,0 +1,337 @@
+package replace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"k8s.io/klog/v2"
+
+	"aduu.dev/tools/gogit/gitdir"
+	"aduu.dev/tools/gogit/lock"
+)
+
+const (
+	// journalStaging means the temporary files may be incomplete, an interrupted transaction is rolled back.
+	journalStaging = "staging"
+	// journalCommitted means all temporary files are complete, an interrupted transaction is rolled forward.
+	journalCommitted = "committed"
+
+	tempSuffix = ".gogit-tmp"
+)
+
+var (
+	errJournalCorrupt = fmt.Errorf("transaction journal is corrupt")
+)
+
+// transaction changes several files so that either all or none of the changes happen,
+// even if gogit is interrupted or the disk runs full.
+//
+// Changes are staged to temporary files next to their targets, synced and then renamed over the targets.
+// A journal records the progress, begin finishes or reverts an interrupted transaction.
+type transaction struct {
+	lock    *lock.Lock
+	journal string
+	writes  []*stagedWrite
+	removes []string
+}
+
+type stagedWrite struct {
+	path string
+	data []byte
+	mode os.FileMode
+}
+
+// journal is the on-disk record of a transaction.
+type journal struct {
+	State   string        `json:"state"`
+	Writes  []journalFile `json:"writes"`
+	Removes []string      `json:"removes"`
+}
+
+type journalFile struct {
+	Path string `json:"path"`
+	Temp string `json:"temp"`
+}
+
+// begin locks the repository of the module in base against concurrent gogit invocations,
+// recovers an interrupted transaction and starts a new one.
+//
+// The lock is held until end is called, so everything between begin and end happens exclusively.
+func begin(base string) (tx *transaction, err error) {
+	lockFile, err := stateFilepath(base, "lock")
+	if err != nil {
+		return
+	}
+
+	l, err := lock.Acquire(lockFile, lock.Timeout())
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			if releaseErr := l.Release(); releaseErr != nil {
+				klog.ErrorS(releaseErr, "Failed to release lock", "lock", lockFile)
+			}
+		}
+	}()
+
+	journalFile, err := stateFilepath(base, "journal")
+	if err != nil {
+		return
+	}
+
+	if err = recoverJournal(journalFile); err != nil {
+		return nil, fmt.Errorf("failed to recover interrupted transaction %#v: %w", journalFile, err)
+	}
+
+	return &transaction{lock: l, journal: journalFile}, nil
+}
+
+// end releases the lock taken by begin.
+func (tx *transaction) end() {
+	if err := tx.lock.Release(); err != nil {
+		klog.ErrorS(err, "Failed to release lock")
+	}
+}
+
+// stateFilepath returns the file with the given name below the gogit directory of the repository
+// or next to go.mod prefixed with .gogit- if base is not inside a git repository.
+func stateFilepath(base string, name string) (file string, err error) {
+	abs, err := filepath.Abs(base)
+	if err != nil {
+		return
+	}
+
+	if _, err = gitdir.Find(abs); err != nil {
+		return filepath.Join(abs, ".gogit-"+name), nil
+	}
+
+	gogitDir, err := gitdir.Gogit(abs)
+	if err != nil {
+		return
+	}
+
+	return filepath.Join(gogitDir, name), nil
+}
+
+// write stages writing data to path.
+func (tx *transaction) write(path string, data []byte, mode os.FileMode) {
+	tx.writes = append(tx.writes, &stagedWrite{path: path, data: data, mode: mode})
+}
+
+// remove stages removing path.
+func (tx *transaction) remove(path string) {
+	tx.removes = append(tx.removes, path)
+}
+
+// commit applies all staged changes.
+func (tx *transaction) commit() (err error) {
+	j, err := tx.stage()
+	if err != nil {
+		return
+	}
+
+	return rollForward(tx.journal, j)
+}
+
+// stage writes the temporary files and marks the journal as committed.
+// If staging fails the temporary files are removed again.
+func (tx *transaction) stage() (j *journal, err error) {
+	j = &journal{State: journalStaging}
+
+	for _, w := range tx.writes {
+		path, err := filepath.Abs(w.path)
+		if err != nil {
+			return nil, err
+		}
+
+		j.Writes = append(j.Writes, journalFile{
+			Path: path,
+			Temp: filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+tempSuffix),
+		})
+	}
+
+	for _, path := range tx.removes {
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+
+		j.Removes = append(j.Removes, path)
+	}
+
+	// The journal lists the temporary files before they exist so they are cleaned up after a crash.
+	if err = writeJournal(tx.journal, j); err != nil {
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			if rollbackErr := rollback(tx.journal, j); rollbackErr != nil {
+				klog.ErrorS(rollbackErr, "Failed to roll back transaction", "journal", tx.journal)
+			}
+		}
+	}()
+
+	for i, w := range tx.writes {
+		if err = writeSynced(j.Writes[i].Temp, w.data, w.mode); err != nil {
+			return
+		}
+	}
+
+	j.State = journalCommitted
+	if err = writeJournal(tx.journal, j); err != nil {
+		return
+	}
+
+	return j, nil
+}
+
+// recoverJournal finishes or reverts the transaction recorded in the journal, if there is one.
+func recoverJournal(journalFile string) (err error) {
+	data, err := ioutil.ReadFile(journalFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return
+	}
+
+	j := &journal{}
+	if err = json.Unmarshal(data, j); err != nil {
+		return fmt.Errorf("%w: %v", errJournalCorrupt, err)
+	}
+
+	switch j.State {
+	case journalStaging:
+		klog.InfoS("Rolling back interrupted transaction", "journal", journalFile)
+		return rollback(journalFile, j)
+	case journalCommitted:
+		klog.InfoS("Rolling forward interrupted transaction", "journal", journalFile)
+		return rollForward(journalFile, j)
+	default:
+		return fmt.Errorf("%w: unknown state %#v", errJournalCorrupt, j.State)
+	}
+}
+
+func rollback(journalFile string, j *journal) (err error) {
+	for _, w := range j.Writes {
+		if err = os.Remove(w.Temp); err != nil && !os.IsNotExist(err) {
+			return
+		}
+	}
+
+	return removeJournal(journalFile)
+}
+
+// rollForward renames the temporary files over their targets, removes files and finally the journal.
+// It is idempotent so it can be repeated if it is interrupted itself.
+func rollForward(journalFile string, j *journal) (err error) {
+	for _, w := range j.Writes {
+		// A missing temporary file was renamed already.
+		if err = os.Rename(w.Temp, w.Path); err != nil && !os.IsNotExist(err) {
+			return
+		}
+
+		syncDir(filepath.Dir(w.Path))
+	}
+
+	for _, path := range j.Removes {
+		if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return
+		}
+
+		syncDir(filepath.Dir(path))
+	}
+
+	return removeJournal(journalFile)
+}
+
+func writeJournal(journalFile string, j *journal) (err error) {
+	data, err := json.Marshal(j)
+	if err != nil {
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Dir(journalFile), 0755); err != nil {
+		return
+	}
+
+	temp := journalFile + tempSuffix
+	if err = writeSynced(temp, data, 0644); err != nil {
+		return
+	}
+
+	if err = os.Rename(temp, journalFile); err != nil {
+		return
+	}
+
+	syncDir(filepath.Dir(journalFile))
+
+	return nil
+}
+
+func removeJournal(journalFile string) (err error) {
+	if err = os.Remove(journalFile); err != nil && !os.IsNotExist(err) {
+		return
+	}
+
+	return nil
+}
+
+// writeSynced writes the file with the given mode and syncs it to disk.
+func writeSynced(path string, data []byte, mode os.FileMode) (err error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	// The umask and an already existing file must not change the mode.
+	if err = f.Chmod(mode); err != nil {
+		return
+	}
+
+	if _, err = f.Write(data); err != nil {
+		return
+	}
+
+	return f.Sync()
+}
+
+// syncDir syncs the directory so renames survive a crash.
+// It is best effort as not every platform supports syncing directories.
+func syncDir(dir string) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+
+	defer f.Close()
+
+	_ = f.Sync()
+}
+
+// fileMode returns the mode of the file or fallback if it does not exist.
+func fileMode(path string, fallback os.FileMode) (mode os.FileMode, err error) {
+	stat, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return fallback, nil
+	}
+
+	if err != nil {
+		return
+	}
+
+	return stat.Mode().Perm(), nil
+}
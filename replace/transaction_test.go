@@ -0,0 +1,135 @@
+package replace
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransaction(t *testing.T) {
+	base, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(base); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	gomod := filepath.Join(base, "go.mod")
+	sum := filepath.Join(base, "go.sum")
+	journalFile := filepath.Join(base, ".gogit-journal")
+
+	reset := func() {
+		for file, content := range map[string]string{gomod: "old go.mod\n", sum: "old go.sum\n"} {
+			if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// staged starts a transaction writing both files and removing go.sum.b, without committing it.
+	staged := func() *journal {
+		tx, err := begin(base)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tx.write(gomod, []byte("new go.mod\n"), 0600)
+		tx.write(sum, []byte("new go.sum\n"), 0600)
+		tx.remove(sum + ".b")
+
+		j, err := tx.stage()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The lock is released when gogit dies.
+		tx.end()
+
+		return j
+	}
+
+	t.Run("commit", func(t *testing.T) {
+		reset()
+
+		tx, err := begin(base)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer tx.end()
+
+		tx.write(gomod, []byte("new go.mod\n"), 0600)
+		tx.write(sum, []byte("new go.sum\n"), 0600)
+
+		if err = tx.commit(); err != nil {
+			t.Fatal(err)
+		}
+
+		fileHasContent(t, gomod, "new go.mod\n", "")
+		fileHasContent(t, sum, "new go.sum\n", "")
+		assert.NoFileExists(t, journalFile, "journal should be removed")
+
+		stat, err := os.Stat(gomod)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, os.FileMode(0600), stat.Mode().Perm(), "file mode should be kept")
+	})
+
+	t.Run("roll forward after interrupted rename", func(t *testing.T) {
+		reset()
+
+		j := staged()
+
+		// Interrupted after renaming the first file.
+		if err := os.Rename(j.Writes[0].Temp, j.Writes[0].Path); err != nil {
+			t.Fatal(err)
+		}
+
+		tx, err := begin(base)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tx.end()
+
+		fileHasContent(t, gomod, "new go.mod\n", "")
+		fileHasContent(t, sum, "new go.sum\n", "")
+		assert.NoFileExists(t, journalFile, "journal should be removed")
+	})
+
+	t.Run("roll back while staging", func(t *testing.T) {
+		reset()
+
+		j := staged()
+
+		// Interrupted while writing the temporary files.
+		j.State = journalStaging
+		if err := writeJournal(journalFile, j); err != nil {
+			t.Fatal(err)
+		}
+
+		tx, err := begin(base)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tx.end()
+
+		fileHasContent(t, gomod, "old go.mod\n", "")
+		fileHasContent(t, sum, "old go.sum\n", "")
+		assert.NoFileExists(t, journalFile, "journal should be removed")
+
+		for _, w := range j.Writes {
+			assert.NoFileExists(t, w.Temp, "temporary files should be removed")
+		}
+	})
+}
@@ -0,0 +1,140 @@
+package replace
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"aduu.dev/utils/helper"
+	"golang.org/x/mod/modfile"
+	"k8s.io/klog/v2"
+)
+
+func vendorModulesFilepath(base string) string {
+	return filepath.Join(base, "vendor", "modules.txt")
+}
+
+func vendorBackupFilepath(base string) string {
+	return vendorModulesFilepath(base) + backupSuffix
+}
+
+// backupVendorModules stages a backup of vendor/modules.txt if the module vendors its dependencies.
+func backupVendorModules(tx *transaction, base string) (err error) {
+	return backupFile(tx, vendorModulesFilepath(base), vendorBackupFilepath(base))
+}
+
+// stripVendorModules stages vendor/modules.txt without the replacement annotations of the dropped
+// replace directives, so it stays consistent with the stripped go.mod for -mod=vendor.
+func stripVendorModules(tx *transaction, base string, dropped []*modfile.Replace) (err error) {
+	modules := vendorModulesFilepath(base)
+
+	exists, err := helper.DoesPathExistErr(modules)
+	if err != nil || !exists {
+		return
+	}
+
+	data, err := ioutil.ReadFile(modules)
+	if err != nil {
+		return
+	}
+
+	dataOut, locallyVendored := stripVendorReplaceAnnotations(data, dropped)
+
+	for _, modulePath := range locallyVendored {
+		klog.ErrorS(nil, "Vendored module was copied from a local directory and needs re-vendoring with go mod vendor",
+			"module", modulePath, "modules.txt", modules)
+	}
+
+	if string(dataOut) == string(data) {
+		return nil
+	}
+
+	mode, err := fileMode(modules, 0644)
+	if err != nil {
+		return
+	}
+
+	tx.write(modules, dataOut, mode)
+
+	return nil
+}
+
+// restoreVendorModules stages restoring vendor/modules.txt from its backup if there is one.
+func restoreVendorModules(tx *transaction, base string) (err error) {
+	return restoreFile(tx, vendorModulesFilepath(base), vendorBackupFilepath(base))
+}
+
+// stripVendorReplaceAnnotations removes the replacement annotations of the dropped replace directives
+// from the module lines of vendor/modules.txt:
+//
+//	# aduu.dev/utils v1.0.0 => ../utils   becomes   # aduu.dev/utils v1.0.0
+//	# aduu.dev/other => ../other          is removed as it only records the replacement
+//
+// locallyVendored lists the vendored modules whose files were copied from a local directory.
+func stripVendorReplaceAnnotations(data []byte, dropped []*modfile.Replace) (out []byte, locallyVendored []string) {
+	lines := strings.SplitAfter(string(data), "\n")
+	kept := make([]string, 0, len(lines))
+
+	for _, line := range lines {
+		content := strings.TrimRight(line, "\r\n")
+
+		if !strings.HasPrefix(content, "# ") {
+			kept = append(kept, line)
+			continue
+		}
+
+		fields := strings.Fields(strings.TrimPrefix(content, "# "))
+		arrow := indexOf(fields, "=>")
+
+		if arrow < 1 || arrow > 2 || arrow+1 >= len(fields) {
+			kept = append(kept, line)
+			continue
+		}
+
+		old := modfile.Replace{}
+		old.Old.Path = fields[0]
+
+		if arrow == 2 {
+			old.Old.Version = fields[1]
+		}
+
+		old.New.Path = fields[arrow+1]
+
+		if !isDroppedReplace(old, dropped) {
+			kept = append(kept, line)
+			continue
+		}
+
+		// A line without version only records the replacement.
+		if arrow == 1 {
+			continue
+		}
+
+		locallyVendored = append(locallyVendored, old.Old.Path)
+
+		kept = append(kept, "# "+strings.Join(fields[:arrow], " ")+line[len(content):])
+	}
+
+	return []byte(strings.Join(kept, "")), locallyVendored
+}
+
+func isDroppedReplace(rep modfile.Replace, dropped []*modfile.Replace) bool {
+	for _, d := range dropped {
+		if d.Old.Path == rep.Old.Path && d.New.Path == rep.New.Path &&
+			(len(d.Old.Version) == 0 || d.Old.Version == rep.Old.Version) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func indexOf(fields []string, field string) int {
+	for i, f := range fields {
+		if f == field {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,100 @@
+package replace
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+func Test_stripVendorReplaceAnnotations(t *testing.T) {
+	dropped := []*modfile.Replace{
+		{Old: module.Version{Path: "aduu.dev/utils"}, New: module.Version{Path: "../utils"}},
+		{Old: module.Version{Path: "aduu.dev/other", Version: "v1.0.0"}, New: module.Version{Path: "../other"}},
+	}
+
+	tests := []struct {
+		name                string
+		in                  string
+		want                string
+		wantLocallyVendored []string
+	}{
+		{
+			name:                "module line loses its annotation",
+			in:                  "# aduu.dev/utils v1.0.0 => ../utils\n## explicit\naduu.dev/utils/helper\n",
+			want:                "# aduu.dev/utils v1.0.0\n## explicit\naduu.dev/utils/helper\n",
+			wantLocallyVendored: []string{"aduu.dev/utils"},
+		},
+		{
+			name: "replacement only line is removed",
+			in:   "# golang.org/x/mod v0.3.0\ngolang.org/x/mod/modfile\n# aduu.dev/utils => ../utils\n",
+			want: "# golang.org/x/mod v0.3.0\ngolang.org/x/mod/modfile\n",
+		},
+		{
+			name: "other replaces are kept",
+			in:   "# aduu.dev/fork v1.0.0 => aduu.dev/fork2 v1.1.0\n# aduu.dev/other v2.0.0 => ../other\n",
+			want: "# aduu.dev/fork v1.0.0 => aduu.dev/fork2 v1.1.0\n# aduu.dev/other v2.0.0 => ../other\n",
+		},
+		{
+			name:                "versioned replace and CRLF line endings",
+			in:                  "# aduu.dev/other v1.0.0 => ../other\r\naduu.dev/other\r\n",
+			want:                "# aduu.dev/other v1.0.0\r\naduu.dev/other\r\n",
+			wantLocallyVendored: []string{"aduu.dev/other"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, locallyVendored := stripVendorReplaceAnnotations([]byte(tt.in), dropped)
+			assert.Equal(t, tt.want, string(got))
+			assert.Equal(t, tt.wantLocallyVendored, locallyVendored)
+		})
+	}
+}
+
+func TestRemoveLocalReplacesFromGomod_vendor(t *testing.T) {
+	base, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(base); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	gomod := "module aduu.dev/k\n\ngo 1.14\n\nrequire aduu.dev/utils v1.0.0\n\nreplace aduu.dev/utils => ../utils\n"
+	modules := "# aduu.dev/utils v1.0.0 => ../utils\n## explicit\naduu.dev/utils/helper\n"
+	modulesFilepath := filepath.Join(base, "vendor", "modules.txt")
+
+	if err = ioutil.WriteFile(filepath.Join(base, "go.mod"), []byte(gomod), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(modulesFilepath), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ioutil.WriteFile(modulesFilepath, []byte(modules), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = RemoveLocalReplacesFromGomod(base, false); err != nil {
+		t.Fatal(err)
+	}
+
+	fileHasContent(t, modulesFilepath, "# aduu.dev/utils v1.0.0\n## explicit\naduu.dev/utils/helper\n", "annotation should be stripped")
+	fileHasContent(t, modulesFilepath+".b", modules, "modules.txt should be backed up")
+
+	if err = UndoRemovingLocalReplacesFromGomod(base, false); err != nil {
+		t.Fatal(err)
+	}
+
+	fileHasContent(t, modulesFilepath, modules, "modules.txt should be restored")
+	assert.NoFileExists(t, modulesFilepath+".b", "backup should be removed")
+}
@@ -0,0 +1,227 @@
+// Package verify checks that modules build offline, in particular the staged state of a module
+// once its local replace directives are stripped.
+package verify
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"k8s.io/klog/v2"
+
+	"aduu.dev/tools/gogit/replace"
+)
+
+var (
+	errModuleOutsideRepository = fmt.Errorf("module is outside of the repository")
+)
+
+// Options configures Staged.
+type Options struct {
+	// Vet additionally runs go vet ./...
+	Vet bool
+}
+
+// Failure is returned by Staged and Module if the module does not build or vet.
+type Failure struct {
+	// Command is the failed command line.
+	Command string
+	// Output is the combined output of the command.
+	Output string
+}
+
+func (f *Failure) Error() string {
+	return fmt.Sprintf("module fails %q:\n%s", f.Command, f.Output)
+}
+
+// Staged materializes the index of the repository containing the module in base into a temporary directory,
+// strips the local replace directives of the module's go.mod and runs go build ./... (and go vet ./...)
+// offline against the local module cache.
+//
+// It returns a *Failure with the compiler output if a command fails.
+// The temporary directory is removed afterwards, also when gogit receives SIGINT or SIGTERM.
+func Staged(base string, opts Options) (err error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	defer signal.Stop(signals)
+
+	go func() {
+		select {
+		case sig := <-signals:
+			klog.InfoS("Cancelling verification", "signal", sig.String())
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	tempDir, err := ioutil.TempDir("", "gogit-verify")
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if removeErr := os.RemoveAll(tempDir); removeErr != nil {
+			klog.ErrorS(removeErr, "Failed to remove verification directory", "dir", tempDir)
+		}
+	}()
+
+	moduleDir, err := materializeIndex(base, tempDir)
+	if err != nil {
+		return
+	}
+
+	if _, err = replace.Strip(moduleDir); err != nil {
+		return
+	}
+
+	if err = goOffline(ctx, moduleDir, "-mod=mod", opts); err != nil {
+		return
+	}
+
+	klog.InfoS("Staged module builds", "module", base, "vet", opts.Vet)
+
+	return nil
+}
+
+// Module runs go build ./... (and go vet ./...) offline in the module in dir as it is.
+//
+// It uses -mod=readonly so the module's go.mod and go.sum are left untouched.
+// It returns a *Failure with the compiler output if a command fails.
+func Module(dir string, opts Options) (err error) {
+	return goOffline(context.Background(), dir, "-mod=readonly", opts)
+}
+
+// goOffline runs go build and optionally go vet in dir without network access.
+func goOffline(ctx context.Context, dir string, modFlag string, opts Options) (err error) {
+	commands := [][]string{{"go", "build", "./..."}}
+	if opts.Vet {
+		commands = append(commands, []string{"go", "vet", "./..."})
+	}
+
+	for _, command := range commands {
+		if err = runGo(ctx, dir, modFlag, command); err != nil {
+			return
+		}
+	}
+
+	return nil
+}
+
+// runGo runs the go command in dir without network access.
+func runGo(ctx context.Context, dir string, modFlag string, command []string) (err error) {
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS="+modFlag, "GOPROXY=off")
+
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if _, ok := err.(*exec.ExitError); ok {
+		return &Failure{Command: fmt.Sprint(command), Output: string(output)}
+	}
+
+	return err
+}
+
+// materializeIndex writes the files staged in the repository containing base into dir
+// and returns the directory of the module in there.
+func materializeIndex(base string, dir string) (moduleDir string, err error) {
+	r, err := git.PlainOpenWithOptions(base, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		return
+	}
+
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return
+	}
+
+	rel, err := filepath.Rel(w.Filesystem.Root(), absBase)
+	if err != nil {
+		return
+	}
+
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %#v", errModuleOutsideRepository, base)
+	}
+
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return
+	}
+
+	for _, entry := range idx.Entries {
+		target := filepath.Join(dir, filepath.FromSlash(entry.Name))
+
+		if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return
+		}
+
+		switch entry.Mode {
+		case filemode.Submodule:
+			// Submodules are not part of the index content.
+			continue
+		case filemode.Symlink:
+			link, err := blobContent(r, entry)
+			if err != nil {
+				return "", err
+			}
+
+			if err = os.Symlink(string(link), target); err != nil {
+				return "", err
+			}
+		default:
+			content, err := blobContent(r, entry)
+			if err != nil {
+				return "", err
+			}
+
+			mode := os.FileMode(0644)
+			if entry.Mode == filemode.Executable {
+				mode = 0755
+			}
+
+			if err = ioutil.WriteFile(target, content, mode); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return filepath.Join(dir, rel), nil
+}
+
+func blobContent(r *git.Repository, entry *index.Entry) (content []byte, err error) {
+	blob, err := r.BlobObject(entry.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read staged %#v: %w", entry.Name, err)
+	}
+
+	reader, err := blob.Reader()
+	if err != nil {
+		return
+	}
+
+	defer reader.Close()
+
+	return ioutil.ReadAll(reader)
+}
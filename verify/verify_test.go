@@ -0,0 +1,78 @@
+package verify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeAndStage(t *testing.T, w *git.Worktree, base string, files map[string]string) {
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(base, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := w.Add(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStaged(t *testing.T) {
+	base, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(base); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	r, err := git.PlainInit(base, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The local replace target does not exist, so the module only builds once it is stripped.
+	writeAndStage(t, w, base, map[string]string{
+		"go.mod":  "module aduu.dev/k\n\ngo 1.14\n\nreplace aduu.dev/utils => ../does-not-exist\n",
+		"main.go": "package main\n\nfunc main() {}\n",
+	})
+
+	if err = Staged(base, Options{Vet: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unstaged changes do not matter.
+	if err = ioutil.WriteFile(filepath.Join(base, "main.go"), []byte("package main\n\nfunc main() { broken }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = Staged(base, Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	writeAndStage(t, w, base, map[string]string{
+		"main.go": "package main\n\nfunc main() { broken }\n",
+	})
+
+	err = Staged(base, Options{})
+
+	failure, ok := err.(*Failure)
+	if !ok {
+		t.Fatalf("Staged should return a *Failure for a broken staged module, got %v", err)
+	}
+
+	assert.Contains(t, failure.Output, "broken")
+}
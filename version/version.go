@@ -0,0 +1,27 @@
+// Package version reports the version of the gogit binary.
+package version
+
+import (
+	"runtime/debug"
+)
+
+// Devel is the version of binaries built from a checkout instead of a released module.
+const Devel = "devel"
+
+// Version overrides the version from the build info,
+// e.g. with -ldflags "-X aduu.dev/tools/gogit/version.Version=v1.2.3".
+var Version = ""
+
+// Get returns the version of the running gogit binary, Devel if it is unknown.
+func Get() string {
+	if len(Version) != 0 {
+		return Version
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok || len(info.Main.Version) == 0 || info.Main.Version == "(devel)" {
+		return Devel
+	}
+
+	return info.Main.Version
+}
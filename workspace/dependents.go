@@ -0,0 +1,70 @@
+package workspace
+
+import (
+	"path/filepath"
+
+	"k8s.io/klog/v2"
+
+	"aduu.dev/tools/gogit/replace"
+	"aduu.dev/tools/gogit/verify"
+)
+
+// Dependent is a module which locally replaces another module.
+type Dependent struct {
+	Module
+	// Replace is the local replace directive pointing to the replaced module.
+	Replace replace.LocalReplace
+	// BuildErr is the result of Check, nil if the dependent builds.
+	BuildErr error
+}
+
+// Dependents returns the modules below the roots whose local replace directives resolve to the module in base.
+func Dependents(base string, roots []string) (dependents []*Dependent, err error) {
+	modules, err := Modules(roots)
+	if err != nil {
+		return
+	}
+
+	for _, mod := range modules {
+		if SameDir(mod.Dir, base) {
+			continue
+		}
+
+		replaces, err := replace.LocalReplaces(mod.Dir)
+		if err != nil {
+			klog.InfoS("Skipping module with unreadable go.mod", "dir", mod.Dir, "err", err)
+			continue
+		}
+
+		for _, rep := range replaces {
+			target := rep.New
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(mod.Dir, target)
+			}
+
+			if SameDir(target, base) {
+				dependents = append(dependents, &Dependent{Module: mod, Replace: rep})
+				break
+			}
+		}
+	}
+
+	return dependents, nil
+}
+
+// Check runs go build ./... offline in each dependent and records the result in BuildErr.
+// It returns true if all dependents build.
+func Check(dependents []*Dependent) (ok bool) {
+	ok = true
+
+	for _, dependent := range dependents {
+		dependent.BuildErr = verify.Module(dependent.Dir, verify.Options{})
+		if dependent.BuildErr != nil {
+			ok = false
+		}
+
+		klog.InfoS("Checked dependent", "module", dependent.Path, "dir", dependent.Dir, "ok", dependent.BuildErr == nil)
+	}
+
+	return ok
+}
@@ -0,0 +1,119 @@
+package workspace
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"k8s.io/klog/v2"
+
+	"aduu.dev/tools/gogit/replace"
+)
+
+var (
+	errModuleNotFound  = fmt.Errorf("module not found in the workspace")
+	errModuleAmbiguous = fmt.Errorf("module found in several places in the workspace")
+)
+
+// Find returns the local replace directive pointing from the module in base
+// to the checkout of modulePath below the roots.
+func Find(base string, roots []string, modulePath string) (rep replace.LocalReplace, err error) {
+	modules, err := Modules(roots)
+	if err != nil {
+		return
+	}
+
+	var dirs []string
+
+	for _, mod := range modules {
+		if mod.Path == modulePath && !SameDir(mod.Dir, base) {
+			dirs = append(dirs, mod.Dir)
+		}
+	}
+
+	switch len(dirs) {
+	case 0:
+		return rep, fmt.Errorf("%w: %#v in %v", errModuleNotFound, modulePath, roots)
+	case 1:
+	default:
+		return rep, fmt.Errorf("%w: %#v in %v", errModuleAmbiguous, modulePath, dirs)
+	}
+
+	return localReplace(base, Module{Path: modulePath, Dir: dirs[0]})
+}
+
+// FindRequired returns local replace directives for every module required by the module in base
+// which is checked out exactly once below the roots and not already replaced locally.
+func FindRequired(base string, roots []string) (replaces []replace.LocalReplace, err error) {
+	requires, err := replace.Requires(base)
+	if err != nil {
+		return
+	}
+
+	existing, err := replace.LocalReplaces(base)
+	if err != nil {
+		return
+	}
+
+	for _, rep := range existing {
+		delete(requires, rep.Old.Path)
+	}
+
+	modules, err := Modules(roots)
+	if err != nil {
+		return
+	}
+
+	found := map[string][]Module{}
+
+	for _, mod := range modules {
+		if _, ok := requires[mod.Path]; ok && !SameDir(mod.Dir, base) {
+			found[mod.Path] = append(found[mod.Path], mod)
+		}
+	}
+
+	paths := make([]string, 0, len(found))
+	for modulePath := range found {
+		paths = append(paths, modulePath)
+	}
+
+	sort.Strings(paths)
+
+	for _, modulePath := range paths {
+		if len(found[modulePath]) != 1 {
+			klog.InfoS("Skipping module found in several places", "module", modulePath, "count", len(found[modulePath]))
+			continue
+		}
+
+		rep, err := localReplace(base, found[modulePath][0])
+		if err != nil {
+			return nil, err
+		}
+
+		replaces = append(replaces, rep)
+	}
+
+	return replaces, nil
+}
+
+// localReplace returns the replace directive pointing from base to mod with a relative path.
+func localReplace(base string, mod Module) (rep replace.LocalReplace, err error) {
+	rel, err := filepath.Rel(resolve(base), resolve(mod.Dir))
+	if err != nil {
+		return
+	}
+
+	rel = filepath.ToSlash(rel)
+
+	// Local replace targets have to start with ./ or ../ to not be taken for a module path.
+	if !strings.HasPrefix(rel, "../") {
+		rel = "./" + rel
+	}
+
+	return replace.LocalReplace{
+		Old: module.Version{Path: mod.Path},
+		New: rel,
+	}, nil
+}
@@ -0,0 +1,133 @@
+// Package workspace finds the Go modules checked out below workspace roots,
+// e.g. the sibling checkouts next to the current module.
+package workspace
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// RootsEnv is the environment variable listing the workspace roots, separated like PATH.
+const RootsEnv = "GOGIT_WORKSPACE_ROOTS"
+
+// Module is a Go module found in a workspace.
+type Module struct {
+	// Path is the module path declared in go.mod.
+	Path string
+	// Dir is the absolute directory containing go.mod.
+	Dir string
+}
+
+// Roots returns the given roots, else the roots in $GOGIT_WORKSPACE_ROOTS,
+// else the parent directory of the module in base, where its sibling checkouts are.
+func Roots(base string, roots []string) (out []string, err error) {
+	if len(roots) == 0 {
+		roots = filepath.SplitList(os.Getenv(RootsEnv))
+	}
+
+	if len(roots) == 0 {
+		abs, err := filepath.Abs(base)
+		if err != nil {
+			return nil, err
+		}
+
+		roots = []string{filepath.Dir(abs)}
+	}
+
+	for _, root := range roots {
+		if len(root) == 0 {
+			continue
+		}
+
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, abs)
+	}
+
+	return out, nil
+}
+
+// Modules returns the modules below the roots.
+//
+// Hidden directories, vendor, testdata and node_modules are skipped.
+// A module found below several roots is only returned once.
+func Modules(roots []string) (modules []Module, err error) {
+	seen := map[string]bool{}
+
+	for _, root := range roots {
+		err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				// Unreadable directories should not stop the search.
+				if info != nil && info.IsDir() {
+					return filepath.SkipDir
+				}
+
+				return nil
+			}
+
+			if info.IsDir() {
+				if path != root && skipDir(info.Name()) {
+					return filepath.SkipDir
+				}
+
+				return nil
+			}
+
+			if info.Name() != "go.mod" {
+				return nil
+			}
+
+			dir := filepath.Dir(path)
+			if seen[dir] {
+				return nil
+			}
+
+			seen[dir] = true
+
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil
+			}
+
+			if modulePath := modfile.ModulePath(data); len(modulePath) != 0 {
+				modules = append(modules, Module{Path: modulePath, Dir: dir})
+			}
+
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	return modules, nil
+}
+
+func skipDir(name string) bool {
+	return strings.HasPrefix(name, ".") || name == "vendor" || name == "testdata" || name == "node_modules"
+}
+
+// SameDir returns true if both paths point to the same directory, following symlinks.
+func SameDir(a string, b string) bool {
+	return resolve(a) == resolve(b)
+}
+
+func resolve(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved
+	}
+
+	return filepath.Clean(abs)
+}
@@ -0,0 +1,140 @@
+package workspace
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeWorkspace writes the go.mod files keyed by their directory below root.
+func writeWorkspace(t *testing.T, root string, gomods map[string]string) {
+	for dir, gomod := range gomods {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(root, dir, "go.mod"), []byte(gomod), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir(os.TempDir(), t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = os.RemoveAll(dir); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// Resolve symlinks like /tmp on macOS so paths compare equal.
+	dir, err = filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func TestModules(t *testing.T) {
+	root := tempDir(t)
+
+	writeWorkspace(t, root, map[string]string{
+		"utils":                 "module aduu.dev/utils\n",
+		"k/sub":                 "module aduu.dev/k/sub\n",
+		"k/vendor/x":            "module x\n",
+		"k/.hidden":             "module hidden\n",
+		"k/testdata/fake":       "module fake\n",
+		"web/node_modules/some": "module some\n",
+	})
+
+	modules, err := Modules([]string{root, filepath.Join(root, "utils")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []Module{
+		{Path: "aduu.dev/k/sub", Dir: filepath.Join(root, "k", "sub")},
+		{Path: "aduu.dev/utils", Dir: filepath.Join(root, "utils")},
+	}, modules)
+}
+
+func TestDependents(t *testing.T) {
+	root := tempDir(t)
+
+	writeWorkspace(t, root, map[string]string{
+		"utils": "module aduu.dev/utils\n\ngo 1.14\n",
+		"k":     "module aduu.dev/k\n\ngo 1.14\n\nrequire aduu.dev/utils v1.0.0\n\nreplace aduu.dev/utils => ../utils\n",
+		"other": "module aduu.dev/other\n\ngo 1.14\n\nrequire aduu.dev/utils v1.0.0\n\nreplace aduu.dev/utils => aduu.dev/fork v1.0.0\n",
+	})
+
+	if err := ioutil.WriteFile(filepath.Join(root, "k", "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dependents, err := Dependents(filepath.Join(root, "utils"), []string{root})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !assert.Len(t, dependents, 1) {
+		return
+	}
+
+	assert.Equal(t, "aduu.dev/k", dependents[0].Path)
+	assert.Equal(t, "aduu.dev/utils => ../utils", dependents[0].Replace.String())
+
+	assert.True(t, Check(dependents), "k should build: %v", dependents[0].BuildErr)
+}
+
+func TestFind(t *testing.T) {
+	root := tempDir(t)
+
+	writeWorkspace(t, root, map[string]string{
+		"k":          "module aduu.dev/k\n\nrequire (\n\taduu.dev/utils v1.0.0\n\taduu.dev/twice v1.0.0\n\taduu.dev/remote v1.0.0\n)\n",
+		"k/sub":      "module aduu.dev/sub\n",
+		"utils":      "module aduu.dev/utils\n",
+		"a/twice":    "module aduu.dev/twice\n",
+		"b/twice":    "module aduu.dev/twice\n",
+		"unrequired": "module aduu.dev/unrequired\n",
+	})
+
+	base := filepath.Join(root, "k")
+
+	rep, err := Find(base, []string{root}, "aduu.dev/utils")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "aduu.dev/utils => ../utils", rep.String())
+
+	rep, err = Find(base, []string{root}, "aduu.dev/sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "aduu.dev/sub => ./sub", rep.String(), "nested modules need a ./ prefix")
+
+	_, err = Find(base, []string{root}, "aduu.dev/twice")
+	assert.True(t, errors.Is(err, errModuleAmbiguous), "got %v", err)
+
+	_, err = Find(base, []string{root}, "aduu.dev/remote")
+	assert.True(t, errors.Is(err, errModuleNotFound), "got %v", err)
+
+	replaces, err := FindRequired(base, []string{root})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, replaces, 1, "only unambiguous required modules") {
+		assert.Equal(t, "aduu.dev/utils => ../utils", replaces[0].String())
+	}
+}